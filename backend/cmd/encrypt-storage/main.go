@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/readitlater/backend/internal/config"
+	"github.com/readitlater/backend/internal/database"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/search"
+	"github.com/readitlater/backend/internal/services"
+	"github.com/readitlater/backend/internal/storage"
+	"github.com/readitlater/backend/pkg/logger"
+)
+
+// encrypt-storage encrypts the content and assets of articles saved before
+// server-side encryption was enabled under their users' server-managed
+// keys. It runs alongside the server and can be interrupted and run again
+// to resume.
+func main() {
+	var options models.ServerEncryptionOptions
+	flag.StringVar(&options.UserID, "user", "", "only encrypt the content of this user ID")
+	flag.IntVar(&options.BatchSize, "batch", 500, "articles encrypted between progress reports")
+	flag.DurationVar(&options.Pause, "pause", 100*time.Millisecond, "pause between batches to limit load")
+	flag.BoolVar(&options.DryRun, "dry-run", false, "only count the articles left to encrypt")
+	flag.Parse()
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+
+	logger := logger.New(cfg)
+
+	// Open database connection
+	db, err := database.New(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	// Open search index
+	searchIndex, err := search.New(cfg, db)
+	if err != nil {
+		log.Fatal("Failed to open search index:", err)
+	}
+	defer searchIndex.Close()
+
+	storageBackend, err := storage.NewBackend(cfg)
+	if err != nil {
+		log.Fatal("Failed to open storage backend:", err)
+	}
+	storageService := storage.NewService(cfg, storageBackend, logger)
+	articleService := services.NewArticleService(cfg, db, storageService, searchIndex, logger)
+
+	// Stop after the article being encrypted on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, err := articleService.EncryptExistingContent(ctx, &options, func(progress *models.ServerEncryptionProgress) {
+		fmt.Printf("%d/%d articles (%d encrypted, %d skipped, %d failed) in %s\n",
+			progress.Done(), progress.Total, progress.Encrypted, progress.Skipped, progress.Failed,
+			progress.Elapsed.Round(time.Second))
+	})
+	if ctx.Err() != nil && report != nil {
+		fmt.Printf("Interrupted after %d of %d articles, run again to resume\n", report.Done(), report.Total)
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatal("Failed to encrypt existing content:", err)
+	}
+
+	if options.DryRun {
+		fmt.Printf("%d articles to encrypt under server-managed keys\n", report.Total)
+		return
+	}
+
+	fmt.Printf("Encrypted %d articles under server-managed keys (%d skipped, %d failed)\n",
+		report.Encrypted, report.Skipped, report.Failed)
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/readitlater/backend/internal/encryption"
+)
+
+// escrow manages the organization key escrow offline. keygen creates the
+// key pair whose public key is set as RECOVERY_ESCROW_PUBLIC_KEY; open
+// decrypts an escrowed key released by the admin API, read from stdin, with
+// the private key in ESCROW_PRIVATE_KEY.
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal("Usage: escrow keygen | escrow open -user USER_ID < escrowed_key")
+	}
+
+	switch os.Args[1] {
+	case "keygen":
+		publicKey, privateKey, err := encryption.GenerateEscrowKeyPair()
+		if err != nil {
+			log.Fatal("Failed to generate escrow key pair:", err)
+		}
+		keyID, _ := encryption.EscrowKeyID(publicKey)
+
+		fmt.Printf("Escrow key ID:  %s\n", keyID)
+		fmt.Printf("Public key:     %s\n", publicKey)
+		fmt.Printf("Private key:    %s\n", privateKey)
+		fmt.Println("Keep the private key offline; only the public key goes in the server configuration.")
+
+	case "open":
+		flags := flag.NewFlagSet("open", flag.ExitOnError)
+		userID := flags.String("user", "", "ID of the user the key was escrowed for")
+		flags.Parse(os.Args[2:])
+
+		privateKey := os.Getenv("ESCROW_PRIVATE_KEY")
+		if *userID == "" || privateKey == "" {
+			log.Fatal("Both -user and ESCROW_PRIVATE_KEY are required")
+		}
+
+		sealed, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && sealed == "" {
+			log.Fatal("Failed to read escrowed key:", err)
+		}
+
+		userKey, err := encryption.OpenEscrow(strings.TrimSpace(sealed), privateKey, *userID)
+		if err != nil {
+			log.Fatal("Failed to open escrowed key:", err)
+		}
+		fmt.Println(userKey)
+
+	default:
+		log.Fatalf("Unknown command %q, use keygen or open", os.Args[1])
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/readitlater/backend/internal/config"
+	"github.com/readitlater/backend/internal/database"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/services"
+	"github.com/readitlater/backend/internal/storage"
+	"github.com/readitlater/backend/pkg/logger"
+)
+
+// fsck cross-checks stored article content against the articles table and
+// optionally repairs rows and quarantines or deletes bad files
+func main() {
+	var options models.FsckOptions
+	flag.StringVar(&options.UserID, "user", "", "only check the content of this user ID")
+	flag.BoolVar(&options.Repair, "repair", false, "fix article rows to match storage")
+	flag.StringVar(&options.Files, "files", "", "what to do with bad files: quarantine or delete")
+	flag.BoolVar(&options.DryRun, "dry-run", false, "report what would be done without changing anything")
+	asJSON := flag.Bool("json", false, "print the full report as JSON")
+	flag.Parse()
+
+	if !models.IsValidFsckFiles(options.Files) {
+		log.Fatal("Invalid -files value, use quarantine or delete")
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+
+	logger := logger.New(cfg)
+
+	// Open database connection
+	db, err := database.New(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	storageBackend, err := storage.NewBackend(cfg)
+	if err != nil {
+		log.Fatal("Failed to open storage backend:", err)
+	}
+	fsckService := services.NewFsckService(db, storageBackend, logger)
+
+	report, err := fsckService.Run(&options)
+	if err != nil {
+		log.Fatal("Failed to check storage:", err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatal("Failed to write report:", err)
+		}
+	} else {
+		for _, issue := range report.Issues {
+			fmt.Printf("%-20s %s %s", issue.Type, issue.Key, issue.Detail)
+			if issue.Action != "" {
+				fmt.Printf(" [%s]", issue.Action)
+			}
+			if issue.Error != "" {
+				fmt.Printf(" (failed: %s)", issue.Error)
+			}
+			fmt.Println()
+		}
+
+		fmt.Printf("Checked %d files and %d articles, found %d issues\n",
+			report.FilesScanned, report.ArticlesScanned, len(report.Issues))
+		if options.DryRun {
+			fmt.Println("Dry run: nothing was changed")
+		}
+	}
+
+	if len(report.Issues) > 0 {
+		os.Exit(1)
+	}
+}
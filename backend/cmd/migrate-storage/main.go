@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/readitlater/backend/internal/config"
+	"github.com/readitlater/backend/internal/database"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/services"
+	"github.com/readitlater/backend/internal/storage"
+	"github.com/readitlater/backend/pkg/logger"
+)
+
+// migrate-storage moves stored article content to the current on-disk
+// layout. It runs alongside the server and can be interrupted and run again
+// to resume.
+func main() {
+	var options models.LayoutMigrationOptions
+	flag.StringVar(&options.UserID, "user", "", "only migrate the content of this user ID")
+	flag.IntVar(&options.BatchSize, "batch", 500, "articles moved between progress reports")
+	flag.DurationVar(&options.Pause, "pause", 100*time.Millisecond, "pause between batches to limit load")
+	flag.BoolVar(&options.DryRun, "dry-run", false, "only count the articles left to migrate")
+	flag.Parse()
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+
+	logger := logger.New(cfg)
+
+	// Open database connection
+	db, err := database.New(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	storageBackend, err := storage.NewBackend(cfg)
+	if err != nil {
+		log.Fatal("Failed to open storage backend:", err)
+	}
+	storageService := storage.NewService(cfg, storageBackend, logger)
+	migrationService := services.NewLayoutMigrationService(db, storageService, logger)
+
+	// Stop after the article being moved on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, err := migrationService.Run(ctx, &options, func(progress *models.LayoutMigrationProgress) {
+		fmt.Printf("%d/%d articles (%d moved, %d skipped, %d failed) in %s\n",
+			progress.Done(), progress.Total, progress.Moved, progress.Skipped, progress.Failed,
+			progress.Elapsed.Round(time.Second))
+	})
+	if ctx.Err() != nil && report != nil {
+		fmt.Printf("Interrupted after %d of %d articles, run again to resume\n", report.Done(), report.Total)
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatal("Failed to migrate storage layout:", err)
+	}
+
+	if options.DryRun {
+		fmt.Printf("%d articles to migrate to storage layout %d\n", report.Total, storage.CurrentLayout)
+		return
+	}
+
+	fmt.Printf("Moved %d articles to storage layout %d (%d skipped, %d failed)\n",
+		report.Moved, storage.CurrentLayout, report.Skipped, report.Failed)
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
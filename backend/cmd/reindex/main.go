@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/readitlater/backend/internal/config"
+	"github.com/readitlater/backend/internal/database"
+	"github.com/readitlater/backend/internal/search"
+	"github.com/readitlater/backend/internal/services"
+	"github.com/readitlater/backend/internal/storage"
+	"github.com/readitlater/backend/pkg/logger"
+)
+
+// reindex rebuilds the configured search index from the articles table
+func main() {
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+
+	logger := logger.New(cfg)
+
+	// Open database connection
+	db, err := database.New(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	// Open search index
+	searchIndex, err := search.New(cfg, db)
+	if err != nil {
+		log.Fatal("Failed to open search index:", err)
+	}
+	defer searchIndex.Close()
+
+	storageBackend, err := storage.NewBackend(cfg)
+	if err != nil {
+		log.Fatal("Failed to open storage backend:", err)
+	}
+	storageService := storage.NewService(cfg, storageBackend, logger)
+	articleService := services.NewArticleService(cfg, db, storageService, searchIndex, logger)
+
+	// Rebuild index
+	indexed, err := articleService.RebuildSearchIndex()
+	if err != nil {
+		log.Fatal("Failed to rebuild search index:", err)
+	}
+
+	fmt.Printf("Search index (%s) rebuilt with %d articles\n", searchIndex.Name(), indexed)
+}
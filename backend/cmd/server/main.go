@@ -10,12 +10,16 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 
 	"github.com/readitlater/backend/internal/config"
 	"github.com/readitlater/backend/internal/database"
+	"github.com/readitlater/backend/internal/encryption"
 	"github.com/readitlater/backend/internal/auth"
 	"github.com/readitlater/backend/internal/handlers"
 	"github.com/readitlater/backend/internal/middleware"
+	"github.com/readitlater/backend/internal/scheduler"
+	"github.com/readitlater/backend/internal/search"
 	"github.com/readitlater/backend/internal/services"
 	"github.com/readitlater/backend/internal/storage"
 	"github.com/readitlater/backend/pkg/logger"
@@ -53,17 +57,93 @@ func main() {
 
 	// Initialize services
 	authService := auth.NewService(cfg)
-	storageService := storage.NewService(cfg, logger)
+	storageBackend, err := storage.NewBackend(cfg)
+	if err != nil {
+		logger.Fatalf("Failed to initialize storage backend: %v", err)
+	}
+	checkStoragePermissions(storageBackend, logger)
+	storageService := storage.NewService(cfg, storageBackend, logger)
+
+	searchIndex, err := search.New(cfg, db)
+	if err != nil {
+		logger.Fatalf("Failed to initialize search index: %v", err)
+	}
+	defer searchIndex.Close()
 	
 	userService := services.NewUserService(db, authService, logger)
-	articleService := services.NewArticleService(db, storageService, logger)
+	articleService := services.NewArticleService(cfg, db, storageService, searchIndex, logger)
 	captureService := services.NewCaptureService(cfg, articleService, logger)
 
+	// Finish storage writes and deletes interrupted by a previous crash
+	if reconciled, err := articleService.ReconcileStorage(); err != nil {
+		logger.WithError(err).Warn("Failed to reconcile article storage")
+	} else if reconciled > 0 {
+		logger.WithField("articles", reconciled).Info("Reconciled article storage")
+	}
+
+	// Move server-managed keys off master keys listed in
+	// ENCRYPTION_KEY_PREVIOUS
+	if rewrapped, err := articleService.RewrapServerKeys(); err != nil {
+		logger.WithError(err).Warn("Failed to rewrap server-managed keys")
+	} else if rewrapped > 0 {
+		logger.WithField("keys", rewrapped).Info("Rewrapped server-managed keys under the current master key")
+	}
+	checkMasterKeys(articleService, logger)
+
+	smartListService := services.NewSmartListService(db, articleService, logger)
+	encryptedIndexService := services.NewEncryptedIndexService(db, logger)
+
+	// Cloud backup is only available when enabled on the server
+	var cloudBackup *storage.Backup
+	if cfg.EnableCloudBackup {
+		backupBackend, err := storage.NewBackupBackend(cfg)
+		if err != nil {
+			logger.Fatalf("Failed to initialize backup backend: %v", err)
+		}
+		checkStoragePermissions(backupBackend, logger)
+		cloudBackup = storage.NewBackup(storageBackend, backupBackend, encryption.NewService(cfg.EncryptionAlgorithm, cfg.KeyDerivationIterations), cfg.EncryptionKey, cfg.BackupRetention).WithPreviousKeys(cfg.PreviousEncryptionKeys)
+	}
+	backupService := services.NewBackupService(db, cloudBackup, articleService, logger)
+	fsckService := services.NewFsckService(db, storageBackend, logger)
+	keyRotationService := services.NewKeyRotationService(db, articleService, storageService, logger)
+	keyRecoveryService := services.NewKeyRecoveryService(cfg, db, articleService, logger)
+	if err := backupService.FailStaleBackups(services.BackupTimeout); err != nil {
+		logger.WithError(err).Warn("Failed to clean up interrupted backups")
+	}
+
+	// Run maintenance jobs in the background. The Redis lock makes sure only
+	// one replica runs each job at a time.
+	jobRunService := services.NewJobRunService(db, logger)
+	if err := jobRunService.FailStaleRuns(scheduler.DefaultJobTimeout); err != nil {
+		logger.WithError(err).Warn("Failed to clean up interrupted job runs")
+	}
+
+	maintenanceService := services.NewMaintenanceService(cfg, db, articleService, storageBackend, searchIndex, logger)
+	jobs, err := maintenanceService.Jobs()
+	if err != nil {
+		logger.Fatalf("Failed to configure maintenance jobs: %v", err)
+	}
+
+	jobScheduler := scheduler.New(scheduler.NewRedisLocker(redisClient), jobRunService, logger)
+	for _, job := range jobs {
+		if err := jobScheduler.Add(job); err != nil {
+			logger.Fatalf("Failed to schedule job %s: %v", job.Name, err)
+		}
+	}
+	jobScheduler.Start()
+
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(userService, authService, logger)
-	userHandler := handlers.NewUserHandler(userService, logger)
-	articleHandler := handlers.NewArticleHandler(articleService, logger)
+	userHandler := handlers.NewUserHandler(userService, articleService, logger)
+	articleHandler := handlers.NewArticleHandler(articleService, smartListService, logger)
+	smartListHandler := handlers.NewSmartListHandler(smartListService, logger)
+	encryptedSearchHandler := handlers.NewEncryptedSearchHandler(articleService, encryptedIndexService, logger)
 	captureHandler := handlers.NewCaptureHandler(captureService, logger)
+	backupHandler := handlers.NewBackupHandler(backupService, logger)
+	keyRotationHandler := handlers.NewKeyRotationHandler(keyRotationService, logger)
+	encryptionHandler := handlers.NewEncryptionHandler(articleService, logger)
+	keyRecoveryHandler := handlers.NewKeyRecoveryHandler(keyRecoveryService, logger)
+	adminHandler := handlers.NewAdminHandler(fsckService, jobRunService, jobScheduler, logger)
 
 	// Setup Gin router
 	if cfg.IsProduction() {
@@ -124,8 +204,13 @@ func main() {
 				articles.POST("/:id/read", articleHandler.MarkAsRead)
 				articles.POST("/:id/favorite", articleHandler.ToggleFavorite)
 				articles.POST("/:id/archive", articleHandler.ToggleArchive)
+				articles.PUT("/:id/assets/:name", articleHandler.UploadAsset)
+				articles.GET("/:id/assets/:name", articleHandler.GetAsset)
 			}
 
+			// Atom feed of articles
+			protected.GET("/feed", articleHandler.GetFeed)
+
 			// Capture routes
 			capture := protected.Group("/capture")
 			{
@@ -138,6 +223,50 @@ func main() {
 			{
 				search.GET("/articles", articleHandler.SearchArticles)
 				search.GET("/suggestions", articleHandler.GetSearchSuggestions)
+				search.GET("/encrypted", encryptedSearchHandler.SearchBlindIndex)
+				search.GET("/encrypted-index", encryptedSearchHandler.GetEncryptedIndex)
+				search.PUT("/encrypted-index", encryptedSearchHandler.PutEncryptedIndex)
+				search.GET("/encrypted-index/versions", encryptedSearchHandler.GetEncryptedIndexVersions)
+			}
+
+			// Backup routes
+			backups := protected.Group("/backups")
+			{
+				backups.GET("", backupHandler.GetBackups)
+				backups.POST("", backupHandler.CreateBackup)
+				backups.GET("/:id", backupHandler.GetBackup)
+				backups.POST("/:id/restore", backupHandler.RestoreBackup)
+			}
+
+			// Encryption key routes
+			encryption := protected.Group("/encryption")
+			{
+				encryption.GET("", encryptionHandler.GetStatus)
+				encryption.POST("/verify", encryptionHandler.VerifyKey)
+				encryption.GET("/rotations", keyRotationHandler.GetRotations)
+				encryption.POST("/rotations", keyRotationHandler.StartRotation)
+				encryption.GET("/rotations/:id", keyRotationHandler.GetRotation)
+				encryption.POST("/rotations/:id/pause", keyRotationHandler.PauseRotation)
+				encryption.POST("/rotations/:id/resume", keyRotationHandler.ResumeRotation)
+				encryption.GET("/recovery", keyRecoveryHandler.GetRecovery)
+				encryption.POST("/recovery", keyRecoveryHandler.SetupRecovery)
+				encryption.DELETE("/recovery", keyRecoveryHandler.DeleteRecovery)
+				encryption.POST("/recovery/test", keyRecoveryHandler.TestRecovery)
+				encryption.POST("/recovery/use", keyRecoveryHandler.UseRecovery)
+				encryption.GET("/recovery/events", keyRecoveryHandler.GetEvents)
+			}
+
+			// Smart list routes
+			lists := protected.Group("/lists")
+			{
+				lists.GET("", smartListHandler.GetSmartLists)
+				lists.POST("", smartListHandler.CreateSmartList)
+				lists.PUT("/order", smartListHandler.ReorderSmartLists)
+				lists.GET("/:id", smartListHandler.GetSmartList)
+				lists.PUT("/:id", smartListHandler.UpdateSmartList)
+				lists.DELETE("/:id", smartListHandler.DeleteSmartList)
+				lists.GET("/:id/articles", smartListHandler.GetSmartListArticles)
+				lists.POST("/:id/pin", smartListHandler.TogglePin)
 			}
 
 			// Export/Import routes
@@ -146,6 +275,17 @@ func main() {
 				export.GET("/articles", articleHandler.ExportArticles)
 				export.POST("/import", articleHandler.ImportArticles)
 			}
+
+			// Admin routes
+			admin := protected.Group("/admin")
+			admin.Use(middleware.Admin(cfg))
+			{
+				admin.POST("/storage/fsck", adminHandler.RunStorageCheck)
+				admin.GET("/jobs", adminHandler.GetJobs)
+				admin.GET("/jobs/:name/runs", adminHandler.GetJobRuns)
+				admin.GET("/users/:id/recovery/escrow", keyRecoveryHandler.GetEscrowedKey)
+				admin.GET("/encryption/master-keys", encryptionHandler.GetMasterKeyUsage)
+			}
 		}
 
 		// Extension API routes (with API key authentication)
@@ -189,5 +329,50 @@ func main() {
 		logger.Errorf("Server forced to shutdown: %v", err)
 	}
 
+	// Stop scheduling jobs and wait for running ones to finish
+	if err := jobScheduler.Stop(ctx); err != nil {
+		logger.Errorf("Background jobs forced to stop: %v", err)
+	}
+
+	// Pause key rotations so they can be resumed after a restart
+	keyRotationService.Stop()
+
+	// Interrupt running backups; they are recorded as failed
+	backupService.Stop()
+
 	logger.Info("Server exited")
-} 
\ No newline at end of file
+}
+
+// checkMasterKeys warns about master keys that were dropped from
+// ENCRYPTION_KEY_PREVIOUS while server-managed keys or backups still need
+// them. Content and restores depending on them fail until they are added
+// back.
+func checkMasterKeys(articleService *services.ArticleService, logger *logrus.Logger) {
+	usage, err := articleService.MasterKeyUsage()
+	if err != nil {
+		logger.WithError(err).Warn("Failed to check master key usage")
+		return
+	}
+
+	for _, key := range usage {
+		if !key.Configured && (key.Keys > 0 || key.Backups > 0) {
+			logger.WithFields(logrus.Fields{
+				"master_key_id": key.MasterKeyID,
+				"keys":          key.Keys,
+				"backups":       key.Backups,
+			}).Warn("A master key that is not configured is still needed; add it back to ENCRYPTION_KEY_PREVIOUS")
+		}
+	}
+}
+
+// checkStoragePermissions refuses to start when stored content could be read
+// by other users on the host
+func checkStoragePermissions(backend storage.Backend, logger *logrus.Logger) {
+	checker, ok := backend.(storage.PermissionChecker)
+	if !ok {
+		return
+	}
+	if err := checker.CheckPermissions(); err != nil {
+		logger.Fatalf("Insecure storage permissions: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits maps size suffixes to bytes. Sizes use binary multiples, so
+// 10GB is 10 * 1024^3 bytes, matching the users.max_storage_limit default.
+var sizeUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"K":   1 << 10,
+	"KB":  1 << 10,
+	"KIB": 1 << 10,
+	"M":   1 << 20,
+	"MB":  1 << 20,
+	"MIB": 1 << 20,
+	"G":   1 << 30,
+	"GB":  1 << 30,
+	"GIB": 1 << 30,
+	"T":   1 << 40,
+	"TB":  1 << 40,
+	"TIB": 1 << 40,
+}
+
+// ParseSize parses a human-readable size such as "10GB", "512 MiB" or
+// "1.5G" into bytes. An empty string, "0" or "unlimited" means no limit and
+// returns 0.
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" || s == "UNLIMITED" {
+		return 0, nil
+	}
+
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+		i++
+	}
+
+	number, unit := s[:i], strings.TrimSpace(s[i:])
+	multiplier, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q", unit)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	bytes := value * float64(multiplier)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return int64(bytes), nil
+}
+
+// FormatSize renders a byte count in the largest unit that keeps the
+// value at or above one, with at most one decimal, e.g. 1.5GB
+func FormatSize(bytes int64) string {
+	units := []string{"TB", "GB", "MB", "KB"}
+	for i, unit := range units {
+		multiplier := int64(1) << (10 * (len(units) - i))
+		if bytes >= multiplier {
+			value := fmt.Sprintf("%.1f", float64(bytes)/float64(multiplier))
+			return strings.TrimSuffix(value, ".0") + unit
+		}
+	}
+	return strconv.FormatInt(bytes, 10) + "B"
+}
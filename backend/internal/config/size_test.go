@@ -0,0 +1,54 @@
+package config
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		cases := map[string]int64{
+			"":          0,
+			"0":         0,
+			"unlimited": 0,
+			"512":       512,
+			"100B":      100,
+			"1KB":       1024,
+			"2 mb":      2 << 20,
+			"512MiB":    512 << 20,
+			"1.5G":      3 << 29,
+			"10GB":      10737418240,
+			"1TB":       1 << 40,
+		}
+		for input, expected := range cases {
+			got, err := ParseSize(input)
+			if err != nil {
+				t.Errorf("ParseSize(%q) failed: %v", input, err)
+				continue
+			}
+			if got != expected {
+				t.Errorf("ParseSize(%q) = %d, expected %d", input, got, expected)
+			}
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, input := range []string{"GB", "10XB", "ten", "1.2.3GB", "-5MB", "99999999999TB"} {
+			if _, err := ParseSize(input); err == nil {
+				t.Errorf("ParseSize(%q) should fail", input)
+			}
+		}
+	})
+}
+
+func TestFormatSize(t *testing.T) {
+	cases := map[int64]string{
+		0:           "0B",
+		1023:        "1023B",
+		1024:        "1KB",
+		1536:        "1.5KB",
+		10737418240: "10GB",
+	}
+	for input, expected := range cases {
+		if got := FormatSize(input); got != expected {
+			t.Errorf("FormatSize(%d) = %q, expected %q", input, got, expected)
+		}
+	}
+}
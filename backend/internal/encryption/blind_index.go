@@ -0,0 +1,66 @@
+package encryption
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// BlindTokenLength is the length in bytes of a blind index token before hex
+// encoding. Tokens are truncated HMAC-SHA256 outputs.
+const BlindTokenLength = 16
+
+// blindIndexInfo separates the blind index key from other keys derived from
+// the same user key
+const blindIndexInfo = "readitlater blind index v1"
+
+// DeriveBlindIndexKey derives the key used to compute blind index tokens from
+// a base64 user key. Clients run this; the server never sees the result.
+func DeriveBlindIndexKey(userKey string) ([]byte, error) {
+	userKeyBytes, err := base64.StdEncoding.DecodeString(userKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode user key: %w", err)
+	}
+
+	return DeriveSubkey(userKeyBytes, nil, blindIndexInfo)
+}
+
+// BlindTokens returns the deduplicated blind index tokens of a text. Words
+// are lower-cased and split on anything that is not a letter or digit, so
+// clients must tokenize queries with the same function.
+func BlindTokens(indexKey []byte, text string) []string {
+	seen := map[string]bool{}
+	tokens := []string{}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		token := BlindToken(indexKey, word)
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// BlindToken returns the blind index token of a single normalized word
+func BlindToken(indexKey []byte, word string) string {
+	mac := hmac.New(sha256.New, indexKey)
+	mac.Write([]byte(word))
+	return hex.EncodeToString(mac.Sum(nil)[:BlindTokenLength])
+}
+
+// IsValidBlindToken checks that a token has the shape produced by BlindToken
+func IsValidBlindToken(token string) bool {
+	if len(token) != hex.EncodedLen(BlindTokenLength) {
+		return false
+	}
+	_, err := hex.DecodeString(token)
+	return err == nil && strings.ToLower(token) == token
+}
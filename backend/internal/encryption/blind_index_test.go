@@ -0,0 +1,47 @@
+package encryption
+
+import "testing"
+
+func TestBlindIndex(t *testing.T) {
+	service := NewService(AlgorithmAES256GCM, 10000)
+	userKey, _ := service.GenerateUserKey()
+	otherKey, _ := service.GenerateUserKey()
+
+	indexKey, err := DeriveBlindIndexKey(userKey)
+	if err != nil {
+		t.Fatalf("Failed to derive blind index key: %v", err)
+	}
+
+	t.Run("TokensAreNormalizedAndDeduplicated", func(t *testing.T) {
+		tokens := BlindTokens(indexKey, "Go, go; GO! Rust")
+		if len(tokens) != 2 {
+			t.Fatalf("Expected 2 tokens, got %d", len(tokens))
+		}
+		if tokens[0] != BlindToken(indexKey, "go") || tokens[1] != BlindToken(indexKey, "rust") {
+			t.Error("Tokens should match the lower-cased words")
+		}
+		for _, token := range tokens {
+			if !IsValidBlindToken(token) {
+				t.Errorf("Token %q should be valid", token)
+			}
+		}
+	})
+
+	t.Run("TokensDependOnKey", func(t *testing.T) {
+		otherIndexKey, err := DeriveBlindIndexKey(otherKey)
+		if err != nil {
+			t.Fatalf("Failed to derive blind index key: %v", err)
+		}
+		if BlindToken(indexKey, "golang") == BlindToken(otherIndexKey, "golang") {
+			t.Error("Different keys should produce different tokens")
+		}
+	})
+
+	t.Run("InvalidTokens", func(t *testing.T) {
+		for _, token := range []string{"", "golang", "ABCDEF0123456789ABCDEF0123456789", "zz" + BlindToken(indexKey, "go")[2:]} {
+			if IsValidBlindToken(token) {
+				t.Errorf("Token %q should be invalid", token)
+			}
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// Key hierarchy. Each encrypted article has its own random data key, and
+// only the data key encrypts content. The data key is stored with the
+// article wrapped by a key-encryption key (KEK) that belongs to the user:
+//
+//	user secret ──HKDF──▶ user KEK ──wraps──▶ article data key ──▶ content
+//	ENCRYPTION_KEY ──HKDF──▶ server KEK (server-managed mode)
+//
+// Changing a user's key only means rewrapping small data keys, and reading
+// an article runs no expensive key derivation.
+const (
+	userKEKInfo   = "readitlater user kek v1"
+	serverKEKInfo = "readitlater server kek v1"
+)
+
+// DeriveUserKEK derives a user's key-encryption key from their base64 user
+// key. User keys are random 256-bit values, so HKDF bound to the user ID is
+// enough and the result can be derived once per request. Passphrases would
+// need a slow KDF, so decodeUserKey rejects them.
+func DeriveUserKEK(userKey, userID string) ([]byte, error) {
+	secret, err := decodeUserKey(userKey)
+	if err != nil {
+		return nil, err
+	}
+	return DeriveSubkey(secret, []byte(userID), userKEKInfo)
+}
+
+// decodeUserKey decodes a base64 user key in the format of GenerateUserKey:
+// exactly KeySize random bytes. Malformed keys are reported as
+// ErrInvalidKey.
+func decodeUserKey(userKey string) ([]byte, error) {
+	secret, err := base64.StdEncoding.DecodeString(userKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode user key: %v", ErrInvalidKey, err)
+	}
+	if len(secret) != KeySize {
+		return nil, fmt.Errorf("%w: user key must be %d random bytes", ErrInvalidKey, KeySize)
+	}
+	return secret, nil
+}
+
+// DeriveServerKEK derives a user's key-encryption key from the server master
+// key (ENCRYPTION_KEY) for server-managed encryption
+func DeriveServerKEK(masterKey, userID string) ([]byte, error) {
+	if len(masterKey) < 32 {
+		return nil, fmt.Errorf("master key too short")
+	}
+	return DeriveSubkey([]byte(masterKey), []byte(userID), serverKEKInfo)
+}
+
+// GenerateDataKey generates a random data key for one article
+func GenerateDataKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return key, nil
+}
+
+// WrapKey encrypts a data key under a KEK and returns a base64 envelope.
+// aad binds the wrapped key to its owner and must be given to UnwrapKey.
+func (s *Service) WrapKey(kek, dataKey, aad []byte) (string, error) {
+	if len(dataKey) != KeySize {
+		return "", fmt.Errorf("invalid data key size: %d", len(dataKey))
+	}
+	return s.sealWithKey(kek, dataKey, aad)
+}
+
+// UnwrapKey decrypts a data key wrapped by WrapKey
+func (s *Service) UnwrapKey(kek []byte, wrapped string, aad []byte) ([]byte, error) {
+	dataKey, err := openWithKey(kek, wrapped, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	if len(dataKey) != KeySize {
+		return nil, fmt.Errorf("invalid data key size: %d", len(dataKey))
+	}
+	return dataKey, nil
+}
+
+// EncryptWithKey encrypts data directly under a data key, authenticating
+// aad, and returns a base64 envelope. Unlike Encrypt it runs no key
+// derivation.
+func (s *Service) EncryptWithKey(data string, key, aad []byte) (string, error) {
+	return s.sealWithKey(key, []byte(data), aad)
+}
+
+// DecryptWithKey decrypts data produced by EncryptWithKey with the same aad
+func (s *Service) DecryptWithKey(encryptedData string, key, aad []byte) (string, error) {
+	plaintext, err := openWithKey(key, encryptedData, aad)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// SealWithKey seals binary data under a raw key in an envelope with no
+// KDF, authenticating aad. It is EncryptWithKey without the base64
+// encoding, for bulk data such as backups.
+func (s *Service) SealWithKey(key, plaintext, aad []byte) ([]byte, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key size: %d", len(key))
+	}
+
+	id, err := cipherID(s.algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &envelope{
+		Version: envelopeVersionFor(aad),
+		KDF:     kdfNone,
+		Cipher:  id,
+	}
+	if err := env.seal(key, plaintext, aad); err != nil {
+		return nil, err
+	}
+	return env.marshal(), nil
+}
+
+// OpenWithKey opens an envelope produced by SealWithKey with the same aad
+func OpenWithKey(key, sealed, aad []byte) ([]byte, error) {
+	env, isEnvelope, err := parseEnvelope(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if !isEnvelope || env.KDF != kdfNone {
+		return nil, fmt.Errorf("ciphertext is not sealed under a data key")
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key size: %d", len(key))
+	}
+
+	return env.open(key, aad)
+}
+
+// sealWithKey seals plaintext under a raw key and returns a base64 envelope
+func (s *Service) sealWithKey(key, plaintext, aad []byte) (string, error) {
+	sealed, err := s.SealWithKey(key, plaintext, aad)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openWithKey opens a base64 envelope sealed under a raw key
+func openWithKey(key []byte, encryptedData string, aad []byte) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encryptedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted data: %w", err)
+	}
+	return OpenWithKey(key, data, aad)
+}
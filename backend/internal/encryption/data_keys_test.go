@@ -0,0 +1,99 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestDataKeys(t *testing.T) {
+	service := NewService(AlgorithmXChaCha20Poly1305, 1000)
+	userKey, err := service.GenerateUserKey()
+	if err != nil {
+		t.Fatalf("Failed to generate user key: %v", err)
+	}
+
+	kek, err := DeriveUserKEK(userKey, "user-1")
+	if err != nil {
+		t.Fatalf("Failed to derive KEK: %v", err)
+	}
+	dataKey, err := GenerateDataKey()
+	if err != nil {
+		t.Fatalf("Failed to generate data key: %v", err)
+	}
+
+	t.Run("DeriveKEK", func(t *testing.T) {
+		again, _ := DeriveUserKEK(userKey, "user-1")
+		other, _ := DeriveUserKEK(userKey, "user-2")
+		server, _ := DeriveServerKEK("a-server-master-key-of-32-characters", "user-1")
+		if !bytes.Equal(kek, again) || bytes.Equal(kek, other) || bytes.Equal(kek, server) {
+			t.Error("KEKs should be deterministic per user and secret")
+		}
+		if _, err := DeriveUserKEK("c2hvcnQ=", "user-1"); err == nil {
+			t.Error("Expected a short user key to be rejected")
+		}
+		passphrase := base64.StdEncoding.EncodeToString([]byte("correct horse battery staple, 33+"))
+		if _, err := DeriveUserKEK(passphrase, "user-1"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Expected a key longer than KeySize to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("WrapUnwrap", func(t *testing.T) {
+		wrapped, err := service.WrapKey(kek, dataKey, []byte("article-1"))
+		if err != nil {
+			t.Fatalf("Failed to wrap key: %v", err)
+		}
+		if err := ValidateCiphertext(wrapped); err != nil {
+			t.Errorf("Wrapped key rejected: %v", err)
+		}
+
+		unwrapped, err := service.UnwrapKey(kek, wrapped, []byte("article-1"))
+		if err != nil || !bytes.Equal(unwrapped, dataKey) {
+			t.Fatalf("Failed to unwrap key: %v", err)
+		}
+		if _, err := service.UnwrapKey(kek, wrapped, []byte("article-2")); err == nil {
+			t.Error("Expected unwrapping with other associated data to fail")
+		}
+		otherKEK, _ := DeriveUserKEK(userKey, "user-2")
+		if _, err := service.UnwrapKey(otherKEK, wrapped, []byte("article-1")); err == nil {
+			t.Error("Expected unwrapping with another KEK to fail")
+		}
+	})
+
+	t.Run("EncryptWithKey", func(t *testing.T) {
+		encrypted, err := service.EncryptWithKey("message", dataKey, nil)
+		if err != nil {
+			t.Fatalf("Failed to encrypt: %v", err)
+		}
+
+		decrypted, err := service.DecryptWithKey(encrypted, dataKey, nil)
+		if err != nil || decrypted != "message" {
+			t.Errorf("Failed to decrypt: %q, %v", decrypted, err)
+		}
+
+		// Data key envelopes never go through the user key KDF
+		if _, err := service.Decrypt(encrypted, userKey); err == nil {
+			t.Error("Expected Decrypt to reject a data key envelope")
+		}
+		passwordEncrypted, _ := service.Encrypt("message", userKey)
+		if _, err := service.DecryptWithKey(passwordEncrypted, dataKey, nil); err == nil {
+			t.Error("Expected DecryptWithKey to reject a derived key envelope")
+		}
+	})
+
+	t.Run("SealWithKey", func(t *testing.T) {
+		sealed, err := service.SealWithKey(dataKey, []byte{0, 1, 2}, []byte("blob"))
+		if err != nil {
+			t.Fatalf("Failed to seal: %v", err)
+		}
+
+		opened, err := OpenWithKey(dataKey, sealed, []byte("blob"))
+		if err != nil || !bytes.Equal(opened, []byte{0, 1, 2}) {
+			t.Errorf("Failed to open: %v, %v", opened, err)
+		}
+		if _, err := OpenWithKey(dataKey, sealed, []byte("other")); err == nil {
+			t.Error("Expected opening with other associated data to fail")
+		}
+	})
+}
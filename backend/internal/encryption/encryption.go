@@ -1,25 +1,30 @@
 package encryption
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
-	"io"
 
 	"golang.org/x/crypto/pbkdf2"
 )
 
 // Service handles encryption and decryption operations
 type Service struct {
+	algorithm  string
 	iterations int
 }
 
-// NewService creates a new encryption service
-func NewService(iterations int) *Service {
+// NewService creates a new encryption service that encrypts with algorithm
+// and derives keys with the given number of PBKDF2 iterations, or
+// DefaultIterations if zero. Ciphertext records both, so changing them later
+// keeps old data readable.
+func NewService(algorithm string, iterations int) *Service {
+	if iterations <= 0 {
+		iterations = DefaultIterations
+	}
 	return &Service{
+		algorithm:  algorithm,
 		iterations: iterations,
 	}
 }
@@ -48,112 +53,207 @@ func (s *Service) DeriveKey(masterKey, salt string) ([]byte, error) {
 	return pbkdf2.Key(masterKeyBytes, saltBytes, s.iterations, 32, sha256.New), nil
 }
 
-// Encrypt encrypts data using AES-256-GCM
+// Encrypt encrypts data with the service's algorithm under a key derived
+// from userKey, and returns a base64 versioned envelope
 func (s *Service) Encrypt(data, userKey string) (string, error) {
+	return s.EncryptWithAAD(data, userKey, nil)
+}
+
+// EncryptWithAAD is like Encrypt but also authenticates aad, which must be
+// passed again to DecryptWithAAD. Binding ciphertext to where it is stored
+// keeps it from being copied somewhere else.
+func (s *Service) EncryptWithAAD(data, userKey string, aad []byte) (string, error) {
+	secret, err := base64.StdEncoding.DecodeString(userKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode master key: %w", err)
+	}
+
+	id, err := cipherID(s.algorithm)
+	if err != nil {
+		return "", err
+	}
+
 	// Generate a random salt for key derivation
-	salt := make([]byte, 16)
+	salt := make([]byte, saltSize)
 	if _, err := rand.Read(salt); err != nil {
 		return "", fmt.Errorf("failed to generate salt: %w", err)
 	}
-	
-	// Derive encryption key
-	key, err := s.DeriveKey(userKey, base64.StdEncoding.EncodeToString(salt))
-	if err != nil {
-		return "", fmt.Errorf("failed to derive key: %w", err)
-	}
-	
-	// Create AES cipher
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
+
+	env := &envelope{
+		Version:    envelopeVersionFor(aad),
+		KDF:        kdfPBKDF2SHA256,
+		Iterations: s.iterations,
+		Cipher:     id,
+		Salt:       salt,
 	}
-	
-	// Create GCM mode
-	gcm, err := cipher.NewGCM(block)
+
+	key, err := deriveEnvelopeKey(env, secret)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+		return "", fmt.Errorf("failed to derive key: %w", err)
 	}
-	
-	// Generate nonce
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", fmt.Errorf("failed to generate nonce: %w", err)
+
+	if err := env.seal(key, []byte(data), aad); err != nil {
+		return "", err
 	}
-	
-	// Encrypt data
-	ciphertext := gcm.Seal(nonce, nonce, []byte(data), nil)
-	
-	// Prepend salt to ciphertext
-	result := append(salt, ciphertext...)
-	
-	return base64.StdEncoding.EncodeToString(result), nil
+
+	return base64.StdEncoding.EncodeToString(env.marshal()), nil
 }
 
-// Decrypt decrypts data using AES-256-GCM
+// Decrypt decrypts data produced by Encrypt with any supported envelope
+// version, algorithm and iteration count, or in the format used before
+// envelopes
 func (s *Service) Decrypt(encryptedData, userKey string) (string, error) {
+	return s.DecryptWithAAD(encryptedData, userKey, nil)
+}
+
+// DecryptWithAAD decrypts data produced by EncryptWithAAD with the same aad.
+// Ciphertext without associated data only opens with a nil aad.
+func (s *Service) DecryptWithAAD(encryptedData, userKey string, aad []byte) (string, error) {
 	// Decode base64
 	data, err := base64.StdEncoding.DecodeString(encryptedData)
 	if err != nil {
 		return "", fmt.Errorf("failed to decode encrypted data: %w", err)
 	}
-	
-	if len(data) < 16 {
-		return "", fmt.Errorf("encrypted data too short")
+
+	secret, err := base64.StdEncoding.DecodeString(userKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive key: failed to decode master key: %w", err)
 	}
-	
-	// Extract salt and ciphertext
-	salt := data[:16]
-	ciphertext := data[16:]
-	
-	// Derive encryption key
-	key, err := s.DeriveKey(userKey, base64.StdEncoding.EncodeToString(salt))
+
+	env, isEnvelope, envErr := parseEnvelope(data)
+	if isEnvelope && envErr == nil {
+		plaintext, err := openEnvelope(env, secret, aad)
+		if err == nil {
+			return string(plaintext), nil
+		}
+		envErr = err
+	}
+
+	// A legacy ciphertext whose salt happens to start with the magic is
+	// still read. Legacy ciphertext has no associated data.
+	if len(aad) > 0 {
+		if isEnvelope {
+			return "", envErr
+		}
+		return "", fmt.Errorf("ciphertext has no associated data")
+	}
+	plaintext, err := openLegacy(data, secret)
 	if err != nil {
-		return "", fmt.Errorf("failed to derive key: %w", err)
+		if isEnvelope {
+			return "", envErr
+		}
+		return "", err
 	}
-	
-	// Create AES cipher
-	block, err := aes.NewCipher(key)
+
+	return string(plaintext), nil
+}
+
+// openEnvelope decrypts a parsed envelope
+func openEnvelope(env *envelope, secret, aad []byte) ([]byte, error) {
+	key, err := deriveEnvelopeKey(env, secret)
 	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
+		return nil, fmt.Errorf("failed to derive key: %w", err)
 	}
-	
-	// Create GCM mode
-	gcm, err := cipher.NewGCM(block)
+	return env.open(key, aad)
+}
+
+// openLegacy decrypts ciphertext written before envelopes:
+// salt | nonce | ciphertext with PBKDF2-SHA256 and AES-256-GCM
+func openLegacy(data, secret []byte) ([]byte, error) {
+	if len(data) < saltSize {
+		return nil, fmt.Errorf("encrypted data too short")
+	}
+
+	// Extract salt and ciphertext
+	salt := data[:saltSize]
+	ciphertext := data[saltSize:]
+
+	// Derive encryption key
+	key := pbkdf2.Key(secret, salt, legacyIterations, 32, sha256.New)
+
+	gcm, err := newAEAD(cipherAES256GCM, key)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+		return nil, err
 	}
-	
+
 	nonceSize := gcm.NonceSize()
 	if len(ciphertext) < nonceSize {
-		return "", fmt.Errorf("ciphertext too short")
+		return nil, fmt.Errorf("ciphertext too short")
 	}
-	
+
 	// Extract nonce and encrypted data
 	nonce, encData := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	
+
 	// Decrypt
 	plaintext, err := gcm.Open(nil, nonce, encData, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to decrypt: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
 	}
-	
-	return string(plaintext), nil
+
+	return plaintext, nil
 }
 
-// EncryptFile encrypts file content for storage
-func (s *Service) EncryptFile(content []byte, userKey string) ([]byte, error) {
-	encrypted, err := s.Encrypt(string(content), userKey)
+// HasAssociatedData reports whether ciphertext was sealed with associated
+// data, so it must be opened with the data it was bound to
+func HasAssociatedData(encryptedData string) bool {
+	data, err := base64.StdEncoding.DecodeString(encryptedData)
 	if err != nil {
-		return nil, err
+		return false
 	}
-	return []byte(encrypted), nil
+	env, isEnvelope, err := parseEnvelope(data)
+	return isEnvelope && err == nil && env.Version >= envelopeVersion2
 }
 
-// DecryptFile decrypts file content from storage
-func (s *Service) DecryptFile(encryptedContent []byte, userKey string) ([]byte, error) {
-	decrypted, err := s.Decrypt(string(encryptedContent), userKey)
+// ValidateCiphertext checks that data has the shape produced by Encrypt
+// without decrypting it: valid base64 holding a supported envelope, or the
+// legacy salt, nonce and GCM tag. It lets maintenance tools spot corrupted
+// ciphertext when the user key is unavailable.
+func ValidateCiphertext(encryptedData string) error {
+	data, err := base64.StdEncoding.DecodeString(encryptedData)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("invalid base64: %w", err)
 	}
-	return []byte(decrypted), nil
-} 
\ No newline at end of file
+
+	env, isEnvelope, err := parseEnvelope(data)
+	if isEnvelope {
+		if err != nil {
+			return err
+		}
+		aead, err := newAEAD(env.Cipher, make([]byte, 32))
+		if err != nil {
+			return err
+		}
+		if len(env.Nonce) != aead.NonceSize() || len(env.Ciphertext) < aead.Overhead() {
+			return fmt.Errorf("invalid envelope")
+		}
+		return nil
+	}
+
+	// salt (16) + GCM nonce (12) + GCM tag (16)
+	if len(data) < saltSize+12+16 {
+		return fmt.Errorf("encrypted data too short")
+	}
+	return nil
+}
+
+// ValidateDataKeyCiphertext checks that data is an envelope sealed directly
+// under a data key and bound to associated data, the only form of
+// ciphertext zero-knowledge clients may upload. Like ValidateCiphertext it
+// does not decrypt.
+func ValidateDataKeyCiphertext(encryptedData string) error {
+	if err := ValidateCiphertext(encryptedData); err != nil {
+		return err
+	}
+
+	data, _ := base64.StdEncoding.DecodeString(encryptedData)
+	env, isEnvelope, _ := parseEnvelope(data)
+	switch {
+	case !isEnvelope:
+		return fmt.Errorf("legacy ciphertext")
+	case env.KDF != kdfNone:
+		return fmt.Errorf("ciphertext is not sealed under a data key")
+	case env.Version < envelopeVersion2:
+		return fmt.Errorf("ciphertext has no associated data")
+	}
+	return nil
+}
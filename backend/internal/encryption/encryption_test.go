@@ -1,11 +1,13 @@
 package encryption
 
 import (
+	"bytes"
+	"errors"
 	"testing"
 )
 
 func TestEncryptionService(t *testing.T) {
-	service := NewService(1000) // Use fewer iterations for testing
+	service := NewService(AlgorithmAES256GCM, 1000) // Use fewer iterations for testing
 
 	t.Run("GenerateUserKey", func(t *testing.T) {
 		key, err := service.GenerateUserKey()
@@ -66,18 +68,18 @@ func TestEncryptionService(t *testing.T) {
 		testContent := []byte("This is test file content with some binary data: \x00\x01\x02\x03")
 		
 		// Encrypt file
-		encrypted, err := service.EncryptFile(testContent, userKey)
-		if err != nil {
+		var encrypted bytes.Buffer
+		if err := service.EncryptFile(&encrypted, bytes.NewReader(testContent), userKey); err != nil {
 			t.Fatalf("Failed to encrypt file: %v", err)
 		}
 		
 		// Decrypt file
-		decrypted, err := service.DecryptFile(encrypted, userKey)
-		if err != nil {
+		var decrypted bytes.Buffer
+		if err := service.DecryptFile(&decrypted, &encrypted, userKey); err != nil {
 			t.Fatalf("Failed to decrypt file: %v", err)
 		}
 		
-		if string(decrypted) != string(testContent) {
+		if decrypted.String() != string(testContent) {
 			t.Errorf("Decrypted file content doesn't match original")
 		}
 	})
@@ -103,8 +105,8 @@ func TestEncryptionService(t *testing.T) {
 		
 		// Try to decrypt with key 2 (should fail)
 		_, err = service.Decrypt(encrypted, userKey2)
-		if err == nil {
-			t.Error("Decryption should fail with wrong key")
+		if !errors.Is(err, ErrDecryptionFailed) {
+			t.Errorf("Decryption should fail with wrong key, got %v", err)
 		}
 	})
 
@@ -160,10 +162,51 @@ func TestEncryptionService(t *testing.T) {
 			t.Error("Decrypted large data doesn't match original")
 		}
 	})
+
+	t.Run("ValidateCiphertext", func(t *testing.T) {
+		userKey, err := service.GenerateUserKey()
+		if err != nil {
+			t.Fatalf("Failed to generate user key: %v", err)
+		}
+
+		encrypted, err := service.Encrypt("short", userKey)
+		if err != nil {
+			t.Fatalf("Failed to encrypt: %v", err)
+		}
+
+		if err := ValidateCiphertext(encrypted); err != nil {
+			t.Errorf("Valid ciphertext rejected: %v", err)
+		}
+		if err := ValidateCiphertext("not base64!"); err == nil {
+			t.Error("Invalid base64 should be rejected")
+		}
+		if err := ValidateCiphertext(encrypted[:20]); err == nil {
+			t.Error("Truncated ciphertext should be rejected")
+		}
+
+		dataKey, _ := GenerateDataKey()
+		bound, err := service.sealWithKey(dataKey, []byte("content"), []byte("user/article/content"))
+		if err != nil {
+			t.Fatalf("Failed to seal: %v", err)
+		}
+		unbound, err := service.sealWithKey(dataKey, []byte("content"), nil)
+		if err != nil {
+			t.Fatalf("Failed to seal: %v", err)
+		}
+		if err := ValidateDataKeyCiphertext(bound); err != nil {
+			t.Errorf("Valid data key ciphertext rejected: %v", err)
+		}
+		if err := ValidateDataKeyCiphertext(unbound); err == nil {
+			t.Error("Ciphertext without associated data should be rejected")
+		}
+		if err := ValidateDataKeyCiphertext(encrypted); err == nil {
+			t.Error("Ciphertext under a user key should be rejected")
+		}
+	})
 }
 
 func BenchmarkEncryption(b *testing.B) {
-	service := NewService(10000)
+	service := NewService(AlgorithmAES256GCM, 10000)
 	userKey, _ := service.GenerateUserKey()
 	testData := "This is a benchmark test message for encryption performance"
 
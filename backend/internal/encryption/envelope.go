@@ -0,0 +1,270 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Supported content encryption algorithms
+const (
+	AlgorithmAES256GCM         = "AES-256-GCM"
+	AlgorithmXChaCha20Poly1305 = "XChaCha20-Poly1305"
+)
+
+// Envelope format. Ciphertext produced by Encrypt is base64 of
+//
+//	magic | version | kdf id | kdf params | cipher id | salt len | salt | nonce len | nonce | ciphertext
+//
+// The header up to the nonce is authenticated as additional data, so the
+// parameters cannot be swapped without decryption failing. Version 2
+// envelopes also authenticate associated data given by the caller after the
+// header, so readers know to supply it; version 1 envelopes carry none,
+// except for data keys wrapped before version 2 existed. Envelopes
+// sealed directly under a random key, such as an article's data key, record
+// kdfNone with no iterations or salt. Ciphertext
+// written before envelopes existed is base64(salt | nonce | ciphertext)
+// with PBKDF2-SHA256 at legacyIterations and AES-256-GCM.
+const (
+	envelopeVersion1 = 1
+	envelopeVersion2 = 2
+
+	kdfNone         = 0
+	kdfPBKDF2SHA256 = 1
+
+	cipherAES256GCM         = 1
+	cipherXChaCha20Poly1305 = 2
+)
+
+// envelopeMagic starts every envelope. A legacy ciphertext starts with a
+// random salt, which matches it with negligible probability.
+var envelopeMagic = []byte("RILE")
+
+// DefaultIterations is the PBKDF2 iteration count used when none is
+// configured
+const DefaultIterations = 100000
+
+// legacyIterations is the PBKDF2 iteration count of ciphertext written
+// before envelopes recorded it
+const legacyIterations = 100000
+
+// MaxIterations bounds the PBKDF2 work an envelope can ask for, so a forged
+// header cannot tie up the server. At ten times DefaultIterations it costs
+// a fraction of a second per key derivation.
+const MaxIterations = 1000000
+
+// saltSize is the size of the random KDF salt
+const saltSize = 16
+
+// ErrUnsupportedEnvelope is returned for envelopes with an unknown version,
+// KDF or cipher
+var ErrUnsupportedEnvelope = errors.New("unsupported ciphertext format")
+
+// ErrDecryptionFailed is returned when ciphertext does not authenticate
+// under the key it is opened with: the key is wrong or the data was
+// tampered with
+var ErrDecryptionFailed = errors.New("failed to decrypt")
+
+// envelope is a parsed ciphertext
+type envelope struct {
+	Version    int
+	KDF        int
+	Iterations int
+	Cipher     int
+	Salt       []byte
+	Nonce      []byte
+	Ciphertext []byte
+	Header     []byte // authenticated header bytes
+}
+
+// IsSupportedAlgorithm reports whether Encrypt can use an algorithm
+func IsSupportedAlgorithm(algorithm string) bool {
+	_, err := cipherID(algorithm)
+	return err == nil
+}
+
+// cipherID maps an algorithm name to its envelope cipher id
+func cipherID(algorithm string) (int, error) {
+	switch algorithm {
+	case AlgorithmAES256GCM, "":
+		return cipherAES256GCM, nil
+	case AlgorithmXChaCha20Poly1305:
+		return cipherXChaCha20Poly1305, nil
+	default:
+		return 0, fmt.Errorf("%w: algorithm %s", ErrUnsupportedEnvelope, algorithm)
+	}
+}
+
+// newAEAD creates the cipher with an envelope cipher id under a 256-bit key
+func newAEAD(id int, key []byte) (cipher.AEAD, error) {
+	switch id {
+	case cipherAES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %w", err)
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCM: %w", err)
+		}
+		return gcm, nil
+	case cipherXChaCha20Poly1305:
+		aead, err := chacha20poly1305.NewX(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %w", err)
+		}
+		return aead, nil
+	default:
+		return nil, fmt.Errorf("%w: cipher %d", ErrUnsupportedEnvelope, id)
+	}
+}
+
+// deriveEnvelopeKey runs the KDF named by an envelope
+func deriveEnvelopeKey(env *envelope, secret []byte) ([]byte, error) {
+	switch env.KDF {
+	case kdfPBKDF2SHA256:
+		return pbkdf2.Key(secret, env.Salt, env.Iterations, 32, sha256.New), nil
+	case kdfNone:
+		return nil, fmt.Errorf("ciphertext is sealed under a data key")
+	default:
+		return nil, fmt.Errorf("%w: kdf %d", ErrUnsupportedEnvelope, env.KDF)
+	}
+}
+
+// envelopeVersionFor returns the version of a new envelope sealed with aad
+func envelopeVersionFor(aad []byte) int {
+	if len(aad) > 0 {
+		return envelopeVersion2
+	}
+	return envelopeVersion1
+}
+
+// seal generates a nonce and encrypts plaintext into the envelope under
+// key, authenticating the header followed by aad
+func (e *envelope) seal(key, plaintext, aad []byte) error {
+	aead, err := newAEAD(e.Cipher, key)
+	if err != nil {
+		return err
+	}
+
+	e.Nonce = make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, e.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	e.Ciphertext = aead.Seal(nil, e.Nonce, plaintext, append(e.header(), aad...))
+	return nil
+}
+
+// open decrypts a parsed envelope under key with the aad it was sealed with
+func (e *envelope) open(key, aad []byte) ([]byte, error) {
+	aead, err := newAEAD(e.Cipher, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce size: %d", len(e.Nonce))
+	}
+
+	plaintext, err := aead.Open(nil, e.Nonce, e.Ciphertext, append(e.Header[:len(e.Header):len(e.Header)], aad...))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+	return plaintext, nil
+}
+
+// header serializes the authenticated part of an envelope
+func (e *envelope) header() []byte {
+	var buf bytes.Buffer
+	buf.Write(envelopeMagic)
+	buf.WriteByte(byte(e.Version))
+	buf.WriteByte(byte(e.KDF))
+	binary.Write(&buf, binary.BigEndian, uint32(e.Iterations))
+	buf.WriteByte(byte(e.Cipher))
+	buf.WriteByte(byte(len(e.Salt)))
+	buf.Write(e.Salt)
+	buf.WriteByte(byte(len(e.Nonce)))
+	buf.Write(e.Nonce)
+	return buf.Bytes()
+}
+
+// marshal serializes an envelope
+func (e *envelope) marshal() []byte {
+	return append(e.header(), e.Ciphertext...)
+}
+
+// parseEnvelope parses a versioned envelope. It returns false if data is
+// not one, in which case it may be legacy ciphertext.
+func parseEnvelope(data []byte) (*envelope, bool, error) {
+	if !bytes.HasPrefix(data, envelopeMagic) {
+		return nil, false, nil
+	}
+
+	r := bytes.NewReader(data[len(envelopeMagic):])
+	env := &envelope{}
+
+	version, err := r.ReadByte()
+	if err != nil {
+		return nil, true, fmt.Errorf("envelope too short")
+	}
+	env.Version = int(version)
+	if env.Version != envelopeVersion1 && env.Version != envelopeVersion2 {
+		return nil, true, fmt.Errorf("%w: version %d", ErrUnsupportedEnvelope, env.Version)
+	}
+
+	kdf, err := r.ReadByte()
+	if err != nil {
+		return nil, true, fmt.Errorf("envelope too short")
+	}
+	env.KDF = int(kdf)
+	if env.KDF != kdfPBKDF2SHA256 && env.KDF != kdfNone {
+		return nil, true, fmt.Errorf("%w: kdf %d", ErrUnsupportedEnvelope, env.KDF)
+	}
+
+	var iterations uint32
+	if err := binary.Read(r, binary.BigEndian, &iterations); err != nil {
+		return nil, true, fmt.Errorf("envelope too short")
+	}
+	if (env.KDF == kdfNone) != (iterations == 0) || iterations > MaxIterations {
+		return nil, true, fmt.Errorf("invalid iteration count: %d", iterations)
+	}
+	env.Iterations = int(iterations)
+
+	cipherByte, err := r.ReadByte()
+	if err != nil {
+		return nil, true, fmt.Errorf("envelope too short")
+	}
+	env.Cipher = int(cipherByte)
+
+	if env.Salt, err = readField(r); err != nil {
+		return nil, true, err
+	}
+	if env.Nonce, err = readField(r); err != nil {
+		return nil, true, err
+	}
+
+	env.Header = data[:len(data)-r.Len()]
+	env.Ciphertext = data[len(env.Header):]
+	return env, true, nil
+}
+
+// readField reads a length-prefixed field
+func readField(r *bytes.Reader) ([]byte, error) {
+	size, err := r.ReadByte()
+	if err != nil || int(size) > r.Len() {
+		return nil, fmt.Errorf("envelope too short")
+	}
+
+	field := make([]byte, size)
+	r.Read(field)
+	return field, nil
+}
@@ -0,0 +1,170 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+func TestEnvelope(t *testing.T) {
+	aes256 := NewService(AlgorithmAES256GCM, 1000)
+	userKey, err := aes256.GenerateUserKey()
+	if err != nil {
+		t.Fatalf("Failed to generate user key: %v", err)
+	}
+
+	t.Run("Algorithms", func(t *testing.T) {
+		for _, algorithm := range []string{AlgorithmAES256GCM, AlgorithmXChaCha20Poly1305} {
+			service := NewService(algorithm, 1000)
+			encrypted, err := service.Encrypt("message", userKey)
+			if err != nil {
+				t.Fatalf("Failed to encrypt with %s: %v", algorithm, err)
+			}
+
+			data, _ := base64.StdEncoding.DecodeString(encrypted)
+			env, ok, err := parseEnvelope(data)
+			if !ok || err != nil {
+				t.Fatalf("Expected an envelope, got %t, %v", ok, err)
+			}
+			if expected, _ := cipherID(algorithm); env.Cipher != expected || env.Iterations != 1000 {
+				t.Errorf("Unexpected envelope parameters for %s: %+v", algorithm, env)
+			}
+
+			// Any service reads any envelope
+			decrypted, err := aes256.Decrypt(encrypted, userKey)
+			if err != nil || decrypted != "message" {
+				t.Errorf("Failed to decrypt %s envelope: %q, %v", algorithm, decrypted, err)
+			}
+		}
+	})
+
+	t.Run("ChangedIterations", func(t *testing.T) {
+		encrypted, err := NewService(AlgorithmXChaCha20Poly1305, 2000).Encrypt("message", userKey)
+		if err != nil {
+			t.Fatalf("Failed to encrypt: %v", err)
+		}
+
+		decrypted, err := aes256.Decrypt(encrypted, userKey)
+		if err != nil || decrypted != "message" {
+			t.Errorf("Failed to decrypt: %q, %v", decrypted, err)
+		}
+	})
+
+	t.Run("LegacyFormat", func(t *testing.T) {
+		secret, _ := base64.StdEncoding.DecodeString(userKey)
+		salt := make([]byte, saltSize)
+		rand.Read(salt)
+		block, _ := aes.NewCipher(pbkdf2.Key(secret, salt, legacyIterations, 32, sha256.New))
+		gcm, _ := cipher.NewGCM(block)
+		nonce := make([]byte, gcm.NonceSize())
+		rand.Read(nonce)
+
+		data := append(append(salt, nonce...), gcm.Seal(nil, nonce, []byte("legacy"), nil)...)
+		encrypted := base64.StdEncoding.EncodeToString(data)
+
+		decrypted, err := aes256.Decrypt(encrypted, userKey)
+		if err != nil || decrypted != "legacy" {
+			t.Errorf("Failed to decrypt legacy ciphertext: %q, %v", decrypted, err)
+		}
+		if err := ValidateCiphertext(encrypted); err != nil {
+			t.Errorf("Legacy ciphertext rejected: %v", err)
+		}
+	})
+
+	t.Run("TamperedHeader", func(t *testing.T) {
+		encrypted, err := aes256.Encrypt("message", userKey)
+		if err != nil {
+			t.Fatalf("Failed to encrypt: %v", err)
+		}
+		data, _ := base64.StdEncoding.DecodeString(encrypted)
+
+		// Lower the iteration count recorded in the header
+		data[len(envelopeMagic)+5]--
+		if _, err := aes256.Decrypt(base64.StdEncoding.EncodeToString(data), userKey); err == nil {
+			t.Error("Expected a tampered header to fail")
+		}
+	})
+
+	t.Run("ExcessiveIterations", func(t *testing.T) {
+		encrypted, err := aes256.Encrypt("message", userKey)
+		if err != nil {
+			t.Fatalf("Failed to encrypt: %v", err)
+		}
+		data, _ := base64.StdEncoding.DecodeString(encrypted)
+
+		// A forged header asking for more work than allowed is rejected
+		// before any key derivation
+		binary.BigEndian.PutUint32(data[len(envelopeMagic)+2:], MaxIterations+1)
+		tampered := base64.StdEncoding.EncodeToString(data)
+		if _, err := aes256.Decrypt(tampered, userKey); err == nil {
+			t.Error("Expected an excessive iteration count to fail")
+		}
+		if err := ValidateCiphertext(tampered); err == nil {
+			t.Error("Expected an excessive iteration count to be rejected")
+		}
+	})
+
+	t.Run("UnsupportedVersion", func(t *testing.T) {
+		encrypted, err := aes256.Encrypt("message", userKey)
+		if err != nil {
+			t.Fatalf("Failed to encrypt: %v", err)
+		}
+		data, _ := base64.StdEncoding.DecodeString(encrypted)
+		data[len(envelopeMagic)] = 99
+		tampered := base64.StdEncoding.EncodeToString(data)
+
+		if _, err := aes256.Decrypt(tampered, userKey); !errors.Is(err, ErrUnsupportedEnvelope) {
+			t.Errorf("Expected ErrUnsupportedEnvelope, got %v", err)
+		}
+		if err := ValidateCiphertext(tampered); !errors.Is(err, ErrUnsupportedEnvelope) {
+			t.Errorf("Expected ErrUnsupportedEnvelope, got %v", err)
+		}
+	})
+
+	t.Run("SupportedAlgorithms", func(t *testing.T) {
+		if !IsSupportedAlgorithm(AlgorithmXChaCha20Poly1305) || IsSupportedAlgorithm("DES") {
+			t.Error("Unexpected supported algorithms")
+		}
+		if _, err := NewService("DES", 1000).Encrypt("message", userKey); err == nil {
+			t.Error("Expected an unsupported algorithm to fail")
+		}
+	})
+
+	t.Run("AssociatedData", func(t *testing.T) {
+		aad := []byte("user/article/content")
+		encrypted, err := aes256.EncryptWithAAD("message", userKey, aad)
+		if err != nil {
+			t.Fatalf("Failed to encrypt: %v", err)
+		}
+		if !HasAssociatedData(encrypted) {
+			t.Error("Expected the ciphertext to be marked as bound")
+		}
+
+		decrypted, err := aes256.DecryptWithAAD(encrypted, userKey, aad)
+		if err != nil || decrypted != "message" {
+			t.Errorf("Failed to decrypt: %q, %v", decrypted, err)
+		}
+		if _, err := aes256.DecryptWithAAD(encrypted, userKey, []byte("user/other/content")); err == nil {
+			t.Error("Expected other associated data to fail")
+		}
+		if _, err := aes256.Decrypt(encrypted, userKey); err == nil {
+			t.Error("Expected decryption without associated data to fail")
+		}
+
+		unbound, _ := aes256.Encrypt("message", userKey)
+		if HasAssociatedData(unbound) {
+			t.Error("Expected the ciphertext to be unbound")
+		}
+		if _, err := aes256.DecryptWithAAD(unbound, userKey, aad); err == nil {
+			t.Error("Expected unbound ciphertext to fail with associated data")
+		}
+	})
+}
+
@@ -0,0 +1,91 @@
+package encryption
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidKey is returned when a user key is malformed or is not the key
+// the user's content is encrypted with
+var ErrInvalidKey = errors.New("invalid encryption key")
+
+const (
+	keyCheckInfo    = "readitlater key check v1"
+	keyCheckMessage = "readitlater key check"
+)
+
+// NewKeyCheck returns a verifier recognising a user key without revealing
+// it: an HMAC of a fixed message under a subkey derived from the user key
+// for this purpose only. Store it when the user first encrypts content.
+func NewKeyCheck(userKey, userID string) (string, error) {
+	mac, err := keyCheckMAC(userKey, userID)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(mac), nil
+}
+
+// VerifyKeyCheck checks a user key against a verifier from NewKeyCheck and
+// returns ErrInvalidKey when they do not match
+func VerifyKeyCheck(check, userKey, userID string) error {
+	expected, err := base64.StdEncoding.DecodeString(check)
+	if err != nil {
+		return fmt.Errorf("failed to decode key check: %w", err)
+	}
+
+	mac, err := keyCheckMAC(userKey, userID)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, expected) {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// MatchKeyCheck compares a key check computed by a zero-knowledge client,
+// which never sends its key, with the recorded verifier and returns
+// ErrInvalidKey when they do not match
+func MatchKeyCheck(check, verifier string) error {
+	if err := ValidateKeyCheck(check); err != nil {
+		return err
+	}
+	mac, _ := base64.StdEncoding.DecodeString(check)
+	expected, err := base64.StdEncoding.DecodeString(verifier)
+	if err != nil {
+		return fmt.Errorf("failed to decode key check: %w", err)
+	}
+	if !hmac.Equal(mac, expected) {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// ValidateKeyCheck checks that a key check has the shape NewKeyCheck
+// produces, and returns ErrInvalidKey when it does not
+func ValidateKeyCheck(check string) error {
+	mac, err := base64.StdEncoding.DecodeString(check)
+	if err != nil || len(mac) != sha256.Size {
+		return fmt.Errorf("%w: malformed key check", ErrInvalidKey)
+	}
+	return nil
+}
+
+// keyCheckMAC computes the key check of a user key
+func keyCheckMAC(userKey, userID string) ([]byte, error) {
+	secret, err := decodeUserKey(userKey)
+	if err != nil {
+		return nil, err
+	}
+	subkey, err := DeriveSubkey(secret, []byte(userID), keyCheckInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, subkey)
+	mac.Write([]byte(keyCheckMessage))
+	return mac.Sum(nil), nil
+}
@@ -0,0 +1,41 @@
+package encryption
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKeyCheck(t *testing.T) {
+	service := NewService(AlgorithmAES256GCM, 1000)
+	userKey, _ := service.GenerateUserKey()
+	otherKey, _ := service.GenerateUserKey()
+
+	check, err := NewKeyCheck(userKey, "user")
+	if err != nil {
+		t.Fatalf("Failed to create key check: %v", err)
+	}
+
+	if err := VerifyKeyCheck(check, userKey, "user"); err != nil {
+		t.Errorf("Expected the key to match: %v", err)
+	}
+	if err := VerifyKeyCheck(check, otherKey, "user"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected ErrInvalidKey for another key, got %v", err)
+	}
+	if err := VerifyKeyCheck(check, userKey, "other"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected ErrInvalidKey for another user, got %v", err)
+	}
+	if err := VerifyKeyCheck(check, "not a key", "user"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected ErrInvalidKey for a malformed key, got %v", err)
+	}
+
+	otherCheck, _ := NewKeyCheck(otherKey, "user")
+	if err := MatchKeyCheck(check, check); err != nil {
+		t.Errorf("Expected the key checks to match: %v", err)
+	}
+	if err := MatchKeyCheck(otherCheck, check); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected ErrInvalidKey for another key check, got %v", err)
+	}
+	if err := MatchKeyCheck("bm90IGEgY2hlY2s=", check); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected ErrInvalidKey for a malformed key check, got %v", err)
+	}
+}
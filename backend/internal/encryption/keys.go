@@ -0,0 +1,23 @@
+package encryption
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// KeySize is the size in bytes of raw keys, such as data keys
+const KeySize = 32
+
+// DeriveSubkey derives an independent 256-bit key from a high-entropy secret
+// with HKDF-SHA256. Different info strings yield unrelated keys, so one
+// secret can back several purposes.
+func DeriveSubkey(secret, salt []byte, info string) ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,218 @@
+package encryption
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Key recovery. A user key can be wrapped by a printable recovery key held
+// by the user and, optionally, sealed to an organization escrow public key
+// whose private key is kept offline by administrators:
+//
+//	recovery key ──HKDF──▶ recovery KEK ──wraps──▶ user key
+//	escrow public key ──X25519──▶ escrow KEK ──wraps──▶ user key
+//
+// The user key is the root of the user's key-encryption key, so recovering
+// it restores access to every article. The user key is wrapped rather than
+// the KEK because every API that reads or writes encrypted content, and
+// the key rotation that follows a recovery, takes the user key.
+const (
+	recoveryKEKInfo = "readitlater recovery kek v1"
+	escrowKEKInfo   = "readitlater escrow kek v1"
+
+	// recoveryGroupSize is the number of characters per group of a printed
+	// recovery key
+	recoveryGroupSize = 4
+)
+
+// ErrInvalidRecoveryKey is returned when a recovery key is malformed or does
+// not open the wrapped user key
+var ErrInvalidRecoveryKey = errors.New("invalid recovery key")
+
+// recoveryEncoding encodes recovery keys with letters and digits that are
+// hard to confuse when written down
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateRecoveryKey generates a random 256-bit recovery key formatted for
+// printing as dash-separated groups of base32 characters
+func GenerateRecoveryKey() (string, error) {
+	secret := make([]byte, KeySize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate recovery key: %w", err)
+	}
+
+	encoded := recoveryEncoding.EncodeToString(secret)
+	groups := make([]string, 0, len(encoded)/recoveryGroupSize+1)
+	for len(encoded) > recoveryGroupSize {
+		groups = append(groups, encoded[:recoveryGroupSize])
+		encoded = encoded[recoveryGroupSize:]
+	}
+	groups = append(groups, encoded)
+	return strings.Join(groups, "-"), nil
+}
+
+// parseRecoveryKey decodes a printed recovery key, ignoring case, spaces
+// and dashes
+func parseRecoveryKey(recoveryKey string) ([]byte, error) {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(recoveryKey))
+	secret, err := recoveryEncoding.DecodeString(normalized)
+	if err != nil || len(secret) != KeySize {
+		return nil, ErrInvalidRecoveryKey
+	}
+	return secret, nil
+}
+
+// WrapForRecovery encrypts a user key under a recovery key
+func (s *Service) WrapForRecovery(userKey, recoveryKey, userID string) (string, error) {
+	secret, err := decodeUserKey(userKey)
+	if err != nil {
+		return "", err
+	}
+	kek, err := recoveryKEK(recoveryKey, userID)
+	if err != nil {
+		return "", err
+	}
+	return s.sealWithKey(kek, secret, recoveryAAD(userID))
+}
+
+// UnwrapWithRecovery returns the user key wrapped by WrapForRecovery, or
+// ErrInvalidRecoveryKey when the recovery key does not open it
+func UnwrapWithRecovery(wrapped, recoveryKey, userID string) (string, error) {
+	kek, err := recoveryKEK(recoveryKey, userID)
+	if err != nil {
+		return "", err
+	}
+	secret, err := openWithKey(kek, wrapped, recoveryAAD(userID))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidRecoveryKey, err)
+	}
+	return base64.StdEncoding.EncodeToString(secret), nil
+}
+
+// recoveryKEK derives the key wrapping a user key from a recovery key
+func recoveryKEK(recoveryKey, userID string) ([]byte, error) {
+	secret, err := parseRecoveryKey(recoveryKey)
+	if err != nil {
+		return nil, err
+	}
+	return DeriveSubkey(secret, []byte(userID), recoveryKEKInfo)
+}
+
+// recoveryAAD binds a recovered user key to its user
+func recoveryAAD(userID string) []byte {
+	return []byte(userID + "/recovery")
+}
+
+// GenerateEscrowKeyPair generates a base64 X25519 key pair for organization
+// escrow. Only the public key is given to the server.
+func GenerateEscrowKeyPair() (publicKey, privateKey string, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate escrow key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()),
+		base64.StdEncoding.EncodeToString(key.Bytes()), nil
+}
+
+// ParseEscrowPublicKey checks a base64 X25519 escrow public key
+func ParseEscrowPublicKey(publicKey string) (*ecdh.PublicKey, error) {
+	data, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode escrow public key: %w", err)
+	}
+	return ecdh.X25519().NewPublicKey(data)
+}
+
+// EscrowKeyID returns a short fingerprint identifying an escrow public key
+func EscrowKeyID(publicKey string) (string, error) {
+	key, err := ParseEscrowPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(key.Bytes())
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// SealForEscrow encrypts a user key to an escrow public key with an
+// ephemeral X25519 key agreement. The result is the base64 ephemeral public
+// key and the envelope, separated by a dot.
+func (s *Service) SealForEscrow(userKey, escrowPublicKey, userID string) (string, error) {
+	secret, err := decodeUserKey(userKey)
+	if err != nil {
+		return "", err
+	}
+	recipient, err := ParseEscrowPublicKey(escrowPublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	kek, err := escrowKEK(ephemeral, recipient, ephemeral.PublicKey(), userID)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := s.sealWithKey(kek, secret, escrowAAD(userID))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ephemeral.PublicKey().Bytes()) + "." + sealed, nil
+}
+
+// OpenEscrow decrypts a user key sealed by SealForEscrow with the escrow
+// private key
+func OpenEscrow(sealed, escrowPrivateKey, userID string) (string, error) {
+	ephemeralPart, envelopePart, found := strings.Cut(sealed, ".")
+	if !found {
+		return "", fmt.Errorf("invalid escrowed key")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(escrowPrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode escrow private key: %w", err)
+	}
+	private, err := ecdh.X25519().NewPrivateKey(data)
+	if err != nil {
+		return "", fmt.Errorf("invalid escrow private key: %w", err)
+	}
+	ephemeral, err := ParseEscrowPublicKey(ephemeralPart)
+	if err != nil {
+		return "", err
+	}
+
+	kek, err := escrowKEK(private, ephemeral, ephemeral, userID)
+	if err != nil {
+		return "", err
+	}
+	secret, err := openWithKey(kek, envelopePart, escrowAAD(userID))
+	if err != nil {
+		return "", fmt.Errorf("failed to open escrowed key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(secret), nil
+}
+
+// escrowKEK derives the key wrapping an escrowed user key from an X25519
+// key agreement, bound to the ephemeral public key and the user
+func escrowKEK(private *ecdh.PrivateKey, peer, ephemeral *ecdh.PublicKey, userID string) ([]byte, error) {
+	shared, err := private.ECDH(peer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to agree on escrow key: %w", err)
+	}
+	salt := append(ephemeral.Bytes(), []byte(userID)...)
+	return DeriveSubkey(shared, salt, escrowKEKInfo)
+}
+
+// escrowAAD binds an escrowed user key to its user
+func escrowAAD(userID string) []byte {
+	return []byte(userID + "/escrow")
+}
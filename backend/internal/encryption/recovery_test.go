@@ -0,0 +1,65 @@
+package encryption
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRecovery(t *testing.T) {
+	service := NewService(AlgorithmAES256GCM, 1000)
+	userKey, _ := service.GenerateUserKey()
+
+	t.Run("RecoveryKey", func(t *testing.T) {
+		recoveryKey, err := GenerateRecoveryKey()
+		if err != nil {
+			t.Fatalf("Failed to generate recovery key: %v", err)
+		}
+		wrapped, err := service.WrapForRecovery(userKey, recoveryKey, "user")
+		if err != nil {
+			t.Fatalf("Failed to wrap user key: %v", err)
+		}
+
+		// Printed keys are accepted without dashes and in lower case
+		typed := strings.ToLower(strings.ReplaceAll(recoveryKey, "-", " "))
+		recovered, err := UnwrapWithRecovery(wrapped, typed, "user")
+		if err != nil || recovered != userKey {
+			t.Errorf("Failed to recover user key: %q, %v", recovered, err)
+		}
+
+		otherKey, _ := GenerateRecoveryKey()
+		if _, err := UnwrapWithRecovery(wrapped, otherKey, "user"); !errors.Is(err, ErrInvalidRecoveryKey) {
+			t.Errorf("Expected ErrInvalidRecoveryKey for another recovery key, got %v", err)
+		}
+		if _, err := UnwrapWithRecovery(wrapped, recoveryKey, "other"); !errors.Is(err, ErrInvalidRecoveryKey) {
+			t.Errorf("Expected ErrInvalidRecoveryKey for another user, got %v", err)
+		}
+		if _, err := UnwrapWithRecovery(wrapped, "not-a-key", "user"); !errors.Is(err, ErrInvalidRecoveryKey) {
+			t.Errorf("Expected ErrInvalidRecoveryKey for a malformed key, got %v", err)
+		}
+	})
+
+	t.Run("Escrow", func(t *testing.T) {
+		publicKey, privateKey, err := GenerateEscrowKeyPair()
+		if err != nil {
+			t.Fatalf("Failed to generate escrow key pair: %v", err)
+		}
+		sealed, err := service.SealForEscrow(userKey, publicKey, "user")
+		if err != nil {
+			t.Fatalf("Failed to seal for escrow: %v", err)
+		}
+
+		recovered, err := OpenEscrow(sealed, privateKey, "user")
+		if err != nil || recovered != userKey {
+			t.Errorf("Failed to open escrowed key: %q, %v", recovered, err)
+		}
+		if _, err := OpenEscrow(sealed, privateKey, "other"); err == nil {
+			t.Error("Expected an escrowed key to be bound to its user")
+		}
+
+		_, otherPrivate, _ := GenerateEscrowKeyPair()
+		if _, err := OpenEscrow(sealed, otherPrivate, "user"); err == nil {
+			t.Error("Expected another private key to fail")
+		}
+	})
+}
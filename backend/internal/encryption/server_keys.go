@@ -0,0 +1,52 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// Server-managed encryption. Users who don't manage a key of their own get
+// a random server key, generated like a user key, that takes its place in
+// the key hierarchy. It is stored wrapped by a server KEK derived from the
+// master key (ENCRYPTION_KEY), so rotating the master key only rewraps one
+// small key per user. Unlike user keys, server keys are available to the server,
+// which protects content at rest but is not zero-knowledge.
+const masterKeyIDInfo = "readitlater master key id v1"
+
+// MasterKeyID returns a short public identifier of a master key, recorded
+// with every key wrapped by it so rotation knows which keys to rewrap
+func MasterKeyID(masterKey string) (string, error) {
+	id, err := DeriveSubkey([]byte(masterKey), nil, masterKeyIDInfo)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:8]), nil
+}
+
+// WrapServerKey encrypts a user's server key under the master key
+func (s *Service) WrapServerKey(masterKey, userID, serverKey string) (string, error) {
+	secret, err := decodeUserKey(serverKey)
+	if err != nil {
+		return "", err
+	}
+	kek, err := DeriveServerKEK(masterKey, userID)
+	if err != nil {
+		return "", err
+	}
+	return s.sealWithKey(kek, secret, []byte(userID))
+}
+
+// UnwrapServerKey decrypts a server key wrapped by WrapServerKey. A master
+// key that does not open it is reported as ErrInvalidKey.
+func (s *Service) UnwrapServerKey(masterKey, userID, wrapped string) (string, error) {
+	kek, err := DeriveServerKEK(masterKey, userID)
+	if err != nil {
+		return "", err
+	}
+	secret, err := openWithKey(kek, wrapped, []byte(userID))
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to unwrap server key: %v", ErrInvalidKey, err)
+	}
+	return base64.StdEncoding.EncodeToString(secret), nil
+}
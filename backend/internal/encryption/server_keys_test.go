@@ -0,0 +1,35 @@
+package encryption
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestServerKeys(t *testing.T) {
+	service := NewService(AlgorithmAES256GCM, 1000)
+	masterKey := "test-master-key-with-32-characters"
+	serverKey, _ := service.GenerateUserKey()
+
+	wrapped, err := service.WrapServerKey(masterKey, "user", serverKey)
+	if err != nil {
+		t.Fatalf("Failed to wrap server key: %v", err)
+	}
+
+	unwrapped, err := service.UnwrapServerKey(masterKey, "user", wrapped)
+	if err != nil || unwrapped != serverKey {
+		t.Errorf("Expected the server key back, got %q, %v", unwrapped, err)
+	}
+	if _, err := service.UnwrapServerKey("another-master-key-with-32-chars!!", "user", wrapped); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected ErrInvalidKey for another master key, got %v", err)
+	}
+	if _, err := service.UnwrapServerKey(masterKey, "other", wrapped); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected ErrInvalidKey for another user, got %v", err)
+	}
+
+	id, _ := MasterKeyID(masterKey)
+	again, _ := MasterKeyID(masterKey)
+	other, _ := MasterKeyID("another-master-key-with-32-chars!!")
+	if id != again || id == other || len(id) != 16 {
+		t.Errorf("Unexpected master key IDs: %q, %q, %q", id, again, other)
+	}
+}
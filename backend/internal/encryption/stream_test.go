@@ -0,0 +1,193 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	service := NewService(AlgorithmAES256GCM, 1000)
+	userKey, err := service.GenerateUserKey()
+	if err != nil {
+		t.Fatalf("Failed to generate user key: %v", err)
+	}
+	key, _ := GenerateDataKey()
+	aad := []byte("user/article/asset/file.pdf")
+
+	encrypt := func(t *testing.T, service *Service, plaintext []byte) []byte {
+		var buf bytes.Buffer
+		w, err := service.EncryptStreamWithKey(&buf, key, aad)
+		if err != nil {
+			t.Fatalf("Failed to create stream: %v", err)
+		}
+		// Odd write sizes so writes straddle chunk boundaries
+		for data := plaintext; len(data) > 0; {
+			n := 1000
+			if n > len(data) {
+				n = len(data)
+			}
+			if _, err := w.Write(data[:n]); err != nil {
+				t.Fatalf("Failed to write: %v", err)
+			}
+			data = data[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Failed to close stream: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	decrypt := func(service *Service, stream []byte) ([]byte, error) {
+		r, err := service.DecryptStreamWithKey(bytes.NewReader(stream), key, aad)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		sizes := []int{0, 1, DefaultChunkSize - 1, DefaultChunkSize, DefaultChunkSize + 1, 3 * DefaultChunkSize, 3*DefaultChunkSize + 12345}
+		for _, algorithm := range []string{AlgorithmAES256GCM, AlgorithmXChaCha20Poly1305} {
+			service := NewService(algorithm, 1000)
+			for _, size := range sizes {
+				plaintext := make([]byte, size)
+				rand.Read(plaintext)
+
+				stream := encrypt(t, service, plaintext)
+				if expected, _ := service.StreamSize(int64(size)); int64(len(stream)) != expected {
+					t.Errorf("Expected %s stream of %d bytes to be %d bytes, got %d", algorithm, size, expected, len(stream))
+				}
+
+				decrypted, err := decrypt(service, stream)
+				if err != nil {
+					t.Fatalf("Failed to decrypt %s stream of %d bytes: %v", algorithm, size, err)
+				}
+				if !bytes.Equal(decrypted, plaintext) {
+					t.Errorf("Decrypted %s stream of %d bytes doesn't match", algorithm, size)
+				}
+			}
+		}
+	})
+
+	t.Run("Truncation", func(t *testing.T) {
+		plaintext := make([]byte, 2*DefaultChunkSize)
+		stream := encrypt(t, service, plaintext)
+		sealedSize := DefaultChunkSize + aeadOverhead
+		headerSize := len(stream) - 2*sealedSize - aeadOverhead
+
+		// Cut at a chunk boundary, inside a chunk, and right after the header
+		for _, size := range []int{len(stream) - aeadOverhead, headerSize + sealedSize, len(stream) - 100, headerSize} {
+			if _, err := decrypt(service, stream[:size]); err == nil {
+				t.Errorf("Expected a stream truncated to %d bytes to fail", size)
+			}
+		}
+	})
+
+	t.Run("Reorder", func(t *testing.T) {
+		plaintext := make([]byte, 3*DefaultChunkSize)
+		stream := encrypt(t, service, plaintext)
+		sealedSize := DefaultChunkSize + aeadOverhead
+		headerSize := len(stream) - 3*sealedSize - aeadOverhead
+
+		reordered := append([]byte{}, stream[:headerSize]...)
+		reordered = append(reordered, stream[headerSize+sealedSize:headerSize+2*sealedSize]...)
+		reordered = append(reordered, stream[headerSize:headerSize+sealedSize]...)
+		reordered = append(reordered, stream[headerSize+2*sealedSize:]...)
+		if _, err := decrypt(service, reordered); err == nil {
+			t.Error("Expected reordered chunks to fail")
+		}
+	})
+
+	t.Run("AssociatedData", func(t *testing.T) {
+		stream := encrypt(t, service, []byte("content"))
+		r, err := service.DecryptStreamWithKey(bytes.NewReader(stream), key, []byte("user/other/asset/file.pdf"))
+		if err != nil {
+			t.Fatalf("Failed to open stream: %v", err)
+		}
+		if _, err := io.ReadAll(r); err == nil {
+			t.Error("Expected other associated data to fail")
+		}
+	})
+
+	t.Run("ReaderAt", func(t *testing.T) {
+		plaintext := make([]byte, 2*DefaultChunkSize+500)
+		rand.Read(plaintext)
+		stream := encrypt(t, service, plaintext)
+
+		r, err := service.NewStreamReaderAt(bytes.NewReader(stream), int64(len(stream)), key, aad)
+		if err != nil {
+			t.Fatalf("Failed to open stream: %v", err)
+		}
+		if r.Size() != int64(len(plaintext)) {
+			t.Errorf("Expected size %d, got %d", len(plaintext), r.Size())
+		}
+
+		ranges := [][2]int{{0, 10}, {DefaultChunkSize - 5, 10}, {100, 2 * DefaultChunkSize}, {len(plaintext) - 20, 20}}
+		for _, rng := range ranges {
+			buf := make([]byte, rng[1])
+			if _, err := r.ReadAt(buf, int64(rng[0])); err != nil {
+				t.Fatalf("Failed to read %d bytes at %d: %v", rng[1], rng[0], err)
+			}
+			if !bytes.Equal(buf, plaintext[rng[0]:rng[0]+rng[1]]) {
+				t.Errorf("Unexpected content for %d bytes at %d", rng[1], rng[0])
+			}
+		}
+
+		buf := make([]byte, 100)
+		n, err := r.ReadAt(buf, int64(len(plaintext)-50))
+		if n != 50 || err != io.EOF {
+			t.Errorf("Expected 50 bytes and io.EOF past the end, got %d, %v", n, err)
+		}
+
+		// A stream missing its final chunk parses, but its last chunk was
+		// not sealed as final
+		truncated := stream[:len(stream)-500-aeadOverhead]
+		r, err = service.NewStreamReaderAt(bytes.NewReader(truncated), int64(len(truncated)), key, aad)
+		if err != nil {
+			t.Fatalf("Failed to open stream: %v", err)
+		}
+		if _, err := r.ReadAt(buf, int64(DefaultChunkSize)); err == nil {
+			t.Error("Expected the truncated last chunk to fail")
+		}
+	})
+
+	t.Run("File", func(t *testing.T) {
+		plaintext := make([]byte, DefaultChunkSize+1)
+		rand.Read(plaintext)
+
+		var encrypted, decrypted bytes.Buffer
+		if err := service.EncryptFile(&encrypted, bytes.NewReader(plaintext), userKey); err != nil {
+			t.Fatalf("Failed to encrypt file: %v", err)
+		}
+		if err := service.DecryptFile(&decrypted, &encrypted, userKey); err != nil {
+			t.Fatalf("Failed to decrypt file: %v", err)
+		}
+		if !bytes.Equal(decrypted.Bytes(), plaintext) {
+			t.Error("Decrypted file doesn't match")
+		}
+
+		// Files encrypted whole before streaming
+		legacy, err := service.Encrypt("legacy file", userKey)
+		if err != nil {
+			t.Fatalf("Failed to encrypt: %v", err)
+		}
+		decrypted.Reset()
+		if err := service.DecryptFile(&decrypted, bytes.NewReader([]byte(legacy)), userKey); err != nil {
+			t.Fatalf("Failed to decrypt legacy file: %v", err)
+		}
+		if decrypted.String() != "legacy file" {
+			t.Errorf("Expected legacy file content, got %q", decrypted.String())
+		}
+
+		other, _ := service.GenerateUserKey()
+		decrypted.Reset()
+		if err := service.EncryptFile(&encrypted, bytes.NewReader(plaintext), userKey); err != nil {
+			t.Fatalf("Failed to encrypt file: %v", err)
+		}
+		if err := service.DecryptFile(&decrypted, &encrypted, other); err == nil {
+			t.Error("Expected the wrong key to fail")
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/scheduler"
+	"github.com/readitlater/backend/internal/services"
+)
+
+// AdminHandler handles maintenance endpoints restricted to administrators
+type AdminHandler struct {
+	fsckService   *services.FsckService
+	jobRunService *services.JobRunService
+	scheduler     *scheduler.Scheduler
+	logger        *logrus.Logger
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(fsckService *services.FsckService, jobRunService *services.JobRunService, scheduler *scheduler.Scheduler, logger *logrus.Logger) *AdminHandler {
+	return &AdminHandler{
+		fsckService:   fsckService,
+		jobRunService: jobRunService,
+		scheduler:     scheduler,
+		logger:        logger,
+	}
+}
+
+// RunStorageCheck cross-checks stored content against the articles table.
+// Repairs and file actions are opt-in and can be previewed with dry_run.
+func (h *AdminHandler) RunStorageCheck(c *gin.Context) {
+	var options models.FsckOptions
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&options); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if !models.IsValidFsckFiles(options.Files) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "files must be quarantine or delete"})
+		return
+	}
+
+	report, err := h.fsckService.Run(&options)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to run storage check")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run storage check"})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"admin":  c.GetString("user_email"),
+		"issues": len(report.Issues),
+		"repair": options.Repair,
+		"files":  options.Files,
+	}).Info("Storage check run by admin")
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetJobs returns the background jobs with their next run, last run and
+// last error
+func (h *AdminHandler) GetJobs(c *gin.Context) {
+	statuses, err := h.jobRunService.GetJobStatuses(h.scheduler.Jobs())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get job statuses")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": statuses})
+}
+
+// GetJobRuns returns the run history of a background job
+func (h *AdminHandler) GetJobRuns(c *gin.Context) {
+	name := c.Param("name")
+
+	known := false
+	for _, job := range h.scheduler.Jobs() {
+		if job.Name == name {
+			known = true
+			break
+		}
+	}
+	if !known {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+
+	runs, err := h.jobRunService.GetJobRuns(name, limit)
+	if err != nil {
+		h.logger.WithError(err).WithField("job", name).Error("Failed to get job runs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
@@ -1,25 +1,42 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/encryption"
 	"github.com/readitlater/backend/internal/models"
 	"github.com/readitlater/backend/internal/services"
+	"github.com/readitlater/backend/internal/storage"
+)
+
+// Pagination and suggestion limits for list endpoints
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	maxSuggestions  = 50
 )
 
 // ArticleHandler handles article-related endpoints
 type ArticleHandler struct {
-	articleService *services.ArticleService
-	logger         *logrus.Logger
+	articleService   *services.ArticleService
+	smartListService *services.SmartListService
+	logger           *logrus.Logger
 }
 
 // NewArticleHandler creates a new article handler
-func NewArticleHandler(articleService *services.ArticleService, logger *logrus.Logger) *ArticleHandler {
+func NewArticleHandler(articleService *services.ArticleService, smartListService *services.SmartListService, logger *logrus.Logger) *ArticleHandler {
 	return &ArticleHandler{
-		articleService: articleService,
-		logger:         logger,
+		articleService:   articleService,
+		smartListService: smartListService,
+		logger:           logger,
 	}
 }
 
@@ -31,10 +48,28 @@ func (h *ArticleHandler) GetArticles(c *gin.Context) {
 		return
 	}
 
-	// Placeholder implementation
+	filter, ok := h.articleFilter(c, userID)
+	if !ok {
+		return
+	}
+
+	articles, err := h.articleService.FindArticles(filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get articles")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get articles"})
+		return
+	}
+
+	total, err := h.articleService.CountArticles(filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to count articles")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get articles"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"articles": []gin.H{},
-		"total":    0,
+		"articles": toArticleResponses(articles),
+		"total":    total,
 	})
 }
 
@@ -52,11 +87,36 @@ func (h *ArticleHandler) CreateArticle(c *gin.Context) {
 		return
 	}
 
-	// Placeholder implementation
-	c.JSON(http.StatusCreated, gin.H{
-		"id":  "placeholder-article-id",
-		"url": req.URL,
-	})
+	article, err := h.articleService.CreateArticle(userID, &req)
+	switch {
+	case errors.Is(err, encryption.ErrInvalidKey):
+		invalidEncryptionKey(c)
+		return
+	case errors.Is(err, services.ErrFullPrivacyRequiresKey), errors.Is(err, services.ErrInvalidEncryptedContent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrArticleIDConflict), errors.Is(err, services.ErrKeyNotVerified):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrStorageQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, storage.ErrInsufficientStorage):
+		h.logger.WithError(err).Error("Storage is full")
+		c.JSON(http.StatusInsufficientStorage, gin.H{"error": "Server storage is full, try again later"})
+		return
+	case err != nil:
+		h.logger.WithError(err).Error("Failed to create article")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create article"})
+		return
+	}
+
+	// Tell the client when the user is close to their quota
+	if usage, err := h.articleService.GetStorageUsage(userID); err == nil && usage.Warning > 0 {
+		c.Header("X-Storage-Warning", fmt.Sprintf("%.0f%% of storage quota used", usage.Percent))
+	}
+
+	c.JSON(http.StatusCreated, article.ToResponse())
 }
 
 // GetArticle retrieves a specific article
@@ -117,7 +177,9 @@ func (h *ArticleHandler) DeleteArticle(c *gin.Context) {
 	})
 }
 
-// GetArticleContent retrieves the full content of an article
+// GetArticleContent retrieves the full content of an article. Content
+// encrypted under a user key is returned as ciphertext for the client to
+// decrypt.
 func (h *ArticleHandler) GetArticleContent(c *gin.Context) {
 	userID := c.GetString("user_id")
 	articleID := c.Param("id")
@@ -127,11 +189,21 @@ func (h *ArticleHandler) GetArticleContent(c *gin.Context) {
 		return
 	}
 
-	// Placeholder implementation
-	c.JSON(http.StatusOK, gin.H{
-		"id":      articleID,
-		"content": "<p>Placeholder article content</p>",
-	})
+	content, err := h.articleService.GetArticleContent(userID, articleID)
+	switch {
+	case errors.Is(err, services.ErrArticleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		return
+	case errors.Is(err, storage.ErrContentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Article has no stored content"})
+		return
+	case err != nil:
+		h.logger.WithError(err).Error("Failed to get article content")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get article content"})
+		return
+	}
+
+	c.JSON(http.StatusOK, content)
 }
 
 // MarkAsRead marks an article as read
@@ -193,13 +265,69 @@ func (h *ArticleHandler) SearchArticles(c *gin.Context) {
 		return
 	}
 
+	filter, ok := h.articleFilter(c, userID)
+	if !ok {
+		return
+	}
+
+	// A smart list's own query is used when no query is given
 	query := c.Query("q")
-	
-	// Placeholder implementation
+	if query == "" {
+		query = filter.Search
+	}
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+
+	if mode := c.Query("mode"); mode != "" {
+		filter.SearchMode = mode
+	}
+	if filter.SearchMode == "" {
+		filter.SearchMode = models.SearchModeFullText
+	}
+	if !models.IsValidSearchMode(filter.SearchMode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search mode"})
+		return
+	}
+
+	// All facets are computed unless the client picks some with facets=tag,domain
+	// or disables them with an empty facets parameter
+	facets := models.AllFacets
+	if value, ok := c.GetQuery("facets"); ok {
+		facets = []string{}
+		for _, facet := range strings.Split(value, ",") {
+			if facet = strings.TrimSpace(facet); facet == "" {
+				continue
+			}
+			if !models.IsValidFacet(facet) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid facet: " + facet})
+				return
+			}
+			facets = append(facets, facet)
+		}
+	}
+
+	articles, err := h.articleService.SearchArticles(userID, query, filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to search articles")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search articles"})
+		return
+	}
+
+	facetResult, err := h.articleService.GetSearchFacets(userID, query, filter, facets)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to compute search facets")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search articles"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"query":    query,
-		"articles": []gin.H{},
-		"total":    0,
+		"mode":     filter.SearchMode,
+		"articles": toArticleResponses(articles),
+		"total":    facetResult.Total,
+		"facets":   facetResult.Facets,
 	})
 }
 
@@ -211,9 +339,21 @@ func (h *ArticleHandler) GetSearchSuggestions(c *gin.Context) {
 		return
 	}
 
-	// Placeholder implementation
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > maxSuggestions {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	suggestions, err := h.articleService.GetSearchSuggestions(userID, c.Query("q"), limit)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get search suggestions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get search suggestions"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"suggestions": []string{},
+		"suggestions": suggestions,
 	})
 }
 
@@ -225,8 +365,29 @@ func (h *ArticleHandler) ExportArticles(c *gin.Context) {
 		return
 	}
 
-	// Placeholder implementation
-	c.JSON(http.StatusOK, gin.H{"message": "Export functionality not implemented yet"})
+	filter, ok := h.articleFilter(c, userID)
+	if !ok {
+		return
+	}
+
+	// Export everything matching the filter unless a page was requested
+	if c.Query("limit") == "" {
+		filter.Limit = 0
+	}
+
+	articles, err := h.articleService.FindArticles(filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to export articles")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export articles"})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=articles.json")
+	c.JSON(http.StatusOK, gin.H{
+		"exported_at": time.Now().UTC(),
+		"articles":    toArticleResponses(articles),
+		"total":       len(articles),
+	})
 }
 
 // ImportArticles imports articles for the user
@@ -239,4 +400,132 @@ func (h *ArticleHandler) ImportArticles(c *gin.Context) {
 
 	// Placeholder implementation
 	c.JSON(http.StatusOK, gin.H{"message": "Import functionality not implemented yet"})
-} 
\ No newline at end of file
+} 
+
+// articleFilter parses the article filter of a request, replacing it with the
+// filter of a smart list when list=<id> is given. It writes the error response
+// and returns false if the filter is invalid.
+func (h *ArticleHandler) articleFilter(c *gin.Context, userID string) (*models.ArticleFilter, bool) {
+	filter, err := parseArticleFilter(c, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	listID := c.Query("list")
+	if listID == "" {
+		return filter, true
+	}
+
+	filter, err = h.smartListService.ResolveFilter(userID, listID, filter)
+	if errors.Is(err, services.ErrSmartListNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Smart list not found"})
+		return nil, false
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to resolve smart list")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve smart list"})
+		return nil, false
+	}
+
+	return filter, true
+}
+
+// toArticleResponses converts articles to API responses
+func toArticleResponses(articles []*models.Article) []*models.ArticleResponse {
+	responses := make([]*models.ArticleResponse, 0, len(articles))
+	for _, article := range articles {
+		responses = append(responses, article.ToResponse())
+	}
+	return responses
+}
+
+// parseArticleFilter builds an article filter from query parameters
+func parseArticleFilter(c *gin.Context, userID string) (*models.ArticleFilter, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	filter := &models.ArticleFilter{
+		UserID:    userUUID,
+		Status:    c.Query("status"),
+		Category:  c.Query("category"),
+		Domain:    c.Query("domain"),
+		Author:    c.Query("author"),
+		Search:    c.Query("search"),
+		SortBy:    c.Query("sort_by"),
+		SortOrder: c.Query("sort_order"),
+		Limit:     defaultPageSize,
+	}
+
+	if err := filter.ValidateSort(); err != nil {
+		return nil, err
+	}
+
+	for name, target := range map[string]**bool{
+		"is_read":     &filter.IsRead,
+		"is_favorite": &filter.IsFavorite,
+		"is_archived": &filter.IsArchived,
+	} {
+		if value := c.Query(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value", name)
+			}
+			*target = &parsed
+		}
+	}
+
+	if tags := c.Query("tags"); tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				filter.Tags = append(filter.Tags, tag)
+			}
+		}
+	}
+
+	// read_state mirrors the read_state facet buckets
+	switch c.Query("read_state") {
+	case "":
+	case "read", "unread":
+		isRead := c.Query("read_state") == "read"
+		filter.IsRead = &isRead
+	default:
+		return nil, fmt.Errorf("invalid read_state value")
+	}
+
+	if value := c.Query("year"); value != "" {
+		year, err := strconv.Atoi(value)
+		if err != nil || year < 1 {
+			return nil, fmt.Errorf("invalid year")
+		}
+		filter.Year = year
+	}
+
+	if value := c.Query("max_reading_time"); value != "" {
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes < 1 {
+			return nil, fmt.Errorf("invalid max_reading_time")
+		}
+		filter.MaxReadingTime = minutes
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return nil, fmt.Errorf("invalid limit")
+		}
+		filter.Limit = limit
+	}
+
+	if value := c.Query("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("invalid offset")
+		}
+		filter.Offset = offset
+	}
+
+	return filter, nil
+}
\ No newline at end of file
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/readitlater/backend/internal/encryption"
+	"github.com/readitlater/backend/internal/services"
+	"github.com/readitlater/backend/internal/storage"
+)
+
+// userKeyHeader carries the user key for assets of articles encrypted
+// under it. Keys are kept out of URLs so they do not end up in logs.
+const userKeyHeader = "X-User-Key"
+
+// UploadAsset stores the request body as an asset of an article, such as a
+// PDF or an image. The body's Content-Length is required so the asset can
+// be checked against the user's quota before it is written.
+func (h *ArticleHandler) UploadAsset(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if c.Request.ContentLength < 0 {
+		c.JSON(http.StatusLengthRequired, gin.H{"error": "Content-Length is required"})
+		return
+	}
+
+	info, err := h.articleService.SaveAsset(userID, c.Param("id"), c.Param("name"), c.Request.Body, c.Request.ContentLength, c.GetHeader(userKeyHeader))
+	if h.assetError(c, err, "Failed to save asset") {
+		return
+	}
+
+	// Tell the client when the user is close to their quota
+	if usage, err := h.articleService.GetStorageUsage(userID); err == nil && usage.Warning > 0 {
+		c.Header("X-Storage-Warning", fmt.Sprintf("%.0f%% of storage quota used", usage.Percent))
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"name":        c.Param("name"),
+		"stored_size": info.Size,
+	})
+}
+
+// GetAsset returns an asset of an article, decrypted if it is encrypted.
+// Range requests are served by reading only the parts of the asset they
+// cover.
+func (h *ArticleHandler) GetAsset(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	asset, modTime, err := h.articleService.OpenAsset(userID, c.Param("id"), c.Param("name"), c.GetHeader(userKeyHeader))
+	if h.assetError(c, err, "Failed to get asset") {
+		return
+	}
+
+	c.Header("Cache-Control", "private")
+	http.ServeContent(c.Writer, c.Request, c.Param("name"), modTime, asset)
+}
+
+// assetError writes the response for an error from an asset operation and
+// reports whether there was one
+func (h *ArticleHandler) assetError(c *gin.Context, err error, message string) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, services.ErrArticleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+	case errors.Is(err, services.ErrAssetNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+	case errors.Is(err, encryption.ErrInvalidKey):
+		invalidEncryptionKey(c)
+	case errors.Is(err, services.ErrAssetKeyRequired), errors.Is(err, storage.ErrInvalidAssetName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, storage.ErrNoDataKey):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrStorageQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, storage.ErrInsufficientStorage):
+		h.logger.WithError(err).Error("Storage is full")
+		c.JSON(http.StatusInsufficientStorage, gin.H{"error": "Server storage is full, try again later"})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+	return true
+}
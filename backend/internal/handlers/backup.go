@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/services"
+)
+
+// maxBackupHistory is the number of backup runs returned by GetBackups
+const maxBackupHistory = 100
+
+// BackupHandler handles cloud backup endpoints
+type BackupHandler struct {
+	backupService *services.BackupService
+	logger        *logrus.Logger
+}
+
+// NewBackupHandler creates a new backup handler
+func NewBackupHandler(backupService *services.BackupService, logger *logrus.Logger) *BackupHandler {
+	return &BackupHandler{
+		backupService: backupService,
+		logger:        logger,
+	}
+}
+
+// CreateBackup starts a cloud backup of the current user's content
+func (h *BackupHandler) CreateBackup(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	backup, err := h.backupService.StartBackup(userID)
+	if err != nil {
+		h.handleError(c, err, "Failed to start backup")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, backup)
+}
+
+// GetBackups retrieves the current user's backup history
+func (h *BackupHandler) GetBackups(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	backups, err := h.backupService.GetBackups(userID, maxBackupHistory)
+	if err != nil {
+		h.handleError(c, err, "Failed to get backups")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"backups": backups})
+}
+
+// GetBackup retrieves a specific backup run
+func (h *BackupHandler) GetBackup(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	backup, err := h.backupService.GetBackup(userID, c.Param("id"))
+	if err != nil {
+		h.handleError(c, err, "Failed to get backup")
+		return
+	}
+
+	c.JSON(http.StatusOK, backup)
+}
+
+// RestoreBackup restores a backup, or a single article from it
+func (h *BackupHandler) RestoreBackup(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.BackupRestore
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	restored, err := h.backupService.RestoreBackup(userID, c.Param("id"), &req)
+	if err != nil {
+		h.handleError(c, err, "Failed to restore backup")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"restored": restored})
+}
+
+// handleError maps backup service errors to HTTP responses
+func (h *BackupHandler) handleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrBackupDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cloud backup is not enabled"})
+	case errors.Is(err, services.ErrBackupInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": "A backup is already running"})
+	case errors.Is(err, services.ErrBackupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Backup not found"})
+	case errors.Is(err, services.ErrArticleNotInBackup):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Article not found in backup"})
+	case errors.Is(err, services.ErrBackupNotRestorable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Backup is not available for restore"})
+	case errors.Is(err, services.ErrArticleNotRestored):
+		c.JSON(http.StatusConflict, gin.H{"error": "Article was deleted or its encryption changed since the backup"})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/services"
+)
+
+// EncryptedSearchHandler handles search endpoints for encrypted articles
+type EncryptedSearchHandler struct {
+	articleService        *services.ArticleService
+	encryptedIndexService *services.EncryptedIndexService
+	logger                *logrus.Logger
+}
+
+// NewEncryptedSearchHandler creates a new encrypted search handler
+func NewEncryptedSearchHandler(articleService *services.ArticleService, encryptedIndexService *services.EncryptedIndexService, logger *logrus.Logger) *EncryptedSearchHandler {
+	return &EncryptedSearchHandler{
+		articleService:        articleService,
+		encryptedIndexService: encryptedIndexService,
+		logger:                logger,
+	}
+}
+
+// SearchBlindIndex finds encrypted articles whose blind index contains every
+// client-computed token in tokens=<t1,t2,...>
+func (h *EncryptedSearchHandler) SearchBlindIndex(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tokens := []string{}
+	for _, token := range strings.Split(c.Query("tokens"), ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one token is required"})
+		return
+	}
+
+	filter, err := parseArticleFilter(c, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	articles, err := h.articleService.SearchBlindIndex(userID, tokens, filter)
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to search blind index")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"articles": toArticleResponses(articles),
+		"limit":    filter.Limit,
+		"offset":   filter.Offset,
+	})
+}
+
+// GetEncryptedIndex retrieves the latest encrypted search index, or the
+// version given by version=<n>
+func (h *EncryptedSearchHandler) GetEncryptedIndex(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	version := 0
+	if value := c.Query("version"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+			return
+		}
+		version = parsed
+	}
+
+	index, err := h.encryptedIndexService.GetIndex(userID, version)
+	if errors.Is(err, services.ErrEncryptedIndexNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Encrypted search index not found"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get encrypted search index")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get encrypted search index"})
+		return
+	}
+
+	c.JSON(http.StatusOK, index)
+}
+
+// PutEncryptedIndex stores a new version of the encrypted search index
+func (h *EncryptedSearchHandler) PutEncryptedIndex(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.EncryptedSearchIndexUpload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	index, err := h.encryptedIndexService.PutIndex(userID, &req)
+	if errors.Is(err, services.ErrEncryptedIndexConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Encrypted search index was updated by another client"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to store encrypted search index")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, index)
+}
+
+// GetEncryptedIndexVersions lists the stored versions of the encrypted
+// search index
+func (h *EncryptedSearchHandler) GetEncryptedIndexVersions(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	versions, err := h.encryptedIndexService.ListVersions(userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list encrypted search indexes")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list encrypted search indexes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
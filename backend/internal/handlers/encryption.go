@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/encryption"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/services"
+)
+
+// invalidKeyCode is the error code of responses to wrong or malformed
+// encryption keys, so clients can tell them apart from other bad requests
+const invalidKeyCode = "invalid_encryption_key"
+
+// EncryptionHandler handles encryption key endpoints
+type EncryptionHandler struct {
+	articleService *services.ArticleService
+	logger         *logrus.Logger
+}
+
+// NewEncryptionHandler creates a new encryption handler
+func NewEncryptionHandler(articleService *services.ArticleService, logger *logrus.Logger) *EncryptionHandler {
+	return &EncryptionHandler{
+		articleService: articleService,
+		logger:         logger,
+	}
+}
+
+// GetStatus returns the encryption modes the server offers, so clients
+// know whether content saved without a user key is encrypted at rest
+func (h *EncryptionHandler) GetStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.articleService.EncryptionStatus())
+}
+
+// GetMasterKeyUsage returns how many server-managed keys each master key
+// wraps, so admins know when a previous master key can be retired
+func (h *EncryptionHandler) GetMasterKeyUsage(c *gin.Context) {
+	usage, err := h.articleService.MasterKeyUsage()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get master key usage")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get master key usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"master_keys": usage})
+}
+
+// VerifyKey checks that a user key is the one the current user's content is
+// encrypted with
+func (h *EncryptionHandler) VerifyKey(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.EncryptionKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.articleService.VerifyEncryptionKey(userID, req.UserKey)
+	switch {
+	case errors.Is(err, encryption.ErrInvalidKey):
+		invalidEncryptionKey(c)
+		return
+	case errors.Is(err, services.ErrNoEncryptionKey):
+		c.JSON(http.StatusNotFound, gin.H{"error": "No encryption key has been set up"})
+		return
+	case err != nil:
+		h.logger.WithError(err).Error("Failed to verify encryption key")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify encryption key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"valid": true})
+}
+
+// invalidEncryptionKey responds to a request made with the wrong
+// encryption key
+func invalidEncryptionKey(c *gin.Context) {
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid encryption key", "code": invalidKeyCode})
+}
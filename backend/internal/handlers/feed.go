@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/readitlater/backend/internal/models"
+)
+
+// atomFeed is an Atom (RFC 4287) feed of articles
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomLink is a link of a feed or entry
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+// atomEntry is one article in a feed
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Link       atomLink       `xml:"link"`
+	Author     *atomAuthor    `xml:"author,omitempty"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+// atomAuthor is the author of an entry
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// atomCategory is a tag of an entry
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// GetFeed returns the current user's articles as an Atom feed. It takes the
+// same filters as GetArticles, including list=<id> for a smart list.
+// Full-privacy articles are left out, since a feed reader cannot decrypt
+// them.
+func (h *ArticleHandler) GetFeed(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filter, ok := h.articleFilter(c, userID)
+	if !ok {
+		return
+	}
+
+	articles, err := h.articleService.FindArticles(filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get feed articles")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get feed"})
+		return
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	self := scheme + "://" + c.Request.Host + c.Request.URL.RequestURI()
+
+	feed := &atomFeed{
+		ID:      self,
+		Title:   "Saved articles",
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Link:    atomLink{Href: self, Rel: "self"},
+		Entries: []atomEntry{},
+	}
+	var updated time.Time
+	for _, article := range articles {
+		if article.IsPrivate {
+			continue
+		}
+		if article.UpdatedAt.After(updated) {
+			updated = article.UpdatedAt
+		}
+		feed.Entries = append(feed.Entries, toAtomEntry(article))
+	}
+	if !updated.IsZero() {
+		feed.Updated = updated.UTC().Format(time.RFC3339)
+	}
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to encode feed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get feed"})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/atom+xml; charset=utf-8", append([]byte(xml.Header), body...))
+}
+
+// toAtomEntry converts an article to a feed entry
+func toAtomEntry(article *models.Article) atomEntry {
+	entry := atomEntry{
+		ID:        "urn:uuid:" + article.ID.String(),
+		Title:     article.Title,
+		Updated:   article.UpdatedAt.UTC().Format(time.RFC3339),
+		Published: article.CreatedAt.UTC().Format(time.RFC3339),
+		Link:      atomLink{Href: article.URL},
+		Summary:   article.Description,
+	}
+	if entry.Title == "" {
+		entry.Title = article.URL
+	}
+	if article.Author != "" {
+		entry.Author = &atomAuthor{Name: article.Author}
+	}
+	for _, tag := range article.Tags {
+		entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+	}
+	return entry
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/encryption"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/services"
+)
+
+// maxKeyRecoveryEvents is the number of audit events returned by GetEvents
+const maxKeyRecoveryEvents = 100
+
+// KeyRecoveryHandler handles encryption key recovery endpoints
+type KeyRecoveryHandler struct {
+	keyRecoveryService *services.KeyRecoveryService
+	logger             *logrus.Logger
+}
+
+// NewKeyRecoveryHandler creates a new key recovery handler
+func NewKeyRecoveryHandler(keyRecoveryService *services.KeyRecoveryService, logger *logrus.Logger) *KeyRecoveryHandler {
+	return &KeyRecoveryHandler{
+		keyRecoveryService: keyRecoveryService,
+		logger:             logger,
+	}
+}
+
+// SetupRecovery creates a recovery key for the current user's encryption
+// key. The recovery key is only returned in this response.
+func (h *KeyRecoveryHandler) SetupRecovery(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.KeyRecoverySetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	setup, err := h.keyRecoveryService.SetupRecovery(userID, &req, c.GetString("user_email"), c.ClientIP())
+	if err != nil {
+		h.handleError(c, err, "Failed to set up key recovery")
+		return
+	}
+
+	c.JSON(http.StatusCreated, setup)
+}
+
+// GetRecovery retrieves the recovery set up for the current user
+func (h *KeyRecoveryHandler) GetRecovery(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	recovery, err := h.keyRecoveryService.GetRecovery(userID)
+	if err != nil {
+		h.handleError(c, err, "Failed to get key recovery")
+		return
+	}
+
+	c.JSON(http.StatusOK, recovery)
+}
+
+// DeleteRecovery removes the current user's recovery
+func (h *KeyRecoveryHandler) DeleteRecovery(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.keyRecoveryService.DeleteRecovery(userID, c.GetString("user_email"), c.ClientIP()); err != nil {
+		h.handleError(c, err, "Failed to delete key recovery")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Key recovery removed"})
+}
+
+// TestRecovery checks that a recovery key recovers the current user's key
+func (h *KeyRecoveryHandler) TestRecovery(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.KeyRecoveryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recovery, err := h.keyRecoveryService.TestRecovery(userID, req.RecoveryKey, c.GetString("user_email"), c.ClientIP())
+	if err != nil {
+		h.handleError(c, err, "Failed to test key recovery")
+		return
+	}
+
+	c.JSON(http.StatusOK, recovery)
+}
+
+// UseRecovery returns the current user's encryption key recovered with a
+// recovery key
+func (h *KeyRecoveryHandler) UseRecovery(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.KeyRecoveryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userKey, err := h.keyRecoveryService.UseRecovery(userID, req.RecoveryKey, c.GetString("user_email"), c.ClientIP())
+	if err != nil {
+		h.handleError(c, err, "Failed to recover encryption key")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user_key": userKey})
+}
+
+// GetEvents retrieves the current user's key recovery audit log
+func (h *KeyRecoveryHandler) GetEvents(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	events, err := h.keyRecoveryService.GetEvents(userID, maxKeyRecoveryEvents)
+	if err != nil {
+		h.handleError(c, err, "Failed to get key recovery events")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// GetEscrowedKey releases a user's escrowed key to an administrator
+func (h *KeyRecoveryHandler) GetEscrowedKey(c *gin.Context) {
+	escrowed, err := h.keyRecoveryService.GetEscrowedKey(c.Param("id"), c.GetString("user_email"), c.ClientIP())
+	if err != nil {
+		h.handleError(c, err, "Failed to get escrowed key")
+		return
+	}
+
+	c.JSON(http.StatusOK, escrowed)
+}
+
+// handleError maps key recovery service errors to HTTP responses
+func (h *KeyRecoveryHandler) handleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, encryption.ErrInvalidKey):
+		invalidEncryptionKey(c)
+	case errors.Is(err, encryption.ErrInvalidRecoveryKey):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recovery key", "code": "invalid_recovery_key"})
+	case errors.Is(err, services.ErrRecoveryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Key recovery is not set up"})
+	case errors.Is(err, services.ErrKeyNotEscrowed):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Encryption key is not escrowed"})
+	case errors.Is(err, services.ErrRecoveryOutdated):
+		c.JSON(http.StatusConflict, gin.H{"error": "Recovery key wraps a previous encryption key, set up recovery again"})
+	case errors.Is(err, services.ErrEscrowNotConfigured):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Key escrow is not configured on this server"})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/services"
+)
+
+// maxKeyRotationHistory is the number of rotations returned by GetRotations
+const maxKeyRotationHistory = 50
+
+// KeyRotationHandler handles encryption key rotation endpoints
+type KeyRotationHandler struct {
+	keyRotationService *services.KeyRotationService
+	logger             *logrus.Logger
+}
+
+// NewKeyRotationHandler creates a new key rotation handler
+func NewKeyRotationHandler(keyRotationService *services.KeyRotationService, logger *logrus.Logger) *KeyRotationHandler {
+	return &KeyRotationHandler{
+		keyRotationService: keyRotationService,
+		logger:             logger,
+	}
+}
+
+// StartRotation starts rotating the current user's encrypted articles to a
+// new key
+func (h *KeyRotationHandler) StartRotation(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.KeyRotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rotation, err := h.keyRotationService.StartRotation(userID, &req)
+	if err != nil {
+		h.handleError(c, err, "Failed to start key rotation")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, rotation)
+}
+
+// GetRotations retrieves the current user's key rotations
+func (h *KeyRotationHandler) GetRotations(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	rotations, err := h.keyRotationService.GetRotations(userID, maxKeyRotationHistory)
+	if err != nil {
+		h.handleError(c, err, "Failed to get key rotations")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rotations": rotations})
+}
+
+// GetRotation retrieves the progress of a key rotation
+func (h *KeyRotationHandler) GetRotation(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	rotation, err := h.keyRotationService.GetRotation(userID, c.Param("id"))
+	if err != nil {
+		h.handleError(c, err, "Failed to get key rotation")
+		return
+	}
+
+	c.JSON(http.StatusOK, rotation)
+}
+
+// PauseRotation pauses a running key rotation
+func (h *KeyRotationHandler) PauseRotation(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	rotation, err := h.keyRotationService.PauseRotation(userID, c.Param("id"))
+	if err != nil {
+		h.handleError(c, err, "Failed to pause key rotation")
+		return
+	}
+
+	c.JSON(http.StatusOK, rotation)
+}
+
+// ResumeRotation resumes a paused, interrupted or failed key rotation. The
+// keys must be supplied again because they are never stored.
+func (h *KeyRotationHandler) ResumeRotation(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.KeyRotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rotation, err := h.keyRotationService.ResumeRotation(userID, c.Param("id"), &req)
+	if err != nil {
+		h.handleError(c, err, "Failed to resume key rotation")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, rotation)
+}
+
+// handleError maps key rotation service errors to HTTP responses
+func (h *KeyRotationHandler) handleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRotationKeys):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": invalidKeyCode})
+	case errors.Is(err, services.ErrRotationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Key rotation not found"})
+	case errors.Is(err, services.ErrRotationInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": "A key rotation is already in progress"})
+	case errors.Is(err, services.ErrRotationNotRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": "Key rotation is not running"})
+	case errors.Is(err, services.ErrRotationNotResumable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Key rotation cannot be resumed"})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
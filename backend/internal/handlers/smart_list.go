@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/services"
+)
+
+// SmartListHandler handles saved search endpoints
+type SmartListHandler struct {
+	smartListService *services.SmartListService
+	logger           *logrus.Logger
+}
+
+// NewSmartListHandler creates a new smart list handler
+func NewSmartListHandler(smartListService *services.SmartListService, logger *logrus.Logger) *SmartListHandler {
+	return &SmartListHandler{
+		smartListService: smartListService,
+		logger:           logger,
+	}
+}
+
+// GetSmartLists retrieves the current user's smart lists with live counts
+func (h *SmartListHandler) GetSmartLists(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	lists, err := h.smartListService.GetSmartLists(userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get smart lists")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get smart lists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"lists": lists})
+}
+
+// CreateSmartList creates a new smart list
+func (h *SmartListHandler) CreateSmartList(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.SmartListCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	list, err := h.smartListService.CreateSmartList(userID, &req)
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to create smart list")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, list)
+}
+
+// GetSmartList retrieves a specific smart list
+func (h *SmartListHandler) GetSmartList(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	list, err := h.smartListService.GetSmartList(userID, c.Param("id"))
+	if err != nil {
+		h.handleError(c, err, "Failed to get smart list")
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// UpdateSmartList updates a smart list
+func (h *SmartListHandler) UpdateSmartList(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.SmartListUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	list, err := h.smartListService.UpdateSmartList(userID, c.Param("id"), &req)
+	if err != nil {
+		h.handleError(c, err, "Failed to update smart list")
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// DeleteSmartList deletes a smart list
+func (h *SmartListHandler) DeleteSmartList(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.smartListService.DeleteSmartList(userID, c.Param("id")); err != nil {
+		h.handleError(c, err, "Failed to delete smart list")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Smart list deleted successfully",
+		"smart_list_id": c.Param("id"),
+	})
+}
+
+// TogglePin toggles the pinned status of a smart list
+func (h *SmartListHandler) TogglePin(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.smartListService.TogglePin(userID, c.Param("id")); err != nil {
+		h.handleError(c, err, "Failed to toggle pinned status")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Smart list pinned status toggled",
+		"smart_list_id": c.Param("id"),
+	})
+}
+
+// ReorderSmartLists changes the order of the current user's smart lists
+func (h *SmartListHandler) ReorderSmartLists(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.SmartListOrder
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.smartListService.ReorderSmartLists(userID, req.IDs); err != nil {
+		h.handleError(c, err, "Failed to reorder smart lists")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Smart lists reordered successfully"})
+}
+
+// GetSmartListArticles retrieves the articles currently matching a smart list
+func (h *SmartListHandler) GetSmartListArticles(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	request, err := parseArticleFilter(c, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	articles, err := h.smartListService.GetSmartListArticles(userID, c.Param("id"), request)
+	if err != nil {
+		h.handleError(c, err, "Failed to get smart list articles")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"articles": toArticleResponses(articles),
+		"total":    len(articles),
+	})
+}
+
+// handleError maps smart list service errors to responses
+func (h *SmartListHandler) handleError(c *gin.Context, err error, message string) {
+	if errors.Is(err, services.ErrSmartListNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Smart list not found"})
+		return
+	}
+
+	h.logger.WithError(err).Error(message)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
@@ -11,15 +11,17 @@ import (
 
 // UserHandler handles user-related endpoints
 type UserHandler struct {
-	userService *services.UserService
-	logger      *logrus.Logger
+	userService    *services.UserService
+	articleService *services.ArticleService
+	logger         *logrus.Logger
 }
 
 // NewUserHandler creates a new user handler
-func NewUserHandler(userService *services.UserService, logger *logrus.Logger) *UserHandler {
+func NewUserHandler(userService *services.UserService, articleService *services.ArticleService, logger *logrus.Logger) *UserHandler {
 	return &UserHandler{
-		userService: userService,
-		logger:      logger,
+		userService:    userService,
+		articleService: articleService,
+		logger:         logger,
 	}
 }
 
@@ -83,14 +85,14 @@ func (h *UserHandler) GetStats(c *gin.Context) {
 		return
 	}
 
-	// Placeholder implementation
-	c.JSON(http.StatusOK, gin.H{
-		"total_articles":    0,
-		"read_articles":     0,
-		"unread_articles":   0,
-		"favorite_articles": 0,
-		"storage_used":      0,
-	})
+	stats, err := h.articleService.GetStats(userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get user stats")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
 }
 
 // GetExtensionUser retrieves user info for browser extension
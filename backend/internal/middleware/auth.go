@@ -43,8 +43,9 @@ func Auth(authService *auth.Service, userService *services.UserService) gin.Hand
 			return
 		}
 
-		// Set user info in context
-		c.Set("user_id", claims.UserID)
+		// Set user info in context. Handlers read the user ID with
+		// c.GetString, so it is stored as a string.
+		c.Set("user_id", claims.UserID.String())
 		c.Set("user_email", claims.Email)
 		c.Set("user_username", claims.Username)
 
@@ -26,6 +26,10 @@ type Article struct {
 	
 	// Storage and encryption
 	IsEncrypted     bool   `json:"is_encrypted" db:"is_encrypted"`
+	IsPrivate       bool   `json:"is_private" db:"is_private"` // title, URL and description hold ciphertext
+	ServerEncrypted bool   `json:"server_encrypted" db:"server_encrypted"` // content encrypted under the user's server-managed key
+	ClientEncrypted bool   `json:"client_encrypted" db:"client_encrypted"` // content encrypted by a zero-knowledge client
+	PrivateFields   string `json:"private_fields,omitempty" db:"private_fields"` // encrypted tags, category and metadata
 	StorageSize     int64  `json:"storage_size" db:"storage_size"`
 	LocalPath       string `json:"local_path" db:"local_path"`
 	CloudPath       string `json:"cloud_path" db:"cloud_path"`
@@ -53,6 +57,7 @@ type Article struct {
 
 // ArticleCreate represents the data needed to create a new article
 type ArticleCreate struct {
+	ID            string            `json:"id,omitempty"` // client-generated, required with Encrypted
 	URL           string            `json:"url" validate:"required,url"`
 	Title         string            `json:"title,omitempty"`
 	Description   string            `json:"description,omitempty"`
@@ -62,7 +67,11 @@ type ArticleCreate struct {
 	Category      string            `json:"category,omitempty"`
 	CaptureMethod string            `json:"capture_method,omitempty"`
 	Metadata      map[string]string `json:"metadata,omitempty"`
-	UserKey       string            `json:"user_key,omitempty"`
+	UserKey       string            `json:"user_key,omitempty"` // legacy: the server encrypts with the key; prefer Encrypted
+	Encrypted     *EncryptedContent `json:"encrypted,omitempty"` // zero-knowledge: content encrypted by the client
+	KeyCheck      string            `json:"key_check,omitempty"` // client-computed, see encryption.NewKeyCheck; required with Encrypted
+	FullPrivacy   bool              `json:"full_privacy,omitempty"` // also encrypt title, URL, description, tags, category and metadata
+	BlindIndex    []string          `json:"blind_index,omitempty"` // client-computed tokens, see encryption.BlindTokens
 }
 
 // ArticleUpdate represents the data that can be updated for an article
@@ -74,6 +83,7 @@ type ArticleUpdate struct {
 	IsRead      *bool     `json:"is_read,omitempty"`
 	IsFavorite  *bool     `json:"is_favorite,omitempty"`
 	IsArchived  *bool     `json:"is_archived,omitempty"`
+	BlindIndex  *[]string `json:"blind_index,omitempty"`
 }
 
 // ArticleResponse represents the article data returned in API responses
@@ -90,6 +100,9 @@ type ArticleResponse struct {
 	ReadingTime     int        `json:"reading_time"`
 	Language        string     `json:"language"`
 	StorageSize     int64      `json:"storage_size"`
+	IsPrivate       bool       `json:"is_private"`
+	PrivateFields   string     `json:"private_fields,omitempty"`
+	Encryption      string     `json:"encryption"` // none, server_managed or zero_knowledge
 	Status          string     `json:"status"`
 	IsRead          bool       `json:"is_read"`
 	IsFavorite      bool       `json:"is_favorite"`
@@ -107,26 +120,52 @@ type ArticleResponse struct {
 type ArticleContent struct {
 	Article  Article           `json:"article"`
 	ID       uuid.UUID         `json:"id"`
-	Content  string            `json:"content"`
-	Metadata map[string]string `json:"metadata"`
+	Content   string            `json:"content"`
+	Summary   string            `json:"summary,omitempty"`
+	Metadata  map[string]string `json:"metadata"`
+	Encrypted *EncryptedContent `json:"encrypted,omitempty"` // set instead of Content for zero-knowledge articles
 }
 
 // ArticleFilter represents filters for querying articles
 type ArticleFilter struct {
-	UserID     uuid.UUID `json:"user_id"`
-	Status     string    `json:"status,omitempty"`
-	IsRead     *bool     `json:"is_read,omitempty"`
-	IsFavorite *bool     `json:"is_favorite,omitempty"`
-	IsArchived *bool     `json:"is_archived,omitempty"`
-	Category   string    `json:"category,omitempty"`
-	Tags       []string  `json:"tags,omitempty"`
-	Search     string    `json:"search,omitempty"`
-	DateFrom   *time.Time `json:"date_from,omitempty"`
-	DateTo     *time.Time `json:"date_to,omitempty"`
-	Limit      int       `json:"limit,omitempty"`
-	Offset     int       `json:"offset,omitempty"`
-	SortBy     string    `json:"sort_by,omitempty"` // created_at, updated_at, title, reading_time
-	SortOrder  string    `json:"sort_order,omitempty"` // asc, desc
+	UserID         uuid.UUID  `json:"user_id"`
+	Status         string     `json:"status,omitempty"`
+	IsRead         *bool      `json:"is_read,omitempty"`
+	IsFavorite     *bool      `json:"is_favorite,omitempty"`
+	IsArchived     *bool      `json:"is_archived,omitempty"`
+	Category       string     `json:"category,omitempty"`
+	Tags           []string   `json:"tags,omitempty"`
+	Domain         string     `json:"domain,omitempty"`
+	Author         string     `json:"author,omitempty"`
+	Year           int        `json:"year,omitempty"`
+	MaxReadingTime int        `json:"max_reading_time,omitempty"` // in minutes
+	Search         string     `json:"search,omitempty"`
+	SearchMode     string     `json:"search_mode,omitempty"` // fulltext, fuzzy
+	DateFrom       *time.Time `json:"date_from,omitempty"`
+	DateTo         *time.Time `json:"date_to,omitempty"`
+	Limit          int        `json:"limit,omitempty"`
+	Offset         int        `json:"offset,omitempty"`
+	SortBy         string     `json:"sort_by,omitempty"`    // created_at, updated_at, title, reading_time
+	SortOrder      string     `json:"sort_order,omitempty"` // asc, desc
+}
+
+// sortFields lists the columns articles can be sorted by
+var sortFields = map[string]bool{
+	"created_at":   true,
+	"updated_at":   true,
+	"title":        true,
+	"reading_time": true,
+}
+
+// ValidateSort checks the sort field and order of the filter
+func (f *ArticleFilter) ValidateSort() error {
+	if f.SortBy != "" && !sortFields[f.SortBy] {
+		return fmt.Errorf("invalid sort field: %s", f.SortBy)
+	}
+	if f.SortOrder != "" && f.SortOrder != "asc" && f.SortOrder != "desc" {
+		return fmt.Errorf("invalid sort order: %s", f.SortOrder)
+	}
+	return nil
 }
 
 // ArticleStats represents statistics about user's articles
@@ -139,6 +178,32 @@ type ArticleStats struct {
 	TotalWordCount   int   `json:"total_word_count"`
 	TotalReadingTime int   `json:"total_reading_time"`
 	StorageUsed      int64 `json:"storage_used"`
+
+	Storage      StorageUsage `json:"storage"`
+	StorageStats StorageStats `json:"storage_stats"`
+}
+
+// StorageStats summarizes a user's stored article content
+type StorageStats struct {
+	Articles          int       `json:"articles"` // articles with stored content
+	EncryptedArticles int       `json:"encrypted_articles"`
+	LogicalSize       int64     `json:"logical_size"`  // article JSON before compression and deduplication
+	PhysicalSize      int64     `json:"physical_size"` // compressed content files; shared bodies are stored once for all users and not included
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// EncryptionMode returns how the article's content is encrypted
+func (a *Article) EncryptionMode() string {
+	switch {
+	case a.IsEncrypted && a.ClientEncrypted:
+		return EncryptionModeZeroKnowledge
+	case a.IsEncrypted:
+		return EncryptionModeUserKey
+	case a.ServerEncrypted:
+		return EncryptionModeServerManaged
+	default:
+		return EncryptionModeNone
+	}
 }
 
 // ToResponse converts an Article to ArticleResponse
@@ -156,6 +221,9 @@ func (a *Article) ToResponse() *ArticleResponse {
 		ReadingTime:   a.ReadingTime,
 		Language:      a.Language,
 		StorageSize:   a.StorageSize,
+		IsPrivate:     a.IsPrivate,
+		PrivateFields: a.PrivateFields,
+		Encryption:    a.EncryptionMode(),
 		Status:        a.Status,
 		IsRead:        a.IsRead,
 		IsFavorite:    a.IsFavorite,
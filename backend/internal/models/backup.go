@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Backup statuses
+const (
+	BackupStatusRunning   = "running"
+	BackupStatusCompleted = "completed"
+	BackupStatusFailed    = "failed"
+	BackupStatusExpired   = "expired" // removed by the retention policy
+)
+
+// Backup records one cloud backup run of a user's content
+type Backup struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	UserID        uuid.UUID  `json:"user_id" db:"user_id"`
+	SnapshotID    string     `json:"snapshot_id,omitempty" db:"snapshot_id"`
+	Status        string     `json:"status" db:"status"`
+	ObjectCount   int        `json:"object_count" db:"object_count"`
+	TotalSize     int64      `json:"total_size" db:"total_size"`
+	UploadedCount int        `json:"uploaded_count" db:"uploaded_count"`
+	UploadedSize  int64      `json:"uploaded_size" db:"uploaded_size"`
+	Error         string     `json:"error,omitempty" db:"error"`
+	StartedAt     time.Time  `json:"started_at" db:"started_at"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// BackupRestore selects what to restore from a backup. An empty ArticleID
+// restores the whole snapshot.
+type BackupRestore struct {
+	ArticleID string `json:"article_id,omitempty"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+// EncryptionKeyRequest carries a user key to verify before use
+type EncryptionKeyRequest struct {
+	UserKey string `json:"user_key" binding:"required"`
+}
+
+// EncryptedContent is article content encrypted by the client. Every field
+// is a base64 envelope sealed under the article's data key, bound to the
+// user ID, article ID and field name; the data key is wrapped under a KEK
+// derived from the user key. Title, URL, description and private fields
+// are only set for full-privacy articles. See pkg/zkclient.
+type EncryptedContent struct {
+	WrappedKey    string `json:"wrapped_key"`
+	Content       string `json:"content"`
+	Summary       string `json:"summary,omitempty"`
+	Title         string `json:"title,omitempty"`
+	URL           string `json:"url,omitempty"`
+	Description   string `json:"description,omitempty"`
+	PrivateFields string `json:"private_fields,omitempty"`
+}
+
+// Encryption modes of article content. Zero-knowledge content is encrypted
+// by the client under a key only the user holds. User-key content is
+// encrypted under the user's key too, but by the server from a user_key
+// sent with the article, so the server saw the key and the plaintext.
+// Server-managed content is encrypted at rest under a key the server holds
+// and can decrypt.
+const (
+	EncryptionModeNone          = "none"
+	EncryptionModeServerManaged = "server_managed"
+	EncryptionModeUserKey       = "user_key"
+	EncryptionModeZeroKnowledge = "zero_knowledge"
+)
+
+// EncryptionStatus tells clients which encryption modes the server offers
+// and which applies to content saved without a user key
+type EncryptionStatus struct {
+	ZeroKnowledge bool   `json:"zero_knowledge"`
+	ServerManaged bool   `json:"server_managed"`
+	DefaultMode   string `json:"default_mode"`
+}
+
+// MasterKeyUsage counts the server-managed keys wrapped by a master key
+// and the backups encrypted under it
+type MasterKeyUsage struct {
+	MasterKeyID string `json:"master_key_id"`
+	Current     bool   `json:"current"`
+	Configured  bool   `json:"configured"` // the key is ENCRYPTION_KEY or in ENCRYPTION_KEY_PREVIOUS
+	Keys        int    `json:"keys"`
+	Backups     int    `json:"backups"` // completed backups not yet expired by retention
+}
+
+// ServerEncryptionOptions controls the encryption of existing content
+// under server-managed keys
+type ServerEncryptionOptions struct {
+	UserID    string        // limit the run to one user
+	BatchSize int           // articles encrypted between pauses
+	Pause     time.Duration // pause between batches to limit load
+	DryRun    bool          // only count the articles left to encrypt
+}
+
+// ServerEncryptionProgress reports how far an encryption of existing
+// content has got
+type ServerEncryptionProgress struct {
+	Total     int           `json:"total"` // articles with unencrypted content or assets when the run started
+	Encrypted int           `json:"encrypted"`
+	Skipped   int           `json:"skipped"` // deleted, encrypted since or with storage operations in flight
+	Failed    int           `json:"failed"`
+	Elapsed   time.Duration `json:"elapsed"`
+}
+
+// Done returns the number of articles handled so far
+func (p *ServerEncryptionProgress) Done() int {
+	return p.Encrypted + p.Skipped + p.Failed
+}
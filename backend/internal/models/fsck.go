@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Storage check issue types
+const (
+	FsckOrphan             = "orphan"              // content file without an article row
+	FsckMissing            = "missing"             // article row whose content file is missing
+	FsckUnparsable         = "unparsable"          // content file that is not valid article JSON
+	FsckUndecryptable      = "undecryptable"       // encrypted content that is not valid ciphertext
+	FsckEncryptionMismatch = "encryption_mismatch" // is_encrypted disagrees with the content file
+	FsckSizeMismatch       = "size_mismatch"       // storage_size disagrees with the content file
+	FsckPathMismatch       = "path_mismatch"       // local_path disagrees with the content file's key
+)
+
+// Ways to handle bad content files (orphaned, unparsable or undecryptable)
+const (
+	FsckFilesKeep       = ""
+	FsckFilesQuarantine = "quarantine"
+	FsckFilesDelete     = "delete"
+)
+
+// FsckOptions controls a storage check
+type FsckOptions struct {
+	UserID string `json:"user_id,omitempty"` // limit the check to one user
+	Repair bool   `json:"repair"`            // fix database rows to match storage
+	Files  string `json:"files,omitempty"`   // quarantine or delete bad files
+	DryRun bool   `json:"dry_run"`           // report what would be done without doing it
+}
+
+// FsckIssue is an inconsistency found by a storage check
+type FsckIssue struct {
+	Type      string `json:"type"`
+	UserID    string `json:"user_id,omitempty"`
+	ArticleID string `json:"article_id,omitempty"`
+	Key       string `json:"key,omitempty"`
+	Detail    string `json:"detail"`
+	Action    string `json:"action,omitempty"` // action taken, or that would be taken in a dry run
+	Error     string `json:"error,omitempty"`  // why the action failed
+}
+
+// FsckReport is the result of a storage check
+type FsckReport struct {
+	Options         FsckOptions    `json:"options"`
+	StartedAt       time.Time      `json:"started_at"`
+	CompletedAt     time.Time      `json:"completed_at"`
+	FilesScanned    int            `json:"files_scanned"`
+	ArticlesScanned int            `json:"articles_scanned"`
+	Counts          map[string]int `json:"counts"`
+	Issues          []FsckIssue    `json:"issues"`
+}
+
+// IsValidFsckFiles checks if a bad file action is supported
+func IsValidFsckFiles(files string) bool {
+	return files == FsckFilesKeep || files == FsckFilesQuarantine || files == FsckFilesDelete
+}
+
+// Add records an issue and counts it by type
+func (r *FsckReport) Add(issue FsckIssue) {
+	r.Issues = append(r.Issues, issue)
+	r.Counts[issue.Type]++
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Job run statuses
+const (
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// JobRun records one run of a background job
+type JobRun struct {
+	ID          int64      `json:"id" db:"id"`
+	JobName     string     `json:"job_name" db:"job_name"`
+	Instance    string     `json:"instance" db:"instance"`
+	Status      string     `json:"status" db:"status"`
+	Error       string     `json:"error,omitempty" db:"error"`
+	StartedAt   time.Time  `json:"started_at" db:"started_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// JobStatus summarizes a registered job and its recent runs
+type JobStatus struct {
+	Name      string    `json:"name"`
+	Schedule  string    `json:"schedule"`
+	NextRun   time.Time `json:"next_run"`
+	Running   bool      `json:"running"` // running on the replica that answered
+	LastRun   *JobRun   `json:"last_run,omitempty"`
+	LastError *JobRun   `json:"last_error,omitempty"` // most recent failed run
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Key recovery audit events
+const (
+	KeyRecoveryEventSetup          = "setup"
+	KeyRecoveryEventTested         = "tested"
+	KeyRecoveryEventUsed           = "used"
+	KeyRecoveryEventRemoved        = "removed"
+	KeyRecoveryEventEscrowAccessed = "escrow_accessed"
+)
+
+// KeyRecovery describes the recovery set up for a user's encryption key.
+// The wrapped keys themselves are never returned to the user.
+type KeyRecovery struct {
+	UserID       uuid.UUID  `json:"user_id" db:"user_id"`
+	Escrowed     bool       `json:"escrowed" db:"escrowed"`
+	EscrowKeyID  string     `json:"escrow_key_id,omitempty" db:"escrow_key_id"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	LastTestedAt *time.Time `json:"last_tested_at,omitempty" db:"last_tested_at"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+}
+
+// KeyRecoverySetup is returned once when recovery is set up. The recovery
+// key is not stored and cannot be shown again.
+type KeyRecoverySetup struct {
+	RecoveryKey string       `json:"recovery_key"`
+	Recovery    *KeyRecovery `json:"recovery"`
+}
+
+// KeyRecoverySetupRequest carries the user key to make recoverable and
+// whether to also escrow it to the organization
+type KeyRecoverySetupRequest struct {
+	UserKey string `json:"user_key" binding:"required"`
+	Escrow  bool   `json:"escrow,omitempty"`
+}
+
+// KeyRecoveryRequest carries a recovery key to test or use
+type KeyRecoveryRequest struct {
+	RecoveryKey string `json:"recovery_key" binding:"required"`
+}
+
+// KeyRecoveryEvent is an audit log entry of key recovery
+type KeyRecoveryEvent struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Event     string    `json:"event" db:"event"`
+	Success   bool      `json:"success" db:"success"`
+	Actor     string    `json:"actor" db:"actor"`
+	IPAddress string    `json:"ip_address" db:"ip_address"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// EscrowedKey is a user key sealed to the organization escrow public key,
+// released to administrators to open offline
+type EscrowedKey struct {
+	UserID      uuid.UUID `json:"user_id"`
+	EscrowedKey string    `json:"escrowed_key"`
+	EscrowKeyID string    `json:"escrow_key_id"`
+}
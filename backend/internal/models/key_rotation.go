@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Key rotation statuses
+const (
+	KeyRotationRunning   = "running"
+	KeyRotationPaused    = "paused"
+	KeyRotationCompleted = "completed"
+	KeyRotationFailed    = "failed" // some articles still need the old key
+)
+
+// KeyRotation tracks moving a user's encrypted articles from one key to
+// another
+type KeyRotation struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	UserID      uuid.UUID  `json:"user_id" db:"user_id"`
+	Status      string     `json:"status" db:"status"`
+	Total       int        `json:"total" db:"total"`
+	Rotated     int        `json:"rotated" db:"rotated"`
+	Failed      int        `json:"failed" db:"failed"`
+	Error       string     `json:"error,omitempty" db:"error"`
+	StartedAt   time.Time  `json:"started_at" db:"started_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// KeyRotationRequest carries the keys to rotate between. They are held in
+// memory only while the rotation runs. Without a new key, content is only
+// re-encrypted in the current format under the same key.
+type KeyRotationRequest struct {
+	OldKey string `json:"old_key" binding:"required"`
+	NewKey string `json:"new_key,omitempty"`
+}
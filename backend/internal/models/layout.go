@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// LayoutMigrationOptions controls a move of stored content to the current
+// layout
+type LayoutMigrationOptions struct {
+	UserID    string        // limit the migration to one user
+	BatchSize int           // articles moved between pauses
+	Pause     time.Duration // pause between batches to limit load
+	DryRun    bool          // only count the articles left to move
+}
+
+// LayoutMigrationProgress reports how far a layout migration has got
+type LayoutMigrationProgress struct {
+	Total   int           `json:"total"` // articles in an older layout when the run started
+	Moved   int           `json:"moved"`
+	Skipped int           `json:"skipped"` // deleted, rewritten or with storage operations in flight
+	Failed  int           `json:"failed"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// Done returns the number of articles handled so far
+func (p *LayoutMigrationProgress) Done() int {
+	return p.Moved + p.Skipped + p.Failed
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Search modes supported by the article search endpoint
+const (
+	SearchModeFullText = "fulltext" // full-text match with substring fallback
+	SearchModeFuzzy    = "fuzzy"    // full-text match combined with trigram similarity
+)
+
+// Suggestion types returned by the search suggestions endpoint
+const (
+	SuggestionTypeTitle  = "title"
+	SuggestionTypeTag    = "tag"
+	SuggestionTypeDomain = "domain"
+	SuggestionTypeAuthor = "author"
+)
+
+// SearchSuggestion represents a single search suggestion
+type SearchSuggestion struct {
+	Text     string    `json:"text"`
+	Type     string    `json:"type"`      // title, tag, domain, author
+	Count    int       `json:"count"`     // number of matching articles
+	LastSeen time.Time `json:"last_seen"` // most recent matching article
+	Score    float64   `json:"score"`     // similarity to the query
+	IsPrefix bool      `json:"is_prefix"` // true if the query is a prefix of the text
+}
+
+// IsValidSearchMode checks if the given search mode is supported
+func IsValidSearchMode(mode string) bool {
+	return mode == SearchModeFullText || mode == SearchModeFuzzy
+}
+
+// Facets that can be computed for search results
+const (
+	FacetTag       = "tag"
+	FacetDomain    = "domain"
+	FacetCategory  = "category"
+	FacetAuthor    = "author"
+	FacetReadState = "read_state"
+	FacetYear      = "year"
+)
+
+// AllFacets lists every supported facet in display order
+var AllFacets = []string{FacetTag, FacetDomain, FacetCategory, FacetAuthor, FacetReadState, FacetYear}
+
+// FacetBucket represents a single facet value and its article count
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// SearchFacets holds the total number of matches and the facet buckets for a search
+type SearchFacets struct {
+	Total  int                      `json:"total"`
+	Facets map[string][]FacetBucket `json:"facets"`
+}
+
+// IsValidFacet checks if the given facet is supported
+func IsValidFacet(facet string) bool {
+	for _, f := range AllFacets {
+		if f == facet {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxBlindTokens caps the number of blind index tokens per article or query
+const MaxBlindTokens = 10000
+
+// EncryptedSearchIndex is a client-encrypted search index blob. The server
+// stores and versions it without being able to read it.
+type EncryptedSearchIndex struct {
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Version   int       `json:"version" db:"version"`
+	Blob      []byte    `json:"blob,omitempty" db:"blob"` // base64 in JSON
+	Size      int64     `json:"size" db:"size"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// EncryptedSearchIndexUpload represents a new version of a client-encrypted
+// search index. BaseVersion must equal the latest stored version (0 for the
+// first upload) so concurrent clients cannot overwrite each other.
+type EncryptedSearchIndexUpload struct {
+	BaseVersion int    `json:"base_version"`
+	Blob        []byte `json:"blob" binding:"required"`
+}
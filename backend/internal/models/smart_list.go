@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SmartList represents a saved search: a query plus filter and sort order
+type SmartList struct {
+	ID        uuid.UUID     `json:"id" db:"id"`
+	UserID    uuid.UUID     `json:"user_id" db:"user_id"`
+	Name      string        `json:"name" db:"name"`
+	Query     string        `json:"query" db:"query"`
+	Filter    ArticleFilter `json:"filter" db:"filter"`
+	SortBy    string        `json:"sort_by" db:"sort_by"`
+	SortOrder string        `json:"sort_order" db:"sort_order"`
+	Position  int           `json:"position" db:"position"`
+	IsPinned  bool          `json:"is_pinned" db:"is_pinned"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at" db:"updated_at"`
+
+	// Live number of matching articles, filled in when listing
+	Count int `json:"count"`
+}
+
+// SmartListCreate represents the data needed to create a smart list
+type SmartListCreate struct {
+	Name      string        `json:"name" binding:"required,max=100"`
+	Query     string        `json:"query"`
+	Filter    ArticleFilter `json:"filter"`
+	SortBy    string        `json:"sort_by"`
+	SortOrder string        `json:"sort_order"`
+	IsPinned  bool          `json:"is_pinned"`
+}
+
+// SmartListUpdate represents the data that can be updated for a smart list
+type SmartListUpdate struct {
+	Name      *string        `json:"name,omitempty" binding:"omitempty,max=100"`
+	Query     *string        `json:"query,omitempty"`
+	Filter    *ArticleFilter `json:"filter,omitempty"`
+	SortBy    *string        `json:"sort_by,omitempty"`
+	SortOrder *string        `json:"sort_order,omitempty"`
+	IsPinned  *bool          `json:"is_pinned,omitempty"`
+}
+
+// SmartListOrder represents a new ordering of a user's smart lists
+type SmartListOrder struct {
+	IDs []uuid.UUID `json:"ids" binding:"required"`
+}
+
+// ApplyTo builds the article filter for this list, keeping the pagination
+// of the given request filter
+func (l *SmartList) ApplyTo(request *ArticleFilter) *ArticleFilter {
+	filter := l.Filter
+	filter.UserID = l.UserID
+	filter.Search = l.Query
+	filter.SortBy = l.SortBy
+	filter.SortOrder = l.SortOrder
+	if request != nil {
+		filter.Limit = request.Limit
+		filter.Offset = request.Offset
+	}
+	return &filter
+}
@@ -126,9 +126,10 @@ func (u *User) GetFullName() string {
 	return u.FirstName + " " + u.LastName
 }
 
-// IsStorageLimitExceeded checks if the user has exceeded their storage limit
+// IsStorageLimitExceeded checks if the user has exceeded their storage limit.
+// A limit of zero means unlimited.
 func (u *User) IsStorageLimitExceeded() bool {
-	return u.StorageUsed >= u.MaxStorageLimit
+	return u.MaxStorageLimit > 0 && u.StorageUsed >= u.MaxStorageLimit
 }
 
 // GetStorageUsagePercentage returns the storage usage as a percentage
@@ -137,4 +138,12 @@ func (u *User) GetStorageUsagePercentage() float64 {
 		return 0
 	}
 	return float64(u.StorageUsed) / float64(u.MaxStorageLimit) * 100
-} 
\ No newline at end of file
+}
+
+// StorageUsage describes a user's stored content against their quota
+type StorageUsage struct {
+	Used    int64   `json:"used"`
+	Limit   int64   `json:"limit"` // 0 means unlimited
+	Percent float64 `json:"percent"`
+	Warning int     `json:"warning,omitempty"` // highest warning threshold reached, in percent
+}
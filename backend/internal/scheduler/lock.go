@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// lockPrefix namespaces job locks in Redis
+const lockPrefix = "scheduler:lock:"
+
+// Locker grants a lock on a job to one replica at a time. Locks expire
+// after their TTL so a crashed replica cannot hold a job forever.
+type Locker interface {
+	// TryLock acquires a lock without waiting. It returns false if another
+	// holder has it, and a function releasing it otherwise.
+	TryLock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error)
+}
+
+// releaseScript deletes a lock only if it is still held by the caller's
+// token, so a lock that expired and was taken over is left alone
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisLocker is a Locker shared by every replica using the same Redis
+type RedisLocker struct {
+	client *redis.Client
+}
+
+// NewRedisLocker creates a Redis-backed locker
+func NewRedisLocker(client *redis.Client) *RedisLocker {
+	return &RedisLocker{client: client}
+}
+
+// TryLock sets the lock key with a random token if it does not exist
+func (r *RedisLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, false, err
+	}
+
+	ok, err := r.client.SetNX(ctx, lockPrefix+key, token, ttl).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	if !ok {
+		return nil, false, nil
+	}
+
+	release := func() {
+		// The job's context may be cancelled by now
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		releaseScript.Run(ctx, r.client, []string{lockPrefix + key}, token)
+	}
+	return release, true, nil
+}
+
+// MemoryLocker is a Locker for a single process, used when jobs do not need
+// coordinating across replicas
+type MemoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]time.Time // key -> expiry
+}
+
+// NewMemoryLocker creates an in-process locker
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{locks: map[string]time.Time{}}
+}
+
+// TryLock takes the lock if it is free or expired
+func (m *MemoryLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if expiry, ok := m.locks[key]; ok && now.Before(expiry) {
+		return nil, false, nil
+	}
+
+	expiry := now.Add(ttl)
+	m.locks[key] = expiry
+
+	release := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.locks[key].Equal(expiry) {
+			delete(m.locks, key)
+		}
+	}
+	return release, true, nil
+}
+
+// randomToken returns a unique lock owner token
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate lock token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
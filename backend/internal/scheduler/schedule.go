@@ -0,0 +1,219 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when a job runs next
+type Schedule interface {
+	// Next returns the first run time strictly after t
+	Next(t time.Time) time.Time
+	String() string
+}
+
+// interval runs a job at a fixed interval
+type interval struct {
+	every time.Duration
+}
+
+// Every returns a schedule running a job every d, starting d after the
+// scheduler starts
+func Every(d time.Duration) Schedule {
+	return interval{every: d}
+}
+
+// Next returns t plus the interval
+func (i interval) Next(t time.Time) time.Time {
+	return t.Add(i.every)
+}
+
+// String returns the schedule in @every form
+func (i interval) String() string {
+	return "@every " + i.every.String()
+}
+
+// cronField bounds
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// cronDescriptors are shorthands for common cron expressions
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cron is a parsed five-field cron expression evaluated in UTC
+type cron struct {
+	expr                         string
+	minute, hour, dom, month     map[int]bool
+	dow                          map[int]bool
+	domRestricted, dowRestricted bool
+}
+
+// ParseCron parses a standard five-field cron expression (minute, hour,
+// day of month, month, day of week) such as "30 3 * * *", a descriptor such
+// as "@daily", or "@every <duration>". Times are in UTC.
+func ParseCron(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid interval in %q", expr)
+		}
+		return Every(d), nil
+	}
+
+	spec := expr
+	if descriptor, ok := cronDescriptors[expr]; ok {
+		spec = descriptor
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q must have %d fields", expr, len(cronFields))
+	}
+
+	sets := make([]map[int]bool, len(fields))
+	for i, field := range fields {
+		max := cronFields[i].max
+		if i == 4 {
+			max = 7 // Sunday may be written as 7
+		}
+
+		set, err := parseCronField(field, cronFields[i].min, max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in %q: %w", cronFields[i].name, expr, err)
+		}
+		sets[i] = set
+	}
+
+	if sets[4][7] {
+		sets[4][0] = true
+		delete(sets[4], 7)
+	}
+
+	return &cron{
+		expr:          expr,
+		minute:        sets[0],
+		hour:          sets[1],
+		dom:           sets[2],
+		month:         sets[3],
+		dow:           sets[4],
+		domRestricted: fields[2] != "*",
+		dowRestricted: fields[4] != "*",
+	}, nil
+}
+
+// parseCronField parses a comma-separated list of *, values, ranges and
+// steps such as "*/15", "1-5" or "0,30"
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := map[int]bool{}
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangePart = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step %q", part)
+			}
+		}
+
+		start, end := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err1, err2 error
+			start, err1 = strconv.Atoi(bounds[0])
+			end, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			value, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", rangePart)
+			}
+			start, end = value, value
+			if strings.Contains(part, "/") {
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return nil, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+
+		for value := start; value <= end; value += step {
+			set[value] = true
+		}
+	}
+
+	return set, nil
+}
+
+// Next returns the first minute after t matching the expression
+func (c *cron) Next(t time.Time) time.Time {
+	next := t.UTC().Truncate(time.Minute).Add(time.Minute)
+
+	// Every matching time recurs within a few years (Feb 29 at worst)
+	limit := next.AddDate(5, 0, 0)
+	for next.Before(limit) {
+		if !c.month[int(next.Month())] {
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.dayMatches(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.hour[next.Hour()] {
+			next = next.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !c.minute[next.Minute()] {
+			next = next.Add(time.Minute)
+			continue
+		}
+		return next
+	}
+
+	// Expressions such as "0 0 31 2 *" never match
+	return time.Time{}
+}
+
+// dayMatches applies cron's day rule: when both the day of month and the
+// day of week are restricted, either may match
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := c.dom[t.Day()]
+	dow := c.dow[int(t.Weekday())]
+
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// String returns the expression the schedule was parsed from
+func (c *cron) String() string {
+	return c.expr
+}
@@ -0,0 +1,248 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultJobTimeout bounds a run, and how long its lock is held, when a job
+// sets no timeout
+const DefaultJobTimeout = time.Hour
+
+// Job is a periodic maintenance task
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Timeout  time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// History records job runs so every replica can report them
+type History interface {
+	StartRun(job, instance string) (int64, error)
+	FinishRun(id int64, runErr error) error
+}
+
+// JobInfo describes a registered job
+type JobInfo struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	NextRun  time.Time `json:"next_run"`
+	Running  bool      `json:"running"` // running on this replica
+}
+
+// Scheduler runs jobs on their schedules. Each run takes the job's lock
+// first, so with a shared Locker only one replica runs a job at a time.
+type Scheduler struct {
+	locker   Locker
+	history  History
+	logger   *logrus.Logger
+	instance string
+
+	mu      sync.Mutex
+	jobs    []*scheduledJob
+	started bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// scheduledJob is a job and its run state
+type scheduledJob struct {
+	Job
+	next    time.Time
+	running bool
+}
+
+// New creates a scheduler. History may be nil.
+func New(locker Locker, history History, logger *logrus.Logger) *Scheduler {
+	hostname, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Scheduler{
+		locker:   locker,
+		history:  history,
+		logger:   logger,
+		instance: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Add registers a job. Jobs must be added before Start.
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Schedule == nil || job.Run == nil {
+		return fmt.Errorf("job needs a name, a schedule and a run function")
+	}
+	if job.Timeout <= 0 {
+		job.Timeout = DefaultJobTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return fmt.Errorf("scheduler already started")
+	}
+	for _, existing := range s.jobs {
+		if existing.Name == job.Name {
+			return fmt.Errorf("job %s already registered", job.Name)
+		}
+	}
+
+	s.jobs = append(s.jobs, &scheduledJob{Job: job})
+	return nil
+}
+
+// Start runs every job on its schedule in the background
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return
+	}
+	s.started = true
+
+	for _, job := range s.jobs {
+		job.next = job.Schedule.Next(time.Now())
+		s.wg.Add(1)
+		go s.loop(job)
+	}
+}
+
+// Stop stops scheduling jobs, cancels running ones and waits for them to
+// return or for ctx to end
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("jobs did not stop in time: %w", ctx.Err())
+	}
+}
+
+// Jobs returns the registered jobs ordered by name
+func (s *Scheduler) Jobs() []JobInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := []JobInfo{}
+	for _, job := range s.jobs {
+		jobs = append(jobs, JobInfo{
+			Name:     job.Name,
+			Schedule: job.Schedule.String(),
+			NextRun:  job.next,
+			Running:  job.running,
+		})
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs
+}
+
+// loop waits for each scheduled time of a job and runs it
+func (s *Scheduler) loop(job *scheduledJob) {
+	defer s.wg.Done()
+
+	for {
+		s.mu.Lock()
+		next := job.next
+		s.mu.Unlock()
+
+		if next.IsZero() {
+			s.logger.WithField("job", job.Name).Warn("Job schedule never fires")
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.run(job)
+
+		s.mu.Lock()
+		job.next = job.Schedule.Next(time.Now())
+		s.mu.Unlock()
+	}
+}
+
+// run runs a job once if its lock is free
+func (s *Scheduler) run(job *scheduledJob) {
+	logger := s.logger.WithField("job", job.Name)
+
+	release, ok, err := s.locker.TryLock(s.ctx, job.Name, job.Timeout)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to lock job")
+		return
+	}
+	if !ok {
+		logger.Debug("Job is running on another replica")
+		return
+	}
+	defer release()
+
+	var runID int64
+	if s.history != nil {
+		if runID, err = s.history.StartRun(job.Name, s.instance); err != nil {
+			logger.WithError(err).Warn("Failed to record job run")
+		}
+	}
+
+	s.setRunning(job, true)
+	defer s.setRunning(job, false)
+
+	ctx, cancel := context.WithTimeout(s.ctx, job.Timeout)
+	defer cancel()
+
+	started := time.Now()
+	runErr := runJob(ctx, job.Run)
+
+	if s.history != nil && runID != 0 {
+		if err := s.history.FinishRun(runID, runErr); err != nil {
+			logger.WithError(err).Warn("Failed to record job result")
+		}
+	}
+
+	logger = logger.WithField("duration", time.Since(started).String())
+	if runErr != nil {
+		logger.WithError(runErr).Error("Job failed")
+		return
+	}
+	logger.Info("Job completed")
+}
+
+// setRunning records whether a job is running on this replica
+func (s *Scheduler) setRunning(job *scheduledJob, running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.running = running
+}
+
+// runJob runs a job function, turning a panic into an error
+func runJob(ctx context.Context, run func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return run(ctx)
+}
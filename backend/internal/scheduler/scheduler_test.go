@@ -0,0 +1,202 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseCron(t *testing.T) {
+	at := func(s string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatalf("Bad time %s: %v", s, err)
+		}
+		return parsed
+	}
+
+	cases := []struct {
+		expr, from, next string
+	}{
+		{"30 3 * * *", "2024-01-01T00:00:00Z", "2024-01-01T03:30:00Z"},
+		{"30 3 * * *", "2024-01-01T03:30:00Z", "2024-01-02T03:30:00Z"},
+		{"*/15 * * * *", "2024-01-01T10:07:30Z", "2024-01-01T10:15:00Z"},
+		{"0 9-17/4 * * 1-5", "2024-01-05T18:00:00Z", "2024-01-08T09:00:00Z"},
+		{"0 0 1 * *", "2024-01-15T12:00:00Z", "2024-02-01T00:00:00Z"},
+		{"0 0 29 2 *", "2024-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		{"0 12 13 * 5", "2024-01-01T00:00:00Z", "2024-01-05T12:00:00Z"}, // day of month or Friday
+		{"0 0 * * 7", "2024-01-01T00:00:00Z", "2024-01-07T00:00:00Z"},   // 7 is Sunday
+		{"@daily", "2024-01-01T00:00:00Z", "2024-01-02T00:00:00Z"},
+		{"@every 90m", "2024-01-01T00:00:00Z", "2024-01-01T01:30:00Z"},
+	}
+
+	for _, tc := range cases {
+		schedule, err := ParseCron(tc.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q) failed: %v", tc.expr, err)
+			continue
+		}
+		if got := schedule.Next(at(tc.from)); !got.Equal(at(tc.next)) {
+			t.Errorf("%q after %s: expected %s, got %s", tc.expr, tc.from, tc.next, got.Format(time.RFC3339))
+		}
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every -1m"} {
+			if _, err := ParseCron(expr); err == nil {
+				t.Errorf("ParseCron(%q) should fail", expr)
+			}
+		}
+	})
+
+	t.Run("NeverMatches", func(t *testing.T) {
+		schedule, err := ParseCron("0 0 31 2 *")
+		if err != nil {
+			t.Fatalf("ParseCron failed: %v", err)
+		}
+		if next := schedule.Next(time.Now()); !next.IsZero() {
+			t.Errorf("Expected no next run, got %s", next)
+		}
+	})
+}
+
+// recordingHistory keeps job runs in memory
+type recordingHistory struct {
+	mu       sync.Mutex
+	started  int
+	finished []error
+}
+
+func (h *recordingHistory) StartRun(job, instance string) (int64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.started++
+	return int64(h.started), nil
+}
+
+func (h *recordingHistory) FinishRun(id int64, runErr error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.finished = append(h.finished, runErr)
+	return nil
+}
+
+func TestScheduler(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	t.Run("RunsIntervalJobsAndRecordsHistory", func(t *testing.T) {
+		history := &recordingHistory{}
+		s := New(NewMemoryLocker(), history, logger)
+
+		var runs int32
+		s.Add(Job{Name: "tick", Schedule: Every(10 * time.Millisecond), Run: func(ctx context.Context) error {
+			if atomic.AddInt32(&runs, 1) == 2 {
+				return errors.New("second run fails")
+			}
+			return nil
+		}})
+		s.Add(Job{Name: "panics", Schedule: Every(10 * time.Millisecond), Run: func(ctx context.Context) error {
+			panic("boom")
+		}})
+		s.Start()
+
+		time.Sleep(100 * time.Millisecond)
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatalf("Failed to stop: %v", err)
+		}
+
+		if atomic.LoadInt32(&runs) < 3 {
+			t.Errorf("Expected several runs, got %d", runs)
+		}
+
+		history.mu.Lock()
+		defer history.mu.Unlock()
+		failures := 0
+		for _, err := range history.finished {
+			if err != nil {
+				failures++
+			}
+		}
+		if history.started != len(history.finished) {
+			t.Errorf("Every started run should finish, got %d started and %d finished", history.started, len(history.finished))
+		}
+		if failures < 2 {
+			t.Errorf("Expected the failed and panicking runs to be recorded as failures, got %d", failures)
+		}
+	})
+
+	t.Run("StopCancelsRunningJobs", func(t *testing.T) {
+		s := New(NewMemoryLocker(), nil, logger)
+
+		started := make(chan struct{})
+		var cancelled int32
+		s.Add(Job{Name: "slow", Schedule: Every(time.Millisecond), Run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			atomic.StoreInt32(&cancelled, 1)
+			return ctx.Err()
+		}})
+		s.Start()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := s.Stop(ctx); err != nil {
+			t.Fatalf("Failed to stop: %v", err)
+		}
+		if atomic.LoadInt32(&cancelled) != 1 {
+			t.Error("Running job should see its context cancelled")
+		}
+	})
+
+	t.Run("SharedLockRunsJobOnOneReplica", func(t *testing.T) {
+		locker := NewMemoryLocker()
+
+		var running, overlaps, runs int32
+		job := Job{Name: "exclusive", Schedule: Every(5 * time.Millisecond), Run: func(ctx context.Context) error {
+			if atomic.AddInt32(&running, 1) > 1 {
+				atomic.AddInt32(&overlaps, 1)
+			}
+			atomic.AddInt32(&runs, 1)
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		}}
+
+		replicas := []*Scheduler{New(locker, nil, logger), New(locker, nil, logger)}
+		for _, replica := range replicas {
+			replica.Add(job)
+			replica.Start()
+		}
+
+		time.Sleep(150 * time.Millisecond)
+		for _, replica := range replicas {
+			replica.Stop(context.Background())
+		}
+
+		if overlaps > 0 {
+			t.Errorf("Job ran on both replicas at once %d times", overlaps)
+		}
+		if runs == 0 {
+			t.Error("Job never ran")
+		}
+	})
+
+	t.Run("RejectsDuplicateJobs", func(t *testing.T) {
+		s := New(NewMemoryLocker(), nil, logger)
+		job := Job{Name: "dup", Schedule: Every(time.Hour), Run: func(ctx context.Context) error { return nil }}
+		if err := s.Add(job); err != nil {
+			t.Fatalf("Failed to add job: %v", err)
+		}
+		if err := s.Add(job); err == nil {
+			t.Error("Adding a job twice should fail")
+		}
+	})
+}
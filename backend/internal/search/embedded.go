@@ -0,0 +1,363 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// tempFilePrefix marks index files being written by save
+const tempFilePrefix = ".index-"
+
+// embeddedIndexVersion is the on-disk format version of a user index file
+const embeddedIndexVersion = 1
+
+// EmbeddedIndex is an on-disk inverted index for deployments whose database
+// lacks full-text search. Each user's index is a single file that is loaded
+// on first use and rewritten atomically after every change.
+type EmbeddedIndex struct {
+	dir   string
+	mu    sync.Mutex
+	users map[string]*userIndex
+}
+
+// userIndex holds the documents and postings of one user
+type userIndex struct {
+	Version  int                       `json:"version"`
+	Docs     map[string]*indexedDoc    `json:"docs"`
+	Postings map[string]map[string]int `json:"postings"` // term -> article ID -> frequency
+}
+
+// indexedDoc holds the fields of a document needed to evaluate a query
+type indexedDoc struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	URL         string         `json:"url"`
+	Author      string         `json:"author"`
+	SiteName    string         `json:"site_name"`
+	Tags        []string       `json:"tags"`
+	CreatedAt   time.Time      `json:"created_at"`
+	Terms       map[string]int `json:"terms"`
+}
+
+// NewEmbeddedIndex creates an embedded index stored under dir
+func NewEmbeddedIndex(dir string) (*EmbeddedIndex, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "users"), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create search index directory: %w", err)
+	}
+
+	return &EmbeddedIndex{
+		dir:   dir,
+		users: map[string]*userIndex{},
+	}, nil
+}
+
+// Name returns the backend name
+func (e *EmbeddedIndex) Name() string {
+	return BackendEmbedded
+}
+
+// Index adds or replaces documents, persisting each affected user once
+func (e *EmbeddedIndex) Index(docs ...*Document) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	changed := map[string]*userIndex{}
+	for _, doc := range docs {
+		idx, err := e.load(doc.UserID)
+		if err != nil {
+			return err
+		}
+
+		// Full-privacy articles are never matched, so they are not kept
+		idx.remove(doc.ArticleID)
+		if !doc.Private {
+			idx.add(doc)
+		}
+		changed[doc.UserID] = idx
+	}
+
+	for userID, idx := range changed {
+		if err := e.save(userID, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes an article from the index
+func (e *EmbeddedIndex) Delete(userID, articleID string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	idx, err := e.load(userID)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := idx.Docs[articleID]; !ok {
+		return nil
+	}
+
+	idx.remove(articleID)
+	return e.save(userID, idx)
+}
+
+// Search returns the articles of a user matching a query
+func (e *EmbeddedIndex) Search(userID, query, mode string) ([]Hit, error) {
+	if !IsValidMode(mode) {
+		return nil, fmt.Errorf("invalid search mode: %s", mode)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	idx, err := e.load(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	queryTerms := terms(query)
+	substring := strings.ToLower(query)
+
+	hits := []Hit{}
+	for articleID, doc := range idx.Docs {
+		rank, termMatch := doc.rank(queryTerms)
+
+		matched := termMatch ||
+			strings.Contains(strings.ToLower(doc.Title), substring) ||
+			strings.Contains(strings.ToLower(doc.Description), substring) ||
+			strings.Contains(strings.ToLower(doc.URL), substring) ||
+			strings.Contains(strings.ToLower(tagsText(doc.Tags)), substring)
+
+		score := rank
+		if mode == ModeFuzzy {
+			fuzzy := doc.fuzzyScore(query)
+			score += fuzzy
+			matched = matched || fuzzy >= WordSimilarityThreshold
+		}
+
+		if matched {
+			hits = append(hits, Hit{ArticleID: articleID, Score: score, CreatedAt: doc.CreatedAt})
+		}
+	}
+
+	sortHits(hits)
+	return hits, nil
+}
+
+// Clear removes every document from the index
+func (e *EmbeddedIndex) Clear() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	usersDir := filepath.Join(e.dir, "users")
+	if err := os.RemoveAll(usersDir); err != nil {
+		return fmt.Errorf("failed to clear search index: %w", err)
+	}
+	if err := os.MkdirAll(usersDir, 0700); err != nil {
+		return fmt.Errorf("failed to create search index directory: %w", err)
+	}
+
+	e.users = map[string]*userIndex{}
+	return nil
+}
+
+// Close releases the cached user indexes
+func (e *EmbeddedIndex) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.users = map[string]*userIndex{}
+	return nil
+}
+
+// CleanupTempFiles removes index files left by saves interrupted by a crash
+func (e *EmbeddedIndex) CleanupTempFiles(maxAge time.Duration) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	entries, err := os.ReadDir(filepath.Join(e.dir, "users"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read search index directory: %w", err)
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), tempFilePrefix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(e.dir, "users", entry.Name())); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("failed to remove search index file: %w", err)
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// load returns the index of a user, reading it from disk on first use.
+// The caller must hold e.mu.
+func (e *EmbeddedIndex) load(userID string) (*userIndex, error) {
+	if idx, ok := e.users[userID]; ok {
+		return idx, nil
+	}
+
+	path, err := e.userPath(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &userIndex{
+		Version:  embeddedIndexVersion,
+		Docs:     map[string]*indexedDoc{},
+		Postings: map[string]map[string]int{},
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read search index: %w", err)
+	default:
+		if err := json.Unmarshal(data, idx); err != nil {
+			return nil, fmt.Errorf("failed to parse search index: %w", err)
+		}
+		if idx.Version != embeddedIndexVersion {
+			return nil, fmt.Errorf("unsupported search index version %d, rebuild the index", idx.Version)
+		}
+	}
+
+	e.users[userID] = idx
+	return idx, nil
+}
+
+// save writes the index of a user to disk atomically. The caller must hold e.mu.
+func (e *EmbeddedIndex) save(userID string, idx *userIndex) error {
+	path, err := e.userPath(userID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to serialize search index: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempFilePrefix+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create search index file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write search index: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync search index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close search index: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace search index: %w", err)
+	}
+	return nil
+}
+
+// userPath returns the index file of a user
+func (e *EmbeddedIndex) userPath(userID string) (string, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return "", fmt.Errorf("invalid user ID: %w", err)
+	}
+	return filepath.Join(e.dir, "users", userUUID.String()+".json"), nil
+}
+
+// add indexes a document
+func (idx *userIndex) add(doc *Document) {
+	termCounts := map[string]int{}
+	for _, term := range terms(doc.Title + " " + doc.Description + " " + doc.ContentText) {
+		termCounts[term]++
+	}
+
+	idx.Docs[doc.ArticleID] = &indexedDoc{
+		Title:       doc.Title,
+		Description: doc.Description,
+		URL:         doc.URL,
+		Author:      doc.Author,
+		SiteName:    doc.SiteName,
+		Tags:        doc.Tags,
+		CreatedAt:   doc.CreatedAt,
+		Terms:       termCounts,
+	}
+
+	for term, count := range termCounts {
+		if idx.Postings[term] == nil {
+			idx.Postings[term] = map[string]int{}
+		}
+		idx.Postings[term][doc.ArticleID] = count
+	}
+}
+
+// remove drops a document and its postings
+func (idx *userIndex) remove(articleID string) {
+	doc, ok := idx.Docs[articleID]
+	if !ok {
+		return
+	}
+
+	for term := range doc.Terms {
+		delete(idx.Postings[term], articleID)
+		if len(idx.Postings[term]) == 0 {
+			delete(idx.Postings, term)
+		}
+	}
+	delete(idx.Docs, articleID)
+}
+
+// rank reports whether every query term occurs in the document and a
+// frequency-based rank in the same range as ts_rank
+func (d *indexedDoc) rank(queryTerms []string) (float64, bool) {
+	if len(queryTerms) == 0 {
+		return 0, false
+	}
+
+	rank := 0.0
+	for _, term := range queryTerms {
+		count := d.Terms[term]
+		if count == 0 {
+			return 0, false
+		}
+		rank += float64(count) / float64(count+1)
+	}
+	return 0.1 * rank / float64(len(queryTerms)), true
+}
+
+// fuzzyScore returns the best word similarity between the query and the
+// short text fields of the document
+func (d *indexedDoc) fuzzyScore(query string) float64 {
+	best := 0.0
+	for _, field := range []string{d.Title, d.Author, d.SiteName, tagsText(d.Tags), d.URL} {
+		if score := wordSimilarity(query, field); score > best {
+			best = score
+		}
+	}
+	return best
+}
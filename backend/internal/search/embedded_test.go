@@ -0,0 +1,173 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestEmbeddedIndex(t *testing.T) {
+	dir := t.TempDir()
+	index, err := NewEmbeddedIndex(dir)
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	userID := uuid.New().String()
+	otherUserID := uuid.New().String()
+	now := time.Now()
+
+	goArticle := &Document{
+		ArticleID:   uuid.New().String(),
+		UserID:      userID,
+		Title:       "Understanding Kubernetes Networking",
+		Description: "How pods talk to each other",
+		ContentText: "Services route traffic between running containers",
+		URL:         "https://example.com/k8s/networking",
+		Author:      "Jane Doe",
+		Tags:        []string{"devops", "k8s"},
+		CreatedAt:   now.Add(-time.Hour),
+	}
+	rustArticle := &Document{
+		ArticleID:   uuid.New().String(),
+		UserID:      userID,
+		Title:       "Ownership in Rust",
+		ContentText: "The borrow checker keeps containers safe",
+		URL:         "https://blog.rust-lang.org/ownership",
+		CreatedAt:   now,
+	}
+	otherArticle := &Document{
+		ArticleID: uuid.New().String(),
+		UserID:    otherUserID,
+		Title:     "Kubernetes for everyone",
+		URL:       "https://example.org/k8s",
+		CreatedAt: now,
+	}
+
+	if err := index.Index(goArticle, rustArticle, otherArticle); err != nil {
+		t.Fatalf("Failed to index documents: %v", err)
+	}
+
+	search := func(t *testing.T, index Index, query, mode string) []string {
+		hits, err := index.Search(userID, query, mode)
+		if err != nil {
+			t.Fatalf("Search %q failed: %v", query, err)
+		}
+		ids := []string{}
+		for _, hit := range hits {
+			ids = append(ids, hit.ArticleID)
+		}
+		return ids
+	}
+
+	t.Run("FullTextMatchesStemmedTerms", func(t *testing.T) {
+		ids := search(t, index, "route the container", ModeFullText)
+		if len(ids) != 1 || ids[0] != goArticle.ArticleID {
+			t.Errorf("Expected only the Kubernetes article, got %v", ids)
+		}
+	})
+
+	t.Run("FullTextRequiresEveryTerm", func(t *testing.T) {
+		ids := search(t, index, "containers borrow", ModeFullText)
+		if len(ids) != 1 || ids[0] != rustArticle.ArticleID {
+			t.Errorf("Expected only the Rust article, got %v", ids)
+		}
+	})
+
+	t.Run("SubstringMatchesURL", func(t *testing.T) {
+		ids := search(t, index, "rust-lang.org", ModeFullText)
+		if len(ids) != 1 || ids[0] != rustArticle.ArticleID {
+			t.Errorf("Expected the Rust article, got %v", ids)
+		}
+	})
+
+	t.Run("FullTextIgnoresTypos", func(t *testing.T) {
+		ids := search(t, index, "kubernets", ModeFullText)
+		if len(ids) != 0 {
+			t.Errorf("Expected no full-text matches for a typo, got %v", ids)
+		}
+	})
+
+	t.Run("FuzzyMatchesTypos", func(t *testing.T) {
+		ids := search(t, index, "kubernets", ModeFuzzy)
+		if len(ids) != 1 || ids[0] != goArticle.ArticleID {
+			t.Errorf("Expected the Kubernetes article, got %v", ids)
+		}
+	})
+
+	t.Run("ResultsOrderedByRecencyOnEqualScore", func(t *testing.T) {
+		ids := search(t, index, "containers", ModeFullText)
+		if len(ids) != 2 || ids[0] != rustArticle.ArticleID {
+			t.Errorf("Expected the newer article first, got %v", ids)
+		}
+	})
+
+	t.Run("InvalidMode", func(t *testing.T) {
+		if _, err := index.Search(userID, "rust", "regex"); err == nil {
+			t.Error("Search should fail with an invalid mode")
+		}
+	})
+
+	t.Run("UpdateReplacesDocument", func(t *testing.T) {
+		updated := *rustArticle
+		updated.Title = "Lifetimes in Rust"
+		updated.ContentText = "References must not outlive their owner"
+		if err := index.Index(&updated); err != nil {
+			t.Fatalf("Failed to update document: %v", err)
+		}
+
+		if ids := search(t, index, "borrow", ModeFullText); len(ids) != 0 {
+			t.Errorf("Old terms should no longer match, got %v", ids)
+		}
+		if ids := search(t, index, "lifetimes", ModeFullText); len(ids) != 1 {
+			t.Errorf("New terms should match, got %v", ids)
+		}
+	})
+
+	t.Run("PersistsAcrossReopen", func(t *testing.T) {
+		reopened, err := NewEmbeddedIndex(dir)
+		if err != nil {
+			t.Fatalf("Failed to reopen index: %v", err)
+		}
+
+		ids := search(t, reopened, "networking", ModeFullText)
+		if len(ids) != 1 || ids[0] != goArticle.ArticleID {
+			t.Errorf("Expected the Kubernetes article after reopening, got %v", ids)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := index.Delete(userID, goArticle.ArticleID); err != nil {
+			t.Fatalf("Failed to delete document: %v", err)
+		}
+
+		if ids := search(t, index, "networking", ModeFullText); len(ids) != 0 {
+			t.Errorf("Deleted article should not match, got %v", ids)
+		}
+
+		hits, err := index.Search(otherUserID, "kubernetes", ModeFullText)
+		if err != nil || len(hits) != 1 {
+			t.Errorf("Other users' documents should be untouched, got %v (%v)", hits, err)
+		}
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		if err := index.Clear(); err != nil {
+			t.Fatalf("Failed to clear index: %v", err)
+		}
+
+		if ids := search(t, index, "lifetimes", ModeFullText); len(ids) != 0 {
+			t.Errorf("Cleared index should be empty, got %v", ids)
+		}
+	})
+}
+
+func TestPlainText(t *testing.T) {
+	html := `<html><head><style>p { color: red; }</style></head>
+		<body><p>Hello <b>world</b></p><script>alert("x")</script></body></html>`
+
+	if got := PlainText(html); got != "Hello world" {
+		t.Errorf("Expected %q, got %q", "Hello world", got)
+	}
+}
@@ -0,0 +1,120 @@
+package search
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/readitlater/backend/internal/database"
+)
+
+// searchDocument is the full-text document searched for every article.
+// It must match the expression of idx_articles_search_document.
+const searchDocument = `to_tsvector('english', coalesce(title, '') || ' ' || coalesce(description, '') || ' ' || coalesce(content_text, ''))`
+
+// fuzzyScore is the best trigram word similarity between the query and the
+// short text fields of an article
+const fuzzyScore = `GREATEST(
+	word_similarity($2, coalesce(title, '')),
+	word_similarity($2, coalesce(author, '')),
+	word_similarity($2, coalesce(site_name, '')),
+	word_similarity($2, tags::text),
+	word_similarity($2, url)
+)`
+
+// PostgresIndex searches the articles table directly using PostgreSQL
+// full-text search and pg_trgm. The table is the index, so writes are no-ops.
+type PostgresIndex struct {
+	db *database.DB
+}
+
+// NewPostgresIndex creates a new PostgreSQL-backed search index
+func NewPostgresIndex(db *database.DB) *PostgresIndex {
+	return &PostgresIndex{db: db}
+}
+
+// Name returns the backend name
+func (p *PostgresIndex) Name() string {
+	return BackendPostgres
+}
+
+// Index is a no-op; articles are indexed by the database on write
+func (p *PostgresIndex) Index(docs ...*Document) error {
+	return nil
+}
+
+// Delete is a no-op; deleted rows leave the database indexes automatically
+func (p *PostgresIndex) Delete(userID, articleID string) error {
+	return nil
+}
+
+// Search returns the articles of a user matching a query
+func (p *PostgresIndex) Search(userID, query, mode string) ([]Hit, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	if !IsValidMode(mode) {
+		return nil, fmt.Errorf("invalid search mode: %s", mode)
+	}
+
+	score := fmt.Sprintf("ts_rank(%s, plainto_tsquery('english', $2))", searchDocument)
+	match := fmt.Sprintf(`%s @@ plainto_tsquery('english', $2) OR
+			title ILIKE $3 OR
+			description ILIKE $3 OR
+			url ILIKE $3 OR
+			tags::text ILIKE $3`, searchDocument)
+
+	// Fuzzy mode adds typo-tolerant trigram matches on titles, authors,
+	// site names, tags and URLs
+	if mode == ModeFuzzy {
+		score = fmt.Sprintf("%s + %s", score, fuzzyScore)
+		match += ` OR
+			$2 <% coalesce(title, '') OR
+			$2 <% coalesce(author, '') OR
+			$2 <% coalesce(site_name, '') OR
+			$2 <% tags::text OR
+			$2 <% url`
+	}
+
+	// Full-privacy articles are never matched, as required by Index
+	searchQuery := fmt.Sprintf(`
+		SELECT id, %s AS score, created_at
+		FROM articles
+		WHERE user_id = $1 AND NOT is_private AND (
+			%s
+		)
+		ORDER BY score DESC, created_at DESC
+	`, score, match)
+
+	rows, err := p.db.Query(searchQuery, userUUID, query, "%"+EscapeLike(query)+"%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to search articles: %w", err)
+	}
+	defer rows.Close()
+
+	hits := []Hit{}
+	for rows.Next() {
+		var hit Hit
+		if err := rows.Scan(&hit.ArticleID, &hit.Score, &hit.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan search hit: %w", err)
+		}
+		hits = append(hits, hit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read search hits: %w", err)
+	}
+
+	return hits, nil
+}
+
+// Clear is a no-op for the PostgreSQL index
+func (p *PostgresIndex) Clear() error {
+	return nil
+}
+
+// Close is a no-op; the database connection is owned by the caller
+func (p *PostgresIndex) Close() error {
+	return nil
+}
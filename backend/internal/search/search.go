@@ -0,0 +1,115 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/readitlater/backend/internal/config"
+	"github.com/readitlater/backend/internal/database"
+)
+
+// Supported search index backends
+const (
+	BackendPostgres = "postgres"
+	BackendEmbedded = "embedded"
+)
+
+// Search modes, matching models.SearchModeFullText and models.SearchModeFuzzy
+const (
+	ModeFullText = "fulltext"
+	ModeFuzzy    = "fuzzy"
+)
+
+// Index is a full-text index over a user's articles. Every implementation
+// follows the same query semantics:
+//
+//   - fulltext: every stemmed, non-stopword query term occurs in the title,
+//     description or content, or the whole query occurs as a case-insensitive
+//     substring of the title, description, URL or tags
+//   - fuzzy: fulltext matches plus articles whose title, author, site name,
+//     tags or URL contain a word sequence with trigram word similarity of at
+//     least WordSimilarityThreshold to the query
+//
+// Full-privacy articles are never matched, on any field: their documents
+// are indexed with Private set and are left out of every search.
+//
+// Hits are ordered by score descending, then by creation time descending.
+// Scores are only comparable within one backend.
+type Index interface {
+	// Name returns the backend name
+	Name() string
+
+	// Index adds or replaces documents
+	Index(docs ...*Document) error
+
+	// Delete removes an article from the index
+	Delete(userID, articleID string) error
+
+	// Search returns the articles of a user matching a query
+	Search(userID, query, mode string) ([]Hit, error)
+
+	// Clear removes every document from the index
+	Clear() error
+
+	// Close releases resources held by the index
+	Close() error
+}
+
+// Document is the searchable representation of an article
+type Document struct {
+	ArticleID   string    `json:"article_id"`
+	UserID      string    `json:"user_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	ContentText string    `json:"content_text"`
+	URL         string    `json:"url"`
+	Author      string    `json:"author"`
+	SiteName    string    `json:"site_name"`
+	Tags        []string  `json:"tags"`
+	CreatedAt   time.Time `json:"created_at"`
+	Private     bool      `json:"private"`
+}
+
+// Hit is a single search result
+type Hit struct {
+	ArticleID string
+	Score     float64
+	CreatedAt time.Time
+}
+
+// New creates the search index configured by SEARCH_BACKEND
+func New(cfg *config.Config, db *database.DB) (Index, error) {
+	switch cfg.SearchBackend {
+	case BackendPostgres, "":
+		return NewPostgresIndex(db), nil
+	case BackendEmbedded:
+		return NewEmbeddedIndex(cfg.SearchIndexPath)
+	default:
+		return nil, fmt.Errorf("unsupported search backend: %s", cfg.SearchBackend)
+	}
+}
+
+// IsValidMode checks if the given search mode is supported
+func IsValidMode(mode string) bool {
+	return mode == ModeFullText || mode == ModeFuzzy
+}
+
+var htmlTag = regexp.MustCompile(`(?s)<script.*?</script>|<style.*?</style>|<[^>]*>`)
+
+// PlainText strips markup from captured HTML content for indexing
+func PlainText(content string) string {
+	return strings.Join(strings.Fields(htmlTag.ReplaceAllString(content, " ")), " ")
+}
+
+// sortHits orders hits by score, then by creation time, newest first
+func sortHits(hits []Hit) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].CreatedAt.After(hits[j].CreatedAt)
+	})
+}
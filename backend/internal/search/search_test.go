@@ -0,0 +1,131 @@
+package search
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/readitlater/backend/internal/database"
+)
+
+// testPrivateArticles checks that an index never matches full-privacy
+// articles on any field. add stores documents in the index the way the
+// backend is fed in production.
+func testPrivateArticles(t *testing.T, index Index, userID string, add func(t *testing.T, docs ...*Document)) {
+	now := time.Now()
+	public := &Document{
+		ArticleID:   uuid.New().String(),
+		UserID:      userID,
+		Title:       "Public notes",
+		ContentText: "quarterly figures",
+		URL:         "https://example.com/public",
+		Author:      "Ada Lovelace",
+		SiteName:    "Analytical Engine Weekly",
+		Tags:        []string{"mathematics"},
+		CreatedAt:   now.Add(-time.Hour),
+	}
+	private := &Document{
+		ArticleID:   uuid.New().String(),
+		UserID:      userID,
+		Title:       "Private notes",
+		Description: "confidential memo",
+		ContentText: "quarterly figures",
+		URL:         "https://example.com/private",
+		Author:      "Ada Lovelace",
+		SiteName:    "Analytical Engine Weekly",
+		Tags:        []string{"mathematics"},
+		CreatedAt:   now,
+		Private:     true,
+	}
+	add(t, public, private)
+
+	queries := []struct {
+		name, query, mode string
+	}{
+		{"Title", "Private notes", ModeFullText},
+		{"Description", "confidential", ModeFullText},
+		{"Content", "quarterly figures", ModeFullText},
+		{"URL", "example.com", ModeFullText},
+		{"Tags", "mathematics", ModeFullText},
+		{"Author", "Ada Lovelace", ModeFuzzy},
+		{"SiteName", "Analytical Engine", ModeFuzzy},
+	}
+	for _, q := range queries {
+		t.Run(q.name, func(t *testing.T) {
+			hits, err := index.Search(userID, q.query, q.mode)
+			if err != nil {
+				t.Fatalf("Search %q failed: %v", q.query, err)
+			}
+			for _, hit := range hits {
+				if hit.ArticleID == private.ArticleID {
+					t.Errorf("Expected search %q not to match the private article", q.query)
+				}
+			}
+		})
+	}
+
+	t.Run("PublicStillMatches", func(t *testing.T) {
+		hits, err := index.Search(userID, "quarterly figures", ModeFullText)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(hits) != 1 || hits[0].ArticleID != public.ArticleID {
+			t.Errorf("Expected only the public article, got %v", hits)
+		}
+	})
+}
+
+func TestPrivateArticlesEmbedded(t *testing.T) {
+	index, err := NewEmbeddedIndex(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	testPrivateArticles(t, index, uuid.New().String(), func(t *testing.T, docs ...*Document) {
+		if err := index.Index(docs...); err != nil {
+			t.Fatalf("Failed to index documents: %v", err)
+		}
+	})
+}
+
+// TestPrivateArticlesPostgres runs the privacy tests against a migrated
+// database when SEARCH_TEST_DATABASE_URL is set. The test user and its
+// articles are deleted afterwards.
+func TestPrivateArticlesPostgres(t *testing.T) {
+	databaseURL := os.Getenv("SEARCH_TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("SEARCH_TEST_DATABASE_URL not set")
+	}
+
+	db, err := database.New(databaseURL)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	userID := uuid.New().String()
+	_, err = db.Exec(`
+		INSERT INTO users (id, email, username, password_hash) VALUES ($1, $2, $3, 'x')
+	`, userID, userID+"@example.com", userID[:20])
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	defer db.Exec("DELETE FROM users WHERE id = $1", userID)
+
+	// The table is the index, so documents are stored as article rows
+	testPrivateArticles(t, NewPostgresIndex(db), userID, func(t *testing.T, docs ...*Document) {
+		for _, doc := range docs {
+			tags, _ := json.Marshal(doc.Tags)
+			_, err := db.Exec(`
+				INSERT INTO articles (id, user_id, title, description, content_text, url, author, site_name, tags, created_at, is_private)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			`, doc.ArticleID, doc.UserID, doc.Title, doc.Description, doc.ContentText, doc.URL,
+				doc.Author, doc.SiteName, string(tags), doc.CreatedAt, doc.Private)
+			if err != nil {
+				t.Fatalf("Failed to insert article: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// WordSimilarityThreshold is the minimum trigram word similarity for a fuzzy
+// match. It mirrors the pg_trgm.word_similarity_threshold default.
+const WordSimilarityThreshold = 0.6
+
+// stopwords are common English words ignored by full-text matching
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "from": true, "has": true,
+	"have": true, "he": true, "her": true, "his": true, "i": true, "if": true,
+	"in": true, "into": true, "is": true, "it": true, "its": true, "no": true,
+	"not": true, "of": true, "on": true, "or": true, "our": true, "she": true,
+	"so": true, "such": true, "that": true, "the": true, "their": true,
+	"then": true, "there": true, "these": true, "they": true, "this": true,
+	"to": true, "was": true, "we": true, "were": true, "what": true,
+	"when": true, "which": true, "who": true, "will": true, "with": true,
+	"you": true, "your": true,
+}
+
+// EscapeLike escapes LIKE wildcards in user input
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// words splits text into lower-cased alphanumeric words
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// terms returns the stemmed, non-stopword terms of a text
+func terms(text string) []string {
+	result := []string{}
+	for _, word := range words(text) {
+		if stopwords[word] {
+			continue
+		}
+		result = append(result, stem(word))
+	}
+	return result
+}
+
+// stem reduces an English word to a crude stem by stripping common
+// inflectional suffixes
+func stem(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "sses"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"), strings.HasSuffix(word, "is"):
+		return word
+	case strings.HasSuffix(word, "ing") && len(word) > 5:
+		return word[:len(word)-3]
+	case strings.HasSuffix(word, "ed") && len(word) > 4:
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "ly") && len(word) > 4:
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "s"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// trigrams returns the pg_trgm style trigram set of a sequence of words:
+// each word is padded with two leading spaces and one trailing space
+func trigrams(ws []string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range ws {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// similarity returns the Jaccard similarity of two trigram sets
+func similarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	common := 0
+	for trigram := range a {
+		if b[trigram] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// wordSimilarity returns the greatest trigram similarity between the query
+// and any run of consecutive words in the text, approximating pg_trgm's
+// word_similarity
+func wordSimilarity(query, text string) float64 {
+	queryTrigrams := trigrams(words(query))
+	textWords := words(text)
+	if len(queryTrigrams) == 0 || len(textWords) == 0 {
+		return 0
+	}
+
+	// Runs longer than the query plus one word cannot score higher
+	maxRun := len(words(query)) + 1
+
+	best := 0.0
+	for start := range textWords {
+		for end := start + 1; end <= len(textWords) && end-start <= maxRun; end++ {
+			if score := similarity(queryTrigrams, trigrams(textWords[start:end])); score > best {
+				best = score
+			}
+		}
+	}
+	return best
+}
+
+// tagsText renders tags the way PostgreSQL renders a JSONB array as text so
+// substring and similarity matches behave the same on both backends
+func tagsText(tags []string) string {
+	if len(tags) == 0 {
+		return "[]"
+	}
+	return `["` + strings.Join(tags, `", "`) + `"]`
+}
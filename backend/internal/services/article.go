@@ -1,44 +1,94 @@
 package services
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/config"
 	"github.com/readitlater/backend/internal/database"
+	"github.com/readitlater/backend/internal/encryption"
 	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/search"
 	"github.com/readitlater/backend/internal/storage"
 )
 
+// Errors returned by the article service
+var (
+	ErrFullPrivacyRequiresKey = errors.New("full privacy requires a user key")
+	ErrPrivateArticleFields   = errors.New("title, description, tags and category of a full-privacy article cannot be updated")
+	ErrArticleNotFound        = errors.New("article not found")
+	ErrArticleIDConflict      = errors.New("article ID is already in use")
+)
+
 // ArticleService handles article-related operations
 type ArticleService struct {
+	config         *config.Config
 	db             *database.DB
 	storageService *storage.Service
+	searchIndex    search.Index
+	encryption     *encryption.Service
 	logger         *logrus.Logger
 }
 
 // NewArticleService creates a new article service
-func NewArticleService(db *database.DB, storageService *storage.Service, logger *logrus.Logger) *ArticleService {
+func NewArticleService(cfg *config.Config, db *database.DB, storageService *storage.Service, searchIndex search.Index, logger *logrus.Logger) *ArticleService {
 	return &ArticleService{
+		config:         cfg,
 		db:             db,
 		storageService: storageService,
+		searchIndex:    searchIndex,
+		encryption:     encryption.NewService(cfg.EncryptionAlgorithm, cfg.KeyDerivationIterations),
 		logger:         logger,
 	}
 }
 
 // CreateArticle creates a new article
 func (s *ArticleService) CreateArticle(userID string, create *models.ArticleCreate) (*models.Article, error) {
+	if create.FullPrivacy && create.UserKey == "" && create.Encrypted == nil {
+		return nil, ErrFullPrivacyRequiresKey
+	}
+
 	// Generate article ID
 	articleID := uuid.New()
+	now := time.Now()
 	
 	// Parse user ID
 	userUUID, err := uuid.Parse(userID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid user ID: %w", err)
 	}
+
+	// Zero-knowledge clients encrypt content themselves, binding it to an
+	// article ID they generate
+	var clientContent *storage.ArticleContent
+	if create.Encrypted != nil {
+		if articleID, err = uuid.Parse(create.ID); err != nil {
+			return nil, fmt.Errorf("%w: invalid article ID: %v", ErrInvalidEncryptedContent, err)
+		}
+		if clientContent, err = clientEncryptedContent(userID, articleID, create, now); err != nil {
+			return nil, err
+		}
+	} else if create.ID != "" {
+		return nil, fmt.Errorf("%w: an article ID is only accepted with encrypted content", ErrInvalidEncryptedContent)
+	}
+
+	// Content must be encrypted with the key the user's other content uses
+	switch {
+	case create.UserKey != "":
+		if err := s.registerEncryptionKey(userUUID, create.UserKey); err != nil {
+			return nil, err
+		}
+	case clientContent != nil:
+		if err := s.registerKeyCheck(userUUID, create.KeyCheck); err != nil {
+			return nil, err
+		}
+	}
 	
 	// Create article model
 	article := &models.Article{
@@ -52,27 +102,36 @@ func (s *ArticleService) CreateArticle(userID string, create *models.ArticleCrea
 		IsRead:      false,
 		IsFavorite:  false,
 		IsArchived:  false,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		IsEncrypted:     create.UserKey != "" || clientContent != nil,
+		ClientEncrypted: clientContent != nil,
+		IsPrivate:       create.FullPrivacy,
+		CreatedAt:       now,
+		UpdatedAt:       now,
 	}
 
-	// Save to database
-	query := `
-		INSERT INTO articles (id, user_id, title, url, description, tags, category, is_read, is_favorite, is_archived, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-	`
-	
-	tagsJSON, _ := json.Marshal(create.Tags)
-	_, err = s.db.Exec(query, article.ID, article.UserID, article.Title, article.URL, 
-		article.Description, string(tagsJSON), article.Category, article.IsRead, article.IsFavorite, 
-		article.IsArchived, article.CreatedAt, article.UpdatedAt)
-	
+	// Keep a plain text copy for search unless the content is encrypted
+	if !article.IsEncrypted {
+		article.ContentText = search.PlainText(create.Content)
+	}
+
+	blindIndexJSON, err := marshalBlindIndex(create.BlindIndex)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create article in database: %w", err)
+		return nil, err
 	}
 
-	// Save content to storage if provided
-	if create.Content != "" {
+	// Encode content before touching the database so encryption errors
+	// leave nothing behind. Full-privacy articles always have a content
+	// file holding their encrypted fields.
+	var contentBytes []byte
+	if clientContent != nil {
+		contentJSON, err := json.Marshal(clientContent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize content: %w", err)
+		}
+		if contentBytes, err = s.storageService.EncodeContent(userID, articleID.String(), string(contentJSON)); err != nil {
+			return nil, fmt.Errorf("failed to encode content: %w", err)
+		}
+	} else if create.Content != "" || create.FullPrivacy {
 		storageContent := storage.ArticleContent{
 			ID:        articleID.String(),
 			UserID:    userID,
@@ -85,26 +144,127 @@ func (s *ArticleService) CreateArticle(userID string, create *models.ArticleCrea
 			CreatedAt: article.CreatedAt,
 			UpdatedAt: article.UpdatedAt,
 		}
+		if create.FullPrivacy {
+			storageContent.Description = create.Description
+			storageContent.Category = create.Category
+			storageContent.FullPrivacy = true
+		}
 
 		contentJSON, err := json.Marshal(storageContent)
 		if err != nil {
 			return nil, fmt.Errorf("failed to serialize content: %w", err)
 		}
 
-		// Save encrypted if user has encryption key
-		if create.UserKey != "" {
-			_, err = s.storageService.SaveEncryptedContent(userID, articleID.String(), string(contentJSON), create.UserKey)
-		} else {
-			_, err = s.storageService.SaveContent(userID, articleID.String(), string(contentJSON))
+		// Encrypt if user has encryption key, otherwise under the user's
+		// server-managed key when server-side encryption is enabled
+		switch {
+		case create.UserKey != "":
+			contentBytes, err = s.storageService.EncodeEncryptedContent(userID, articleID.String(), string(contentJSON), create.UserKey)
+		case s.config.ServerSideEncryption:
+			var serverKey string
+			if serverKey, err = s.serverKey(userUUID); err != nil {
+				return nil, err
+			}
+			contentBytes, err = s.storageService.EncodeServerEncryptedContent(userID, articleID.String(), string(contentJSON), serverKey)
+			article.ServerEncrypted = true
+		default:
+			contentBytes, err = s.storageService.EncodeContent(userID, articleID.String(), string(contentJSON))
 		}
-
 		if err != nil {
-			// Rollback database entry
-			s.db.Exec("DELETE FROM articles WHERE id = $1", articleID)
+			return nil, fmt.Errorf("failed to encode content: %w", err)
+		}
+	}
+
+	// The row of a full-privacy article holds the same ciphertext as its
+	// content file
+	if article.IsPrivate {
+		if err := setPrivateFields(article, contentBytes); err != nil {
+			return nil, err
+		}
+	}
+
+	// Rows with a local path are expected to have stored content. Large
+	// unencrypted bodies are shared with identical articles through a blob.
+	var contentHash string
+	if contentBytes != nil {
+		if article.LocalPath, err = storage.ContentKey(userID, articleID.String()); err != nil {
+			return nil, err
+		}
+		article.StorageSize = int64(len(contentBytes))
+
+		if contentHash, err = s.storageService.ContentHash(contentBytes); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The storage_size of the row is added to the user's storage_used by
+	// the update_storage_stats trigger
+	var usage *models.StorageUsage
+	if article.StorageSize > 0 {
+		if usage, err = s.reserveStorage(tx, userUUID, article.StorageSize); err != nil {
+			return nil, err
+		}
+	}
+
+	// Save to database
+	query := `
+		INSERT INTO articles (id, user_id, title, url, description, content_text, tags, category, is_read, is_favorite, is_archived, is_encrypted, is_private, private_fields, blind_index, local_path, storage_size, content_hash, created_at, updated_at, server_encrypted, client_encrypted)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NULLIF($14, ''), $15, NULLIF($16, ''), $17, NULLIF($18, ''), $19, $20, $21, $22)
+	`
+	
+	tagsJSON, _ := json.Marshal(article.Tags)
+	_, err = tx.Exec(query, article.ID, article.UserID, article.Title, article.URL, 
+		article.Description, article.ContentText, string(tagsJSON), article.Category, article.IsRead, article.IsFavorite, 
+		article.IsArchived, article.IsEncrypted, article.IsPrivate, article.PrivateFields, blindIndexJSON, article.LocalPath, article.StorageSize, contentHash, article.CreatedAt, article.UpdatedAt, article.ServerEncrypted, article.ClientEncrypted)
+	
+	// Only zero-knowledge clients choose IDs. The same error is returned
+	// whoever owns the article, so IDs of other users are not revealed.
+	if isUniqueViolation(err) {
+		return nil, ErrArticleIDConflict
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create article in database: %w", err)
+	}
+
+	if contentHash != "" {
+		if err := retainBlob(tx, contentHash, article.StorageSize); err != nil {
+			return nil, err
+		}
+	}
+
+	// The content write is committed with the article and replayed on
+	// startup if the process dies before it reaches storage
+	if contentBytes != nil {
+		if err := enqueueStorage(tx, userUUID, articleID, outboxWrite, contentBytes); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit article: %w", err)
+	}
+
+	if contentBytes != nil {
+		if err := s.flushStorageOutbox(articleID); err != nil {
+			if discardErr := s.discardArticle(articleID); discardErr != nil {
+				s.logger.WithError(discardErr).WithField("article_id", articleID.String()).Error("Failed to discard article, storage will be reconciled on restart")
+			}
 			return nil, fmt.Errorf("failed to save content to storage: %w", err)
 		}
 	}
 
+	if usage != nil {
+		s.warnStorageThreshold(userID, usage, article.StorageSize)
+	}
+
+	s.indexArticle(articleID)
+
 	s.logger.WithFields(logrus.Fields{
 		"user_id":    userID,
 		"article_id": articleID.String(),
@@ -114,6 +274,23 @@ func (s *ArticleService) CreateArticle(userID string, create *models.ArticleCrea
 	return article, nil
 }
 
+// setPrivateFields replaces the plaintext fields of a full-privacy article
+// with the ciphertext of its encoded content
+func setPrivateFields(article *models.Article, contentBytes []byte) error {
+	var content storage.ArticleContent
+	if err := json.Unmarshal(contentBytes, &content); err != nil {
+		return fmt.Errorf("failed to parse encrypted content: %w", err)
+	}
+
+	article.Title = content.Title
+	article.URL = content.URL
+	article.Description = content.Description
+	article.PrivateFields = content.PrivateFields
+	article.Tags = []string{}
+	article.Category = ""
+	return nil
+}
+
 // GetArticle retrieves an article by ID
 func (s *ArticleService) GetArticle(userID, articleID string) (*models.Article, error) {
 	// Parse IDs
@@ -128,7 +305,8 @@ func (s *ArticleService) GetArticle(userID, articleID string) (*models.Article,
 	}
 	
 	query := `
-		SELECT id, user_id, title, url, description, tags, category, is_read, is_favorite, is_archived, created_at, updated_at
+		SELECT id, user_id, title, url, description, tags, category, is_read, is_favorite, is_archived, created_at, updated_at,
+			is_private, coalesce(private_fields, ''), is_encrypted, server_encrypted, client_encrypted
 		FROM articles 
 		WHERE id = $1 AND user_id = $2
 	`
@@ -139,9 +317,13 @@ func (s *ArticleService) GetArticle(userID, articleID string) (*models.Article,
 	err = s.db.QueryRow(query, articleUUID, userUUID).Scan(
 		&article.ID, &article.UserID, &article.Title, &article.URL, &article.Description,
 		&tagsJSON, &article.Category, &article.IsRead, &article.IsFavorite, &article.IsArchived,
-		&article.CreatedAt, &article.UpdatedAt,
+		&article.CreatedAt, &article.UpdatedAt, &article.IsPrivate, &article.PrivateFields,
+		&article.IsEncrypted, &article.ServerEncrypted, &article.ClientEncrypted,
 	)
 	
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrArticleNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get article: %w", err)
 	}
@@ -156,55 +338,28 @@ func (s *ArticleService) GetArticle(userID, articleID string) (*models.Article,
 
 // GetArticles retrieves articles with filtering
 func (s *ArticleService) GetArticles(filter *models.ArticleFilter) ([]*models.Article, error) {
-	query := `
-		SELECT id, user_id, title, url, description, tags, category, is_read, is_favorite, is_archived, created_at, updated_at
-		FROM articles 
-		WHERE user_id = $1
-	`
-	args := []interface{}{filter.UserID}
-	argIndex := 2
-
-	// Apply filters
-	if filter.IsRead != nil {
-		query += fmt.Sprintf(" AND is_read = $%d", argIndex)
-		args = append(args, *filter.IsRead)
-		argIndex++
-	}
-
-	if filter.IsFavorite != nil {
-		query += fmt.Sprintf(" AND is_favorite = $%d", argIndex)
-		args = append(args, *filter.IsFavorite)
-		argIndex++
-	}
+	where, args := applyArticleFilter("user_id = $1", []interface{}{filter.UserID}, filter)
 
-	if filter.IsArchived != nil {
-		query += fmt.Sprintf(" AND is_archived = $%d", argIndex)
-		args = append(args, *filter.IsArchived)
-		argIndex++
-	}
-
-	if len(filter.Tags) > 0 {
-		// Search for any of the provided tags
-		for _, tag := range filter.Tags {
-			query += fmt.Sprintf(" AND tags LIKE $%d", argIndex)
-			args = append(args, "%"+tag+"%")
-			argIndex++
-		}
-	}
+	query := fmt.Sprintf(`
+		SELECT id, user_id, coalesce(title, ''), url, coalesce(description, ''), tags, coalesce(category, ''),
+			is_read, is_favorite, is_archived, created_at, updated_at, is_private, coalesce(private_fields, ''),
+			is_encrypted, server_encrypted, client_encrypted
+		FROM articles 
+		WHERE %s
+	`, where)
 
 	// Add ordering
-	query += " ORDER BY created_at DESC"
+	query += " ORDER BY " + articleOrder(filter, "created_at DESC")
 
 	// Add pagination
 	if filter.Limit > 0 {
-		query += fmt.Sprintf(" LIMIT $%d", argIndex)
 		args = append(args, filter.Limit)
-		argIndex++
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
 	}
 
 	if filter.Offset > 0 {
-		query += fmt.Sprintf(" OFFSET $%d", argIndex)
 		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
 	}
 
 	rows, err := s.db.Query(query, args...)
@@ -213,29 +368,35 @@ func (s *ArticleService) GetArticles(filter *models.ArticleFilter) ([]*models.Ar
 	}
 	defer rows.Close()
 
-	var articles []*models.Article
-	for rows.Next() {
-		var article models.Article
-		var tagsJSON string
+	return scanArticles(rows)
+}
 
-		err := rows.Scan(
-			&article.ID, &article.UserID, &article.Title, &article.URL, &article.Description,
-			&tagsJSON, &article.Category, &article.IsRead, &article.IsFavorite, &article.IsArchived,
-			&article.CreatedAt, &article.UpdatedAt,
-		)
+// FindArticles retrieves articles matching a filter, running a text search
+// when the filter carries a search query
+func (s *ArticleService) FindArticles(filter *models.ArticleFilter) ([]*models.Article, error) {
+	if filter.Search != "" {
+		return s.SearchArticles(filter.UserID.String(), filter.Search, filter)
+	}
+	return s.GetArticles(filter)
+}
+
+// CountArticles counts the articles matching a filter, including its search query
+func (s *ArticleService) CountArticles(filter *models.ArticleFilter) (int, error) {
+	if filter.Search != "" {
+		result, err := s.GetSearchFacets(filter.UserID.String(), filter.Search, filter, nil)
 		if err != nil {
-			continue
+			return 0, err
 		}
+		return result.Total, nil
+	}
 
-		// Parse tags
-		if tagsJSON != "" {
-			json.Unmarshal([]byte(tagsJSON), &article.Tags)
-		}
+	where, args := applyArticleFilter("user_id = $1", []interface{}{filter.UserID}, filter)
 
-		articles = append(articles, &article)
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM articles WHERE "+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count articles: %w", err)
 	}
-
-	return articles, nil
+	return count, nil
 }
 
 // UpdateArticle updates an article
@@ -250,6 +411,19 @@ func (s *ArticleService) UpdateArticle(userID, articleID string, update *models.
 	if err != nil {
 		return nil, fmt.Errorf("invalid article ID: %w", err)
 	}
+
+	// The encrypted fields of full-privacy articles are only written with
+	// their content
+	if update.Title != nil || update.Description != nil || update.Category != nil || update.Tags != nil {
+		var isPrivate bool
+		err := s.db.QueryRow("SELECT is_private FROM articles WHERE id = $1 AND user_id = $2", articleUUID, userUUID).Scan(&isPrivate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get article: %w", err)
+		}
+		if isPrivate {
+			return nil, ErrPrivateArticleFields
+		}
+	}
 	
 	// Build dynamic update query
 	setParts := []string{}
@@ -281,6 +455,16 @@ func (s *ArticleService) UpdateArticle(userID, articleID string, update *models.
 		argIndex++
 	}
 
+	if update.BlindIndex != nil {
+		blindIndexJSON, err := marshalBlindIndex(*update.BlindIndex)
+		if err != nil {
+			return nil, err
+		}
+		setParts = append(setParts, fmt.Sprintf("blind_index = $%d", argIndex))
+		args = append(args, blindIndexJSON)
+		argIndex++
+	}
+
 	if update.IsRead != nil {
 		setParts = append(setParts, fmt.Sprintf("is_read = $%d", argIndex))
 		args = append(args, *update.IsRead)
@@ -320,6 +504,8 @@ func (s *ArticleService) UpdateArticle(userID, articleID string, update *models.
 		return nil, fmt.Errorf("failed to update article: %w", err)
 	}
 
+	s.indexArticle(articleUUID)
+
 	return s.GetArticle(userID, articleID)
 }
 
@@ -336,24 +522,43 @@ func (s *ArticleService) DeleteArticle(userID, articleID string) error {
 		return fmt.Errorf("invalid article ID: %w", err)
 	}
 	
-	// Delete from storage first
-	err = s.storageService.DeleteContent(userID, articleID)
+	tx, err := s.db.Begin()
 	if err != nil {
-		s.logger.WithError(err).Warn("Failed to delete content from storage")
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
 	// Delete from database
-	query := "DELETE FROM articles WHERE id = $1 AND user_id = $2"
-	result, err := s.db.Exec(query, articleUUID, userUUID)
+	var contentHash sql.NullString
+	query := "DELETE FROM articles WHERE id = $1 AND user_id = $2 RETURNING content_hash"
+	err = tx.QueryRow(query, articleUUID, userUUID).Scan(&contentHash)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("article not found")
+	}
 	if err != nil {
 		return fmt.Errorf("failed to delete article from database: %w", err)
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return fmt.Errorf("article not found")
+	if err := releaseBlob(tx, contentHash); err != nil {
+		return err
+	}
+
+	// Content is deleted after the row so a failure never leaves an article
+	// without content; the delete is retried from the outbox
+	if err := enqueueStorage(tx, userUUID, articleUUID, outboxDelete, nil); err != nil {
+		return err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit article deletion: %w", err)
+	}
+
+	if err := s.flushStorageOutbox(articleUUID); err != nil {
+		s.logger.WithError(err).Warn("Failed to delete content from storage, will retry")
+	}
+
+	s.unindexArticle(userID, articleID)
+
 	s.logger.WithFields(logrus.Fields{
 		"user_id":    userID,
 		"article_id": articleID,
@@ -362,7 +567,9 @@ func (s *ArticleService) DeleteArticle(userID, articleID string) error {
 	return nil
 }
 
-// GetArticleContent retrieves the full content of an article
+// GetArticleContent retrieves the full content of an article. The content
+// of zero-knowledge articles is returned as ciphertext in Encrypted for the
+// client to decrypt.
 func (s *ArticleService) GetArticleContent(userID, articleID string) (*models.ArticleContent, error) {
 	// Get article metadata from database
 	article, err := s.GetArticle(userID, articleID)
@@ -370,8 +577,13 @@ func (s *ArticleService) GetArticleContent(userID, articleID string) (*models.Ar
 		return nil, err
 	}
 
-	// Get content from storage
-	content, err := s.storageService.GetContent(userID, articleID)
+	// Get content from storage, decrypting server-side encryption
+	var content string
+	if article.ServerEncrypted {
+		content, err = s.serverDecryptedContent(userID, articleID)
+	} else {
+		content, err = s.storageService.GetContent(userID, articleID)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get content from storage: %w", err)
 	}
@@ -381,23 +593,44 @@ func (s *ArticleService) GetArticleContent(userID, articleID string) (*models.Ar
 		return nil, fmt.Errorf("failed to parse stored content: %w", err)
 	}
 
+	if storageContent.IsEncrypted {
+		return &models.ArticleContent{
+			Article:   *article,
+			ID:        article.ID,
+			Metadata:  storageContent.Metadata,
+			Encrypted: encryptedArticleContent(&storageContent),
+		}, nil
+	}
+
 	return &models.ArticleContent{
-		Article: *article,
-		Content: storageContent.Content,
+		Article:  *article,
+		ID:       article.ID,
+		Content:  storageContent.Content,
+		Summary:  storageContent.Summary,
 		Metadata: storageContent.Metadata,
 	}, nil
 }
 
 // GetDecryptedArticleContent retrieves and decrypts the full content of an article
 func (s *ArticleService) GetDecryptedArticleContent(userID, articleID, userKey string) (*models.ArticleContent, error) {
+	if err := s.VerifyEncryptionKey(userID, userKey); err != nil && !errors.Is(err, ErrNoEncryptionKey) {
+		return nil, err
+	}
+
 	// Get article metadata from database
 	article, err := s.GetArticle(userID, articleID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get decrypted content from storage
-	content, err := s.storageService.GetDecryptedContent(userID, articleID, userKey)
+	// Get decrypted content from storage. Server-encrypted articles are
+	// decrypted with the user's server-managed key instead.
+	var content string
+	if article.ServerEncrypted {
+		content, err = s.serverDecryptedContent(userID, articleID)
+	} else {
+		content, err = s.storageService.GetDecryptedContent(userID, articleID, userKey)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get decrypted content from storage: %w", err)
 	}
@@ -407,9 +640,21 @@ func (s *ArticleService) GetDecryptedArticleContent(userID, articleID, userKey s
 		return nil, fmt.Errorf("failed to parse stored content: %w", err)
 	}
 
+	// Full-privacy articles keep only ciphertext in the database
+	if article.IsPrivate {
+		article.Title = storageContent.Title
+		article.URL = storageContent.URL
+		article.Description = storageContent.Description
+		article.Tags = storageContent.Tags
+		article.Category = storageContent.Category
+		article.PrivateFields = ""
+	}
+
 	return &models.ArticleContent{
-		Article: *article,
-		Content: storageContent.Content,
+		Article:  *article,
+		ID:       article.ID,
+		Content:  storageContent.Content,
+		Summary:  storageContent.Summary,
 		Metadata: storageContent.Metadata,
 	}, nil
 }
@@ -477,81 +722,6 @@ func (s *ArticleService) ToggleArchive(userID, articleID string) error {
 	return nil
 }
 
-// SearchArticles searches articles by text
-func (s *ArticleService) SearchArticles(userID, query string, filter *models.ArticleFilter) ([]*models.Article, error) {
-	// Parse user ID
-	userUUID, err := uuid.Parse(userID)
-	if err != nil {
-		return nil, fmt.Errorf("invalid user ID: %w", err)
-	}
-	
-	searchQuery := `
-		SELECT id, user_id, title, url, description, tags, category, is_read, is_favorite, is_archived, created_at, updated_at
-		FROM articles 
-		WHERE user_id = $1 AND (
-			title ILIKE $2 OR 
-			description ILIKE $2 OR 
-			url ILIKE $2 OR
-			tags ILIKE $2
-		)
-	`
-	args := []interface{}{userUUID, "%" + query + "%"}
-	argIndex := 3
-
-	// Apply additional filters
-	if filter != nil {
-		if filter.IsRead != nil {
-			searchQuery += fmt.Sprintf(" AND is_read = $%d", argIndex)
-			args = append(args, *filter.IsRead)
-			argIndex++
-		}
-
-		if filter.IsFavorite != nil {
-			searchQuery += fmt.Sprintf(" AND is_favorite = $%d", argIndex)
-			args = append(args, *filter.IsFavorite)
-			argIndex++
-		}
-
-		if filter.IsArchived != nil {
-			searchQuery += fmt.Sprintf(" AND is_archived = $%d", argIndex)
-			args = append(args, *filter.IsArchived)
-			argIndex++
-		}
-	}
-
-	searchQuery += " ORDER BY created_at DESC"
-
-	rows, err := s.db.Query(searchQuery, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to search articles: %w", err)
-	}
-	defer rows.Close()
-
-	var articles []*models.Article
-	for rows.Next() {
-		var article models.Article
-		var tagsJSON string
-
-		err := rows.Scan(
-			&article.ID, &article.UserID, &article.Title, &article.URL, &article.Description,
-			&tagsJSON, &article.Category, &article.IsRead, &article.IsFavorite, &article.IsArchived,
-			&article.CreatedAt, &article.UpdatedAt,
-		)
-		if err != nil {
-			continue
-		}
-
-		// Parse tags
-		if tagsJSON != "" {
-			json.Unmarshal([]byte(tagsJSON), &article.Tags)
-		}
-
-		articles = append(articles, &article)
-	}
-
-	return articles, nil
-}
-
 // GetStats retrieves article statistics for a user
 func (s *ArticleService) GetStats(userID string) (*models.ArticleStats, error) {
 	// Parse user ID
@@ -580,12 +750,20 @@ func (s *ArticleService) GetStats(userID string) (*models.ArticleStats, error) {
 
 	stats.UnreadArticles = stats.TotalArticles - stats.ReadArticles
 
-	// Get storage stats
-	storageStats, err := s.storageService.GetStorageStats(userID)
+	// Storage usage is kept up to date by the update_storage_stats trigger
+	usage, err := s.GetStorageUsage(userID)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to get storage usage")
+	} else {
+		stats.StorageUsed = usage.Used
+		stats.Storage = *usage
+	}
+
+	storageStats, err := s.GetStorageStats(userID)
 	if err != nil {
 		s.logger.WithError(err).Warn("Failed to get storage stats")
 	} else {
-		stats.StorageUsed = storageStats["total_size"].(int64)
+		stats.StorageStats = *storageStats
 	}
 
 	return &stats, nil
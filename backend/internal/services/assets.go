@@ -0,0 +1,191 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/readitlater/backend/internal/storage"
+)
+
+// Errors returned for article assets
+var (
+	ErrAssetNotFound    = errors.New("asset not found")
+	ErrAssetKeyRequired = errors.New("assets of articles encrypted under a user key need the user key")
+)
+
+// rowQuerier is a database or transaction that runs single-row queries
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// SaveAsset stores an asset of an article, such as a PDF or an image, from
+// r, replacing any asset with the same name. size is the number of bytes r
+// will produce; the stored asset counts against the user's quota. Assets
+// of articles encrypted under a user key are encrypted with userKey, and
+// those of server-encrypted articles with the user's server-managed key,
+// under the article's data key. When server-side encryption is enabled,
+// the content of an unencrypted article is encrypted first so its assets
+// are too. Other assets are stored as is.
+func (s *ArticleService) SaveAsset(userID, articleID, name string, r io.Reader, size int64, userKey string) (*storage.ObjectInfo, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+	articleUUID, err := uuid.Parse(articleID)
+	if err != nil {
+		return nil, ErrArticleNotFound
+	}
+
+	if s.config.ServerSideEncryption {
+		if _, err := s.serverEncryptArticle(articleUUID, userUUID); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Assets are written under the article's storage lock, so they cannot
+	// race a change to the content that holds their data key
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "storage_outbox:"+articleID); err != nil {
+		return nil, fmt.Errorf("failed to lock storage outbox: %w", err)
+	}
+
+	key, _, err := s.assetEncryptionKey(tx, userUUID, articleUUID, userKey)
+	if err != nil {
+		return nil, err
+	}
+
+	storedSize := size
+	if key != "" {
+		if storedSize, err = s.storageService.EncryptedAssetSize(size); err != nil {
+			return nil, err
+		}
+	}
+	var previousSize int64
+	previous, err := s.storageService.StatAsset(userID, articleID, name)
+	switch {
+	case err == nil:
+		previousSize = previous.Size
+	case !errors.Is(err, storage.ErrNotFound):
+		return nil, err
+	}
+
+	usage, err := s.reserveStorage(tx, userUUID, storedSize-previousSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var info *storage.ObjectInfo
+	if key == "" {
+		info, err = s.storageService.WriteAsset(userID, articleID, name, r, size)
+	} else {
+		info, err = s.storageService.WriteEncryptedAsset(userID, articleID, name, r, size, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("UPDATE articles SET assets_size = assets_size + $1 WHERE id = $2", info.Size-previousSize, articleUUID); err != nil {
+		return nil, fmt.Errorf("failed to record asset size: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.warnStorageThreshold(userID, usage, info.Size-previousSize)
+	return info, nil
+}
+
+// OpenAsset opens an asset of an article for range reads and returns it
+// with its modification time. Encrypted assets are decrypted as they are
+// read, with the keys SaveAsset encrypts them with. Assets saved before
+// their article was server-encrypted are returned as is until
+// EncryptExistingContent encrypts them.
+func (s *ArticleService) OpenAsset(userID, articleID, name, userKey string) (*io.SectionReader, time.Time, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("invalid user ID: %w", err)
+	}
+	articleUUID, err := uuid.Parse(articleID)
+	if err != nil {
+		return nil, time.Time{}, ErrArticleNotFound
+	}
+
+	key, plainAssets, err := s.assetEncryptionKey(s.db, userUUID, articleUUID, userKey)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	info, err := s.storageService.StatAsset(userID, articleID, name)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, time.Time{}, ErrAssetNotFound
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if plainAssets {
+		encrypted, err := s.storageService.AssetEncrypted(userID, articleID, name, key)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if !encrypted {
+			key = ""
+		}
+	}
+
+	if key == "" {
+		r, err := s.storageService.AssetReaderAt(userID, articleID, name)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		return r, info.ModTime, nil
+	}
+
+	r, err := s.storageService.EncryptedAssetReaderAt(userID, articleID, name, key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return io.NewSectionReader(r, 0, r.Size()), info.ModTime, nil
+}
+
+// assetEncryptionKey returns the key an article's assets are encrypted
+// with: userKey for articles encrypted under a user key, the user's
+// server-managed key for server-encrypted articles, and an empty key for
+// articles whose assets are stored as is. It also reports whether some
+// assets of a server-encrypted article may still be stored as is.
+func (s *ArticleService) assetEncryptionKey(db rowQuerier, userID, articleID uuid.UUID, userKey string) (string, bool, error) {
+	var isEncrypted, serverEncrypted, plainAssets bool
+	err := db.QueryRow(`
+		SELECT coalesce(is_encrypted, false), server_encrypted, plain_assets FROM articles WHERE id = $1 AND user_id = $2
+	`, articleID, userID).Scan(&isEncrypted, &serverEncrypted, &plainAssets)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, ErrArticleNotFound
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get article: %w", err)
+	}
+
+	switch {
+	case serverEncrypted:
+		serverKey, err := s.loadServerKey(userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, fmt.Errorf("server-encrypted article has no server key")
+		}
+		return serverKey, plainAssets, err
+	case isEncrypted:
+		if userKey == "" {
+			return "", false, ErrAssetKeyRequired
+		}
+		return userKey, false, nil
+	}
+	return "", false, nil
+}
@@ -0,0 +1,503 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/database"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/search"
+	"github.com/readitlater/backend/internal/storage"
+)
+
+// Errors returned by the backup service
+var (
+	ErrBackupDisabled      = errors.New("cloud backup is not enabled")
+	ErrBackupInProgress    = errors.New("a backup is already running")
+	ErrBackupNotFound      = errors.New("backup not found")
+	ErrBackupNotRestorable = errors.New("backup is not available for restore")
+	ErrArticleNotInBackup  = errors.New("article not found in backup")
+	ErrArticleNotRestored  = errors.New("article was deleted or its encryption changed since the backup")
+)
+
+// BackupTimeout bounds how long a backup may run. A backup still marked
+// running after that was interrupted by a crash.
+const BackupTimeout = 6 * time.Hour
+
+// backupColumns are the columns selected by scanBackup
+const backupColumns = `id, user_id, coalesce(snapshot_id, ''), status, object_count, total_size,
+	uploaded_count, uploaded_size, error, started_at, completed_at`
+
+// BackupService runs cloud backups and restores and records their history
+type BackupService struct {
+	db             *database.DB
+	backup         *storage.Backup
+	articleService *ArticleService
+	logger         *logrus.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewBackupService creates a new backup service. backup is nil when cloud
+// backup is disabled on the server.
+func NewBackupService(db *database.DB, backup *storage.Backup, articleService *ArticleService, logger *logrus.Logger) *BackupService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &BackupService{
+		db:             db,
+		backup:         backup,
+		articleService: articleService,
+		logger:         logger,
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// StartBackup records a new backup run for a user and runs it in the
+// background. Users must have enabled cloud backup in their settings.
+func (s *BackupService) StartBackup(userID string) (*models.Backup, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	if s.backup == nil {
+		return nil, ErrBackupDisabled
+	}
+
+	var enabled bool
+	err = s.db.QueryRow("SELECT coalesce(enable_cloud_backup, false) FROM users WHERE id = $1", userUUID).Scan(&enabled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backup settings: %w", err)
+	}
+	if !enabled {
+		return nil, ErrBackupDisabled
+	}
+
+	// A backup left running by a crashed server would otherwise block the
+	// user until the next restart
+	if err := s.FailStaleBackups(BackupTimeout); err != nil {
+		return nil, err
+	}
+
+	row := s.db.QueryRow(`
+		INSERT INTO backups (user_id, status) VALUES ($1, $2)
+		RETURNING `+backupColumns, userUUID, models.BackupStatusRunning)
+	backup, err := scanBackup(row)
+	if isUniqueViolation(err) {
+		return nil, ErrBackupInProgress
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.runBackup(backup)
+	}()
+
+	return backup, nil
+}
+
+// Stop cancels running backups and waits for them to record their outcome
+func (s *BackupService) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// runBackup takes a snapshot and records its outcome
+func (s *BackupService) runBackup(backup *models.Backup) {
+	logger := s.logger.WithFields(logrus.Fields{
+		"user_id":   backup.UserID.String(),
+		"backup_id": backup.ID.String(),
+	})
+
+	ctx, cancel := context.WithTimeout(s.ctx, BackupTimeout)
+	defer cancel()
+
+	snapshot, pruned, err := s.backup.Run(ctx, backup.UserID.String())
+	if err != nil && snapshot == nil {
+		message := err.Error()
+		switch {
+		case errors.Is(err, context.Canceled):
+			message = "interrupted by server shutdown"
+		case errors.Is(err, context.DeadlineExceeded):
+			message = "timed out"
+		}
+		logger.WithError(err).Error("Cloud backup failed")
+		_, dbErr := s.db.Exec(`
+			UPDATE backups SET status = $1, error = $2, completed_at = $3 WHERE id = $4
+		`, models.BackupStatusFailed, message, time.Now(), backup.ID)
+		if dbErr != nil {
+			logger.WithError(dbErr).Error("Failed to record backup failure")
+		}
+		return
+	}
+
+	// A retention failure leaves a usable snapshot behind
+	if err != nil {
+		logger.WithError(err).Warn("Failed to apply backup retention policy")
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE backups SET status = $1, snapshot_id = $2, object_count = $3, total_size = $4,
+			uploaded_count = $5, uploaded_size = $6, completed_at = $7, master_key_id = $8
+		WHERE id = $9
+	`, models.BackupStatusCompleted, snapshot.ID, snapshot.ObjectCount, snapshot.TotalSize,
+		snapshot.Uploaded, snapshot.UploadedSize, time.Now(), snapshot.MasterKeyID, backup.ID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to record backup")
+		return
+	}
+
+	if len(pruned) > 0 {
+		_, err = s.db.Exec(`
+			UPDATE backups SET status = $1 WHERE user_id = $2 AND snapshot_id = ANY($3)
+		`, models.BackupStatusExpired, backup.UserID, pq.Array(pruned))
+		if err != nil {
+			logger.WithError(err).Warn("Failed to mark expired backups")
+		}
+	}
+
+	logger.WithFields(logrus.Fields{
+		"snapshot_id":    snapshot.ID,
+		"object_count":   snapshot.ObjectCount,
+		"uploaded_count": snapshot.Uploaded,
+		"uploaded_size":  snapshot.UploadedSize,
+	}).Info("Cloud backup completed successfully")
+}
+
+// FailStaleBackups marks backups still running after maxAge as failed so
+// new backups can start. Backups cannot outlive BackupTimeout, so these
+// were interrupted by a crash; backups other replicas are running are
+// left alone.
+func (s *BackupService) FailStaleBackups(maxAge time.Duration) error {
+	_, err := s.db.Exec(`
+		UPDATE backups SET status = $1, error = 'interrupted', completed_at = $2
+		WHERE status = $3 AND started_at < $4
+	`, models.BackupStatusFailed, time.Now(), models.BackupStatusRunning, time.Now().Add(-maxAge))
+	if err != nil {
+		return fmt.Errorf("failed to fail interrupted backups: %w", err)
+	}
+	return nil
+}
+
+// GetBackups retrieves a user's backup history, newest first
+func (s *BackupService) GetBackups(userID string, limit int) ([]*models.Backup, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+backupColumns+` FROM backups
+		WHERE user_id = $1
+		ORDER BY started_at DESC
+		LIMIT $2
+	`, userUUID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backups: %w", err)
+	}
+	defer rows.Close()
+
+	backups := []*models.Backup{}
+	for rows.Next() {
+		backup, err := scanBackup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan backup: %w", err)
+		}
+		backups = append(backups, backup)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read backups: %w", err)
+	}
+
+	return backups, nil
+}
+
+// GetBackup retrieves a specific backup run
+func (s *BackupService) GetBackup(userID, backupID string) (*models.Backup, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	backupUUID, err := uuid.Parse(backupID)
+	if err != nil {
+		return nil, ErrBackupNotFound
+	}
+
+	row := s.db.QueryRow("SELECT "+backupColumns+" FROM backups WHERE id = $1 AND user_id = $2", backupUUID, userUUID)
+	backup, err := scanBackup(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBackupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backup: %w", err)
+	}
+
+	return backup, nil
+}
+
+// RestoreBackup restores a whole backup, or a single article when
+// restore.ArticleID is set, over the user's current content. It returns the
+// number of restored files. Content is saved through the storage outbox
+// like any other save, so storage usage and shared blobs stay accurate.
+// Articles deleted since the backup are not recreated, and articles whose
+// encryption changed since are skipped, as their rows no longer describe
+// the backed up content. Content backed up before a key rotation still
+// needs the old key.
+func (s *BackupService) RestoreBackup(userID, backupID string, restore *models.BackupRestore) (int, error) {
+	if s.backup == nil {
+		return 0, ErrBackupDisabled
+	}
+
+	backup, err := s.GetBackup(userID, backupID)
+	if err != nil {
+		return 0, err
+	}
+
+	if backup.Status != models.BackupStatusCompleted {
+		return 0, ErrBackupNotRestorable
+	}
+
+	if restore.ArticleID != "" {
+		if _, err := uuid.Parse(restore.ArticleID); err != nil {
+			return 0, ErrArticleNotInBackup
+		}
+	}
+
+	restored := 0
+	skipped := map[string]bool{}
+	err = s.backup.Restore(userID, backup.SnapshotID, restore.ArticleID, func(object *storage.RestoredObject) error {
+		if skipped[object.ArticleID] {
+			return nil
+		}
+
+		var ok bool
+		var err error
+		if object.Asset == "" {
+			ok, err = s.articleService.restoreContent(backup.UserID, object.ArticleID, object.Data)
+		} else {
+			ok, err = s.articleService.restoreAsset(backup.UserID, object.ArticleID, object.Asset, object.Data)
+		}
+		if err != nil {
+			return err
+		}
+		if !ok {
+			skipped[object.ArticleID] = true
+			return nil
+		}
+		restored++
+		return nil
+	})
+	if errors.Is(err, storage.ErrSnapshotNotFound) {
+		return 0, ErrBackupNotRestorable
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		return 0, ErrArticleNotInBackup
+	}
+	if err != nil {
+		return restored, fmt.Errorf("failed to restore backup: %w", err)
+	}
+	if restore.ArticleID != "" && restored == 0 {
+		return 0, ErrArticleNotRestored
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"backup_id":  backupID,
+		"article_id": restore.ArticleID,
+		"restored":   restored,
+		"skipped":    len(skipped),
+	}).Info("Backup restored successfully")
+
+	return restored, nil
+}
+
+// restoreContent saves an article's backed up content file in place of its
+// current content. It returns false without restoring anything when the
+// article was deleted or its encryption changed since the backup.
+func (s *ArticleService) restoreContent(userID uuid.UUID, articleID string, contentBytes []byte) (bool, error) {
+	var content storage.ArticleContent
+	if err := json.Unmarshal(contentBytes, &content); err != nil {
+		return false, fmt.Errorf("failed to parse article content: %w", err)
+	}
+
+	articleUUID, err := uuid.Parse(articleID)
+	if err != nil {
+		return false, fmt.Errorf("invalid article ID: %w", err)
+	}
+	localPath, err := storage.ContentKey(userID.String(), articleID)
+	if err != nil {
+		return false, err
+	}
+	contentHash, err := s.storageService.ContentHash(contentBytes)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "storage_outbox:"+articleID); err != nil {
+		return false, fmt.Errorf("failed to lock storage outbox: %w", err)
+	}
+
+	var encrypted, serverEncrypted, private bool
+	var storageSize int64
+	var previousHash sql.NullString
+	err = tx.QueryRow(`
+		SELECT coalesce(is_encrypted, false), server_encrypted, is_private, coalesce(storage_size, 0), content_hash
+		FROM articles WHERE id = $1 AND user_id = $2
+		FOR UPDATE
+	`, articleUUID, userID).Scan(&encrypted, &serverEncrypted, &private, &storageSize, &previousHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("failed to get article: %w", err)
+	}
+	if errors.Is(err, sql.ErrNoRows) || encrypted != content.IsEncrypted || serverEncrypted != content.ServerEncrypted || private != content.FullPrivacy {
+		return false, nil
+	}
+
+	size := int64(len(contentBytes))
+	if growth := size - storageSize; growth > 0 {
+		if _, err := s.reserveStorage(tx, userID, growth); err != nil {
+			return false, err
+		}
+	}
+
+	if err := releaseBlob(tx, previousHash); err != nil {
+		return false, err
+	}
+	if contentHash != "" {
+		if err := retainBlob(tx, contentHash, size); err != nil {
+			return false, err
+		}
+	}
+
+	// Encrypted articles keep no plain text copy for search
+	var contentText string
+	if !content.IsEncrypted {
+		contentText = search.PlainText(content.Content)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE articles SET local_path = $1, storage_size = $2, content_hash = NULLIF($3, ''), content_text = $4, updated_at = $5
+		WHERE id = $6
+	`, localPath, size, contentHash, contentText, time.Now(), articleUUID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record restored content: %w", err)
+	}
+
+	if err := enqueueStorage(tx, userID, articleUUID, outboxWrite, contentBytes); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit restored content: %w", err)
+	}
+
+	// A failed write stays in the outbox and is replayed on startup
+	if err := s.flushStorageOutbox(articleUUID); err != nil {
+		return false, fmt.Errorf("failed to save content to storage: %w", err)
+	}
+
+	s.indexArticle(articleUUID)
+	return true, nil
+}
+
+// restoreAsset saves a backed up asset of an article under the article's
+// storage lock. It returns false when the article was deleted since the
+// backup.
+func (s *ArticleService) restoreAsset(userID uuid.UUID, articleID, name string, data []byte) (bool, error) {
+	articleUUID, err := uuid.Parse(articleID)
+	if err != nil {
+		return false, fmt.Errorf("invalid article ID: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "storage_outbox:"+articleID); err != nil {
+		return false, fmt.Errorf("failed to lock storage outbox: %w", err)
+	}
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM articles WHERE id = $1 AND user_id = $2)", articleUUID, userID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to get article: %w", err)
+	}
+	if !exists {
+		return false, nil
+	}
+
+	var previousSize int64
+	previous, err := s.storageService.StatAsset(userID.String(), articleID, name)
+	switch {
+	case err == nil:
+		previousSize = previous.Size
+	case !errors.Is(err, storage.ErrNotFound):
+		return false, err
+	}
+
+	info, err := s.storageService.WriteAsset(userID.String(), articleID, name, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return false, err
+	}
+
+	// An asset backed up before its article was server-encrypted is
+	// restored as is, until EncryptExistingContent encrypts it
+	_, err = tx.Exec(`
+		UPDATE articles SET assets_size = assets_size + $1, plain_assets = plain_assets OR server_encrypted WHERE id = $2
+	`, info.Size-previousSize, articleUUID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record asset size: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// scanBackup scans a backup selected with backupColumns
+func scanBackup(row rowScanner) (*models.Backup, error) {
+	var backup models.Backup
+	var completedAt sql.NullTime
+
+	err := row.Scan(&backup.ID, &backup.UserID, &backup.SnapshotID, &backup.Status, &backup.ObjectCount,
+		&backup.TotalSize, &backup.UploadedCount, &backup.UploadedSize, &backup.Error, &backup.StartedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if completedAt.Valid {
+		backup.CompletedAt = &completedAt.Time
+	}
+	return &backup, nil
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique constraint
+// violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
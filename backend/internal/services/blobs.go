@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// blobCollectionBatch is the number of unreferenced blobs removed per
+// CollectBlobs call
+const blobCollectionBatch = 1000
+
+// retainBlob records another article referring to a content blob
+func retainBlob(tx *sql.Tx, hash string, size int64) error {
+	_, err := tx.Exec(`
+		INSERT INTO content_blobs (hash, size, ref_count)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (hash) DO UPDATE
+		SET ref_count = content_blobs.ref_count + 1, updated_at = CURRENT_TIMESTAMP
+	`, hash, size)
+	if err != nil {
+		return fmt.Errorf("failed to reference content blob: %w", err)
+	}
+	return nil
+}
+
+// releaseBlob drops a reference to a content blob. The blob itself is
+// removed later by CollectBlobs.
+func releaseBlob(tx *sql.Tx, hash sql.NullString) error {
+	if !hash.Valid || hash.String == "" {
+		return nil
+	}
+
+	_, err := tx.Exec(`
+		UPDATE content_blobs SET ref_count = ref_count - 1, updated_at = CURRENT_TIMESTAMP WHERE hash = $1
+	`, hash.String)
+	if err != nil {
+		return fmt.Errorf("failed to release content blob: %w", err)
+	}
+	return nil
+}
+
+// CollectBlobs deletes content blobs no article refers to any more and
+// returns how many were removed
+func (s *ArticleService) CollectBlobs(ctx context.Context) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT hash FROM content_blobs WHERE ref_count <= 0 ORDER BY updated_at LIMIT $1
+	`, blobCollectionBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get unreferenced blobs: %w", err)
+	}
+
+	hashes := []string{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan blob: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read unreferenced blobs: %w", err)
+	}
+
+	collected := 0
+	for _, hash := range hashes {
+		if err := ctx.Err(); err != nil {
+			return collected, err
+		}
+
+		removed, err := s.collectBlob(hash)
+		if err != nil {
+			s.logger.WithError(err).WithField("hash", hash).Warn("Failed to collect content blob")
+			continue
+		}
+		if removed {
+			collected++
+		}
+	}
+
+	if collected > 0 {
+		s.logger.WithFields(logrus.Fields{
+			"blobs": collected,
+		}).Info("Collected unreferenced content blobs")
+	}
+
+	return collected, nil
+}
+
+// collectBlob deletes a blob if it is still unreferenced. Its row stays
+// locked while the object is deleted, so an article saving the same body
+// concurrently waits and then writes the blob again.
+func (s *ArticleService) collectBlob(hash string) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked string
+	err = tx.QueryRow(`
+		SELECT hash FROM content_blobs WHERE hash = $1 AND ref_count <= 0 FOR UPDATE
+	`, hash).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock content blob: %w", err)
+	}
+
+	if err := s.storageService.DeleteBlob(hash); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM content_blobs WHERE hash = $1", hash); err != nil {
+		return false, fmt.Errorf("failed to delete content blob: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit blob collection: %w", err)
+	}
+	return true, nil
+}
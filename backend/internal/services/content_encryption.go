@@ -0,0 +1,366 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/storage"
+)
+
+// defaultServerEncryptionBatch is the number of articles encrypted between
+// progress reports when no batch size is given
+const defaultServerEncryptionBatch = 500
+
+// unencryptedCondition selects articles with content or assets stored as
+// is. Full-privacy articles are always encrypted under a user key.
+const unencryptedCondition = `((NOT coalesce(is_encrypted, false) AND NOT server_encrypted AND NOT is_private
+	AND (local_path IS NOT NULL OR assets_size > 0)) OR plain_assets)`
+
+// EncryptExistingContent encrypts the content and assets of articles saved
+// before server-side encryption was enabled under their users'
+// server-managed keys, batch by batch, calling progress after each batch.
+// Content stays readable throughout. Each article is encrypted under the
+// same lock as its storage writes and recorded in its row, so a run can be
+// stopped at any point and started again to resume.
+func (s *ArticleService) EncryptExistingContent(ctx context.Context, options *models.ServerEncryptionOptions, progress func(*models.ServerEncryptionProgress)) (*models.ServerEncryptionProgress, error) {
+	if !s.config.ServerSideEncryption {
+		return nil, fmt.Errorf("server-side encryption is not enabled")
+	}
+	if options.UserID != "" {
+		if _, err := uuid.Parse(options.UserID); err != nil {
+			return nil, fmt.Errorf("invalid user ID: %w", err)
+		}
+	}
+	batchSize := options.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultServerEncryptionBatch
+	}
+
+	started := time.Now()
+	report := &models.ServerEncryptionProgress{}
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM articles WHERE `+unencryptedCondition+` AND ($1 = '' OR user_id::text = $1)
+	`, options.UserID).Scan(&report.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count articles to encrypt: %w", err)
+	}
+	if options.DryRun || report.Total == 0 {
+		return report, nil
+	}
+
+	after := uuid.Nil
+	for {
+		batch, err := s.nextUnencryptedBatch(ctx, options.UserID, after, batchSize)
+		if err != nil {
+			return report, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, article := range batch {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+
+			encrypted, err := s.serverEncryptArticle(article.ID, article.UserID)
+			if err == nil {
+				var assets bool
+				assets, err = s.encryptPlainAssets(article.ID, article.UserID)
+				encrypted = encrypted || assets
+			}
+			switch {
+			case err != nil:
+				report.Failed++
+				s.logger.WithError(err).WithField("article_id", article.ID).Warn("Failed to encrypt article content")
+			case encrypted:
+				report.Encrypted++
+			default:
+				report.Skipped++
+			}
+			after = article.ID
+		}
+
+		report.Elapsed = time.Since(started)
+		if progress != nil {
+			progress(report)
+		}
+
+		if options.Pause > 0 {
+			select {
+			case <-ctx.Done():
+				return report, ctx.Err()
+			case <-time.After(options.Pause):
+			}
+		}
+	}
+
+	report.Elapsed = time.Since(started)
+	s.logger.WithFields(logrus.Fields{
+		"encrypted": report.Encrypted,
+		"skipped":   report.Skipped,
+		"failed":    report.Failed,
+	}).Info("Server-side encryption of existing content completed")
+
+	return report, nil
+}
+
+// unencryptedArticle is an article waiting to be encrypted
+type unencryptedArticle struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+// nextUnencryptedBatch returns the next articles with content or assets
+// stored as is after a cursor
+func (s *ArticleService) nextUnencryptedBatch(ctx context.Context, userID string, after uuid.UUID, limit int) ([]unencryptedArticle, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id
+		FROM articles
+		WHERE `+unencryptedCondition+` AND ($1 = '' OR user_id::text = $1) AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`, userID, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get articles to encrypt: %w", err)
+	}
+	defer rows.Close()
+
+	batch := []unencryptedArticle{}
+	for rows.Next() {
+		var article unencryptedArticle
+		if err := rows.Scan(&article.ID, &article.UserID); err != nil {
+			return nil, fmt.Errorf("failed to scan article: %w", err)
+		}
+		batch = append(batch, article)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read articles to encrypt: %w", err)
+	}
+
+	return batch, nil
+}
+
+// serverEncryptArticle encrypts the content of an unencrypted article under
+// the user's server-managed key. An article without content is given a
+// content file, which holds the data key its assets are encrypted under.
+// Assets are left for encryptPlainAssets. It returns false when the
+// article no longer needs encrypting.
+func (s *ArticleService) serverEncryptArticle(articleID, userID uuid.UUID) (bool, error) {
+	// Apply writes left over from earlier requests first, so the content
+	// encrypted below is the latest
+	if err := s.flushStorageOutbox(articleID); err != nil {
+		return false, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "storage_outbox:"+articleID.String()); err != nil {
+		return false, fmt.Errorf("failed to lock storage outbox: %w", err)
+	}
+
+	entries, err := pendingStorageOperations(tx, articleID)
+	if err != nil {
+		return false, err
+	}
+	if len(entries) > 0 {
+		return false, nil
+	}
+
+	var encrypted, serverEncrypted, private bool
+	var localPath, contentHash sql.NullString
+	var storageSize int64
+	var title, url string
+	var createdAt, updatedAt time.Time
+	err = tx.QueryRow(`
+		SELECT coalesce(is_encrypted, false), server_encrypted, is_private, local_path, coalesce(storage_size, 0), content_hash,
+			coalesce(title, ''), url, created_at, updated_at
+		FROM articles WHERE id = $1 AND user_id = $2
+		FOR UPDATE
+	`, articleID, userID).Scan(&encrypted, &serverEncrypted, &private, &localPath, &storageSize, &contentHash,
+		&title, &url, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get article: %w", err)
+	}
+	if encrypted || serverEncrypted || private {
+		return false, nil
+	}
+
+	var content string
+	if localPath.Valid {
+		if content, err = s.storageService.GetContent(userID.String(), articleID.String()); err != nil {
+			return false, err
+		}
+	} else {
+		contentJSON, err := json.Marshal(storage.ArticleContent{
+			ID:        articleID.String(),
+			UserID:    userID.String(),
+			Title:     title,
+			URL:       url,
+			CreatedAt: createdAt,
+			UpdatedAt: updatedAt,
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to serialize content: %w", err)
+		}
+		content = string(contentJSON)
+	}
+
+	serverKey, err := s.serverKey(userID)
+	if err != nil {
+		return false, err
+	}
+	contentBytes, err := s.storageService.EncodeServerEncryptedContent(userID.String(), articleID.String(), content, serverKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode content: %w", err)
+	}
+	key, err := storage.ContentKey(userID.String(), articleID.String())
+	if err != nil {
+		return false, err
+	}
+
+	// Encrypted content is larger and no longer shares a blob
+	size := int64(len(contentBytes))
+	if growth := size - storageSize; growth > 0 {
+		if _, err := s.reserveStorage(tx, userID, growth); err != nil {
+			return false, err
+		}
+	}
+	if err := releaseBlob(tx, contentHash); err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE articles SET server_encrypted = true, local_path = $1, storage_size = $2, content_hash = NULL, plain_assets = assets_size > 0
+		WHERE id = $3
+	`, key, size, articleID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record encrypted content: %w", err)
+	}
+
+	if err := enqueueStorage(tx, userID, articleID, outboxWrite, contentBytes); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// A failed write stays in the outbox and is replayed on startup
+	if err := s.flushStorageOutbox(articleID); err != nil {
+		return false, fmt.Errorf("failed to save content to storage: %w", err)
+	}
+	return true, nil
+}
+
+// encryptPlainAssets encrypts the assets of a server-encrypted article
+// that are still stored as they were saved before its content was
+// encrypted. assets_size is recomputed from the stored assets, so an
+// interrupted run is corrected by the next one. It returns false when the
+// article has no such assets.
+func (s *ArticleService) encryptPlainAssets(articleID, userID uuid.UUID) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "storage_outbox:"+articleID.String()); err != nil {
+		return false, fmt.Errorf("failed to lock storage outbox: %w", err)
+	}
+
+	// Assets are encrypted under the data key of the stored content
+	entries, err := pendingStorageOperations(tx, articleID)
+	if err != nil {
+		return false, err
+	}
+	if len(entries) > 0 {
+		return false, nil
+	}
+
+	var plainAssets bool
+	var assetsSize int64
+	err = tx.QueryRow(`
+		SELECT plain_assets, assets_size FROM articles WHERE id = $1 AND user_id = $2 FOR UPDATE
+	`, articleID, userID).Scan(&plainAssets, &assetsSize)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get article: %w", err)
+	}
+	if !plainAssets {
+		return false, nil
+	}
+
+	serverKey, err := s.loadServerKey(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("server-encrypted article has no server key")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	names, err := s.storageService.ListAssets(userID.String(), articleID.String())
+	if err != nil {
+		return false, err
+	}
+	plain := []string{}
+	var total int64
+	for _, name := range names {
+		info, err := s.storageService.StatAsset(userID.String(), articleID.String(), name)
+		if err != nil {
+			return false, err
+		}
+		encrypted, err := s.storageService.AssetEncrypted(userID.String(), articleID.String(), name, serverKey)
+		if err != nil {
+			return false, err
+		}
+		if encrypted {
+			total += info.Size
+			continue
+		}
+
+		size, err := s.storageService.EncryptedAssetSize(info.Size)
+		if err != nil {
+			return false, err
+		}
+		total += size
+		plain = append(plain, name)
+	}
+
+	if growth := total - assetsSize; growth > 0 {
+		if _, err := s.reserveStorage(tx, userID, growth); err != nil {
+			return false, err
+		}
+	}
+	for _, name := range plain {
+		if _, err := s.storageService.EncryptAsset(userID.String(), articleID.String(), name, serverKey); err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE articles SET plain_assets = false, assets_size = $1 WHERE id = $2", total, articleID); err != nil {
+		return false, fmt.Errorf("failed to record encrypted assets: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/database"
+	"github.com/readitlater/backend/internal/encryption"
+	"github.com/readitlater/backend/internal/models"
+)
+
+// Errors returned by the encrypted search index service
+var (
+	ErrEncryptedIndexNotFound = errors.New("encrypted search index not found")
+	ErrEncryptedIndexConflict = errors.New("encrypted search index has a newer version")
+)
+
+// encryptedIndexRetention is the number of index versions kept per user
+const encryptedIndexRetention = 5
+
+// maxEncryptedIndexSize caps the size of an uploaded index blob
+const maxEncryptedIndexSize = 64 << 20
+
+// SearchBlindIndex returns the encrypted articles whose client-uploaded blind
+// index contains every query token. Tokens are computed by the client with
+// encryption.BlindTokens; the server only compares opaque values.
+func (s *ArticleService) SearchBlindIndex(userID string, tokens []string, filter *models.ArticleFilter) ([]*models.Article, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("at least one blind index token is required")
+	}
+
+	tokensJSON, err := marshalBlindIndex(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := applyArticleFilter("user_id = $1 AND blind_index @> $2::jsonb", []interface{}{userUUID, tokensJSON}, filter)
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, coalesce(title, ''), url, coalesce(description, ''), tags, coalesce(category, ''),
+			is_read, is_favorite, is_archived, created_at, updated_at, is_private, coalesce(private_fields, ''),
+			is_encrypted, server_encrypted, client_encrypted
+		FROM articles
+		WHERE %s
+		ORDER BY %s
+	`, where, articleOrder(filter, "created_at DESC"))
+
+	// Add pagination
+	if filter != nil && filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	if filter != nil && filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search blind index: %w", err)
+	}
+	defer rows.Close()
+
+	return scanArticles(rows)
+}
+
+// marshalBlindIndex validates blind index tokens and serializes them for
+// the blind_index column
+func marshalBlindIndex(tokens []string) (string, error) {
+	if len(tokens) > models.MaxBlindTokens {
+		return "", fmt.Errorf("too many blind index tokens: %d (max %d)", len(tokens), models.MaxBlindTokens)
+	}
+
+	for _, token := range tokens {
+		if !encryption.IsValidBlindToken(token) {
+			return "", fmt.Errorf("invalid blind index token: %q", token)
+		}
+	}
+
+	if tokens == nil {
+		tokens = []string{}
+	}
+
+	tokensJSON, err := json.Marshal(tokens)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize blind index: %w", err)
+	}
+	return string(tokensJSON), nil
+}
+
+// EncryptedIndexService stores client-encrypted search index blobs
+type EncryptedIndexService struct {
+	db     *database.DB
+	logger *logrus.Logger
+}
+
+// NewEncryptedIndexService creates a new encrypted search index service
+func NewEncryptedIndexService(db *database.DB, logger *logrus.Logger) *EncryptedIndexService {
+	return &EncryptedIndexService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// PutIndex stores a new version of a user's encrypted index. The upload must
+// be based on the latest version, otherwise ErrEncryptedIndexConflict is
+// returned and the client should merge with the latest version first.
+func (s *EncryptedIndexService) PutIndex(userID string, upload *models.EncryptedSearchIndexUpload) (*models.EncryptedSearchIndex, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	if len(upload.Blob) > maxEncryptedIndexSize {
+		return nil, fmt.Errorf("encrypted search index too large: %d bytes (max %d)", len(upload.Blob), maxEncryptedIndexSize)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialize uploads per user so two clients cannot both build on the
+	// same base version
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "encrypted_index:"+userUUID.String()); err != nil {
+		return nil, fmt.Errorf("failed to lock encrypted search index: %w", err)
+	}
+
+	var latest int
+	err = tx.QueryRow(`
+		SELECT COALESCE(MAX(version), 0) FROM encrypted_search_indexes WHERE user_id = $1
+	`, userUUID).Scan(&latest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest index version: %w", err)
+	}
+	if upload.BaseVersion != latest {
+		return nil, ErrEncryptedIndexConflict
+	}
+
+	index := &models.EncryptedSearchIndex{
+		UserID:  userUUID,
+		Version: latest + 1,
+		Size:    int64(len(upload.Blob)),
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO encrypted_search_indexes (user_id, version, blob, size)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`, index.UserID, index.Version, upload.Blob, index.Size).Scan(&index.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store encrypted search index: %w", err)
+	}
+
+	// Keep only the most recent versions
+	_, err = tx.Exec(`
+		DELETE FROM encrypted_search_indexes WHERE user_id = $1 AND version <= $2
+	`, index.UserID, index.Version-encryptedIndexRetention)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prune encrypted search indexes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit encrypted search index: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id": userID,
+		"version": index.Version,
+		"size":    index.Size,
+	}).Info("Encrypted search index stored successfully")
+
+	return index, nil
+}
+
+// GetIndex returns a version of a user's encrypted index, or the latest
+// version when version is 0
+func (s *EncryptedIndexService) GetIndex(userID string, version int) (*models.EncryptedSearchIndex, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	query := `
+		SELECT user_id, version, blob, size, created_at
+		FROM encrypted_search_indexes
+		WHERE user_id = $1 AND ($2 = 0 OR version = $2)
+		ORDER BY version DESC
+		LIMIT 1
+	`
+
+	var index models.EncryptedSearchIndex
+	err = s.db.QueryRow(query, userUUID, version).Scan(
+		&index.UserID, &index.Version, &index.Blob, &index.Size, &index.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEncryptedIndexNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encrypted search index: %w", err)
+	}
+
+	return &index, nil
+}
+
+// ListVersions returns the stored versions of a user's encrypted index
+// without their blobs, newest first
+func (s *EncryptedIndexService) ListVersions(userID string) ([]*models.EncryptedSearchIndex, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT user_id, version, size, created_at
+		FROM encrypted_search_indexes
+		WHERE user_id = $1
+		ORDER BY version DESC
+	`, userUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list encrypted search indexes: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []*models.EncryptedSearchIndex{}
+	for rows.Next() {
+		var index models.EncryptedSearchIndex
+		if err := rows.Scan(&index.UserID, &index.Version, &index.Size, &index.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan encrypted search index: %w", err)
+		}
+		versions = append(versions, &index)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read encrypted search indexes: %w", err)
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/readitlater/backend/internal/models"
+)
+
+// articleDomain extracts the lower-cased host of an article URL without a
+// leading "www."
+const articleDomain = `regexp_replace(lower(substring(url from '^[a-zA-Z]+://([^/?#:]+)')), '^www\.', '')`
+
+// articleTags expands the tags column into one row per tag, tolerating rows
+// where tags is not a JSON array
+const articleTags = `jsonb_array_elements_text(CASE WHEN jsonb_typeof(tags) = 'array' THEN tags ELSE '[]'::jsonb END)`
+
+// articleYear is the year an article was saved
+const articleYear = `EXTRACT(YEAR FROM created_at)::int`
+
+// applyArticleFilter appends the conditions of an article filter to a WHERE
+// clause. Placeholders are numbered after the existing arguments.
+func applyArticleFilter(where string, args []interface{}, filter *models.ArticleFilter) (string, []interface{}) {
+	if filter == nil {
+		return where, args
+	}
+
+	conditions := []string{where}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Status != "" {
+		add("status = $%d", filter.Status)
+	}
+
+	if filter.IsRead != nil {
+		add("is_read = $%d", *filter.IsRead)
+	}
+
+	if filter.IsFavorite != nil {
+		add("is_favorite = $%d", *filter.IsFavorite)
+	}
+
+	if filter.IsArchived != nil {
+		add("is_archived = $%d", *filter.IsArchived)
+	}
+
+	if filter.Category != "" {
+		add("category = $%d", filter.Category)
+	}
+
+	// Articles must carry every requested tag
+	for _, tag := range filter.Tags {
+		add("tags ? $%d", tag)
+	}
+
+	if filter.Domain != "" {
+		add(articleDomain+" = lower($%d)", filter.Domain)
+	}
+
+	if filter.Author != "" {
+		add("author = $%d", filter.Author)
+	}
+
+	if filter.Year != 0 {
+		add(articleYear+" = $%d", filter.Year)
+	}
+
+	if filter.MaxReadingTime > 0 {
+		add("reading_time <= $%d", filter.MaxReadingTime)
+	}
+
+	if filter.DateFrom != nil {
+		add("created_at >= $%d", *filter.DateFrom)
+	}
+
+	if filter.DateTo != nil {
+		add("created_at <= $%d", *filter.DateTo)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// articleOrder returns the ORDER BY expression for a filter, falling back to
+// the given default when no sort field is set. Sort fields are validated
+// against a whitelist by ArticleFilter.ValidateSort before reaching here.
+func articleOrder(filter *models.ArticleFilter, fallback string) string {
+	if filter == nil || filter.SortBy == "" || filter.ValidateSort() != nil {
+		return fallback
+	}
+
+	direction := "DESC"
+	if filter.SortOrder == "asc" {
+		direction = "ASC"
+	}
+	return fmt.Sprintf("%s %s NULLS LAST, created_at DESC", filter.SortBy, direction)
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/database"
+	"github.com/readitlater/backend/internal/encryption"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/storage"
+)
+
+// FsckService cross-checks article rows against stored content and
+// optionally repairs what it finds
+type FsckService struct {
+	db      *database.DB
+	backend storage.Backend
+	logger  *logrus.Logger
+}
+
+// fsckArticle is the storage-related state of an article row
+type fsckArticle struct {
+	UserID      string
+	IsEncrypted bool
+	StorageSize int64
+	StoredSize  int64
+	LocalPath   string
+	Seen        bool
+}
+
+// NewFsckService creates a new storage check service
+func NewFsckService(db *database.DB, backend storage.Backend, logger *logrus.Logger) *FsckService {
+	return &FsckService{
+		db:      db,
+		backend: backend,
+		logger:  logger,
+	}
+}
+
+// Run checks every content file and article row, or those of one user, and
+// reports orphaned files, missing content, unparsable or undecryptable
+// files, and rows whose is_encrypted or storage_size disagree with storage.
+// Articles with pending storage operations are skipped.
+func (s *FsckService) Run(options *models.FsckOptions) (*models.FsckReport, error) {
+	if !models.IsValidFsckFiles(options.Files) {
+		return nil, fmt.Errorf("invalid bad file action: %s", options.Files)
+	}
+
+	prefix := "users/"
+	if options.UserID != "" {
+		userUUID, err := uuid.Parse(options.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user ID: %w", err)
+		}
+		prefix += userUUID.String() + "/"
+	}
+
+	report := &models.FsckReport{
+		Options:   *options,
+		StartedAt: time.Now().UTC(),
+		Counts:    map[string]int{},
+		Issues:    []models.FsckIssue{},
+	}
+
+	articles, err := s.loadArticles(options.UserID)
+	if err != nil {
+		return nil, err
+	}
+	report.ArticlesScanned = len(articles)
+
+	pending, err := s.pendingArticles()
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := s.backend.List(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list content: %w", err)
+	}
+
+	quarantine := "quarantine/" + report.StartedAt.Format("20060102T150405Z") + "/"
+
+	for _, object := range objects {
+		report.FilesScanned++
+
+		location, ok := storage.ParseContentKey(object.Key)
+		userID, articleID := location.UserID, location.ArticleID
+		if ok && pending[articleID] {
+			continue
+		}
+
+		article := articles[articleID]
+		if !ok || article == nil || article.UserID != userID {
+			issue := models.FsckIssue{Type: models.FsckOrphan, UserID: userID, ArticleID: articleID, Key: object.Key,
+				Detail: "no matching article row"}
+			s.handleFile(&issue, options, quarantine)
+			report.Add(issue)
+			continue
+		}
+		if location.Asset {
+			continue
+		}
+
+		// A move to the current layout interrupted by a crash leaves the
+		// old file behind next to the new one
+		if location.Layout != storage.CurrentLayout {
+			key, _ := storage.ContentKey(userID, articleID)
+			if _, err := s.backend.Stat(key); err == nil {
+				issue := models.FsckIssue{Type: models.FsckOrphan, UserID: userID, ArticleID: articleID, Key: object.Key,
+					Detail: "superseded by content in the current layout"}
+				s.handleFile(&issue, options, quarantine)
+				report.Add(issue)
+				continue
+			}
+		}
+		article.Seen = true
+
+		if article.LocalPath != "" && article.LocalPath != object.Key {
+			issue := models.FsckIssue{Type: models.FsckPathMismatch, UserID: userID, ArticleID: articleID, Key: object.Key,
+				Detail: fmt.Sprintf("row local_path=%s", article.LocalPath)}
+			s.repair(&issue, options, "UPDATE articles SET local_path = $1 WHERE id = $2", object.Key, articleID)
+			report.Add(issue)
+		}
+
+		data, err := s.read(object.Key)
+		if err != nil {
+			report.Add(models.FsckIssue{Type: models.FsckUnparsable, UserID: userID, ArticleID: articleID, Key: object.Key,
+				Detail: "unreadable", Error: err.Error()})
+			continue
+		}
+
+		// Compressed files are expanded and shared bodies read from their blob
+		data, err = storage.DecodeContent(s.backend, data)
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			issue := models.FsckIssue{Type: models.FsckMissing, UserID: userID, ArticleID: articleID, Key: object.Key,
+				Detail: err.Error()}
+			s.handleFile(&issue, options, quarantine)
+			report.Add(issue)
+			continue
+		}
+		if err != nil {
+			issue := models.FsckIssue{Type: models.FsckUnparsable, UserID: userID, ArticleID: articleID, Key: object.Key,
+				Detail: err.Error()}
+			s.handleFile(&issue, options, quarantine)
+			report.Add(issue)
+			continue
+		}
+
+		content, issueType, detail := checkContent(data)
+		if issueType != "" {
+			issue := models.FsckIssue{Type: issueType, UserID: userID, ArticleID: articleID, Key: object.Key, Detail: detail}
+			s.handleFile(&issue, options, quarantine)
+			report.Add(issue)
+			continue
+		}
+
+		if content.IsEncrypted != article.IsEncrypted {
+			issue := models.FsckIssue{Type: models.FsckEncryptionMismatch, UserID: userID, ArticleID: articleID, Key: object.Key,
+				Detail: fmt.Sprintf("row is_encrypted=%t, file is_encrypted=%t", article.IsEncrypted, content.IsEncrypted)}
+			s.repair(&issue, options, "UPDATE articles SET is_encrypted = $1 WHERE id = $2", content.IsEncrypted, articleID)
+			report.Add(issue)
+		}
+
+		// storage_size is the size of the article JSON before compression
+		// and deduplication
+		if size := int64(len(data)); size != article.StorageSize {
+			issue := models.FsckIssue{Type: models.FsckSizeMismatch, UserID: userID, ArticleID: articleID, Key: object.Key,
+				Detail: fmt.Sprintf("row storage_size=%d, content size=%d", article.StorageSize, size)}
+			s.repair(&issue, options, "UPDATE articles SET storage_size = $1 WHERE id = $2", size, articleID)
+			report.Add(issue)
+		}
+
+		if object.Size != article.StoredSize {
+			issue := models.FsckIssue{Type: models.FsckSizeMismatch, UserID: userID, ArticleID: articleID, Key: object.Key,
+				Detail: fmt.Sprintf("row stored_size=%d, file size=%d", article.StoredSize, object.Size)}
+			s.repair(&issue, options, "UPDATE articles SET stored_size = $1 WHERE id = $2", object.Size, articleID)
+			report.Add(issue)
+		}
+	}
+
+	for articleID, article := range articles {
+		if article.Seen || article.LocalPath == "" || pending[articleID] {
+			continue
+		}
+
+		issue := models.FsckIssue{Type: models.FsckMissing, UserID: article.UserID, ArticleID: articleID, Key: article.LocalPath,
+			Detail: "content file does not exist"}
+		s.repair(&issue, options, `
+			UPDATE articles SET local_path = NULL, storage_size = 0, stored_size = 0, status = 'failed' WHERE id = $1
+		`, articleID)
+		report.Add(issue)
+	}
+
+	report.CompletedAt = time.Now().UTC()
+
+	s.logger.WithFields(logrus.Fields{
+		"files_scanned":    report.FilesScanned,
+		"articles_scanned": report.ArticlesScanned,
+		"issues":           len(report.Issues),
+		"dry_run":          options.DryRun,
+	}).Info("Storage check completed")
+
+	return report, nil
+}
+
+// loadArticles returns the storage state of every article, or of one
+// user's articles, keyed by article ID
+func (s *FsckService) loadArticles(userID string) (map[string]*fsckArticle, error) {
+	query := `
+		SELECT id, user_id, coalesce(is_encrypted, false), coalesce(storage_size, 0), stored_size, coalesce(local_path, '')
+		FROM articles
+		WHERE $1 = '' OR user_id::text = $1
+	`
+
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get articles: %w", err)
+	}
+	defer rows.Close()
+
+	articles := map[string]*fsckArticle{}
+	for rows.Next() {
+		var id string
+		var article fsckArticle
+		if err := rows.Scan(&id, &article.UserID, &article.IsEncrypted, &article.StorageSize, &article.StoredSize, &article.LocalPath); err != nil {
+			return nil, fmt.Errorf("failed to scan article: %w", err)
+		}
+		articles[id] = &article
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read articles: %w", err)
+	}
+
+	return articles, nil
+}
+
+// pendingArticles returns the articles with storage operations in flight
+func (s *FsckService) pendingArticles() (map[string]bool, error) {
+	rows, err := s.db.Query("SELECT DISTINCT article_id FROM storage_outbox")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage outbox: %w", err)
+	}
+	defer rows.Close()
+
+	pending := map[string]bool{}
+	for rows.Next() {
+		var articleID string
+		if err := rows.Scan(&articleID); err != nil {
+			return nil, fmt.Errorf("failed to scan storage outbox: %w", err)
+		}
+		pending[articleID] = true
+	}
+
+	return pending, rows.Err()
+}
+
+// handleFile quarantines or deletes a bad content file as requested
+func (s *FsckService) handleFile(issue *models.FsckIssue, options *models.FsckOptions, quarantine string) {
+	issue.Action = options.Files
+	if options.Files == models.FsckFilesKeep || options.DryRun {
+		return
+	}
+
+	if options.Files == models.FsckFilesQuarantine {
+		data, err := s.read(issue.Key)
+		if err == nil {
+			err = s.backend.Put(quarantine+issue.Key, bytes.NewReader(data), int64(len(data)))
+		}
+		if err != nil {
+			issue.Error = err.Error()
+			return
+		}
+	}
+
+	if err := s.backend.Delete(issue.Key); err != nil {
+		issue.Error = err.Error()
+	}
+}
+
+// repair runs a statement fixing an article row when repairs are enabled
+func (s *FsckService) repair(issue *models.FsckIssue, options *models.FsckOptions, query string, args ...interface{}) {
+	if !options.Repair {
+		return
+	}
+
+	issue.Action = "repair"
+	if options.DryRun {
+		return
+	}
+
+	if _, err := s.db.Exec(query, args...); err != nil {
+		issue.Error = err.Error()
+	}
+}
+
+// read reads a whole object from the storage backend
+func (s *FsckService) read(key string) ([]byte, error) {
+	reader, err := s.backend.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// checkContent parses a content file, returning an issue type and detail
+// if it cannot be used
+func checkContent(data []byte) (*storage.ArticleContent, string, string) {
+	var content storage.ArticleContent
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, models.FsckUnparsable, err.Error()
+	}
+
+	if content.IsEncrypted || content.ServerEncrypted {
+		if content.WrappedKey != "" {
+			if err := encryption.ValidateCiphertext(content.WrappedKey); err != nil {
+				return nil, models.FsckUndecryptable, "wrapped key: " + err.Error()
+			}
+		}
+		if err := encryption.ValidateCiphertext(content.Content); err != nil {
+			return nil, models.FsckUndecryptable, "content: " + err.Error()
+		}
+		if content.Summary != "" {
+			if err := encryption.ValidateCiphertext(content.Summary); err != nil {
+				return nil, models.FsckUndecryptable, "summary: " + err.Error()
+			}
+		}
+	}
+
+	return &content, "", ""
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/database"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/scheduler"
+)
+
+// maxJobRunHistory is the number of runs kept per job
+const maxJobRunHistory = 100
+
+// jobRunColumns are the columns read by scanJobRun
+const jobRunColumns = "id, job_name, instance, status, error, started_at, completed_at"
+
+// JobRunService records background job runs in the database so every
+// replica can report them
+type JobRunService struct {
+	db     *database.DB
+	logger *logrus.Logger
+}
+
+// NewJobRunService creates a new job run service
+func NewJobRunService(db *database.DB, logger *logrus.Logger) *JobRunService {
+	return &JobRunService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// StartRun records the start of a job run and drops the job's oldest runs
+func (s *JobRunService) StartRun(job, instance string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`
+		INSERT INTO job_runs (job_name, instance, status, started_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, job, instance, models.JobStatusRunning, time.Now()).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record job run: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		DELETE FROM job_runs
+		WHERE job_name = $1 AND id NOT IN (
+			SELECT id FROM job_runs WHERE job_name = $1 ORDER BY started_at DESC LIMIT $2
+		)
+	`, job, maxJobRunHistory)
+	if err != nil {
+		s.logger.WithError(err).WithField("job", job).Warn("Failed to prune job run history")
+	}
+
+	return id, nil
+}
+
+// FinishRun records the outcome of a job run
+func (s *JobRunService) FinishRun(id int64, runErr error) error {
+	status, message := models.JobStatusSucceeded, ""
+	if runErr != nil {
+		status, message = models.JobStatusFailed, runErr.Error()
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE job_runs SET status = $1, error = $2, completed_at = $3 WHERE id = $4
+	`, status, message, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to record job result: %w", err)
+	}
+	return nil
+}
+
+// FailStaleRuns marks runs still running after maxAge as failed. Runs
+// cannot outlive their timeout, so these were interrupted by a crash.
+func (s *JobRunService) FailStaleRuns(maxAge time.Duration) error {
+	_, err := s.db.Exec(`
+		UPDATE job_runs
+		SET status = $1, error = 'interrupted', completed_at = $2
+		WHERE status = $3 AND started_at < $4
+	`, models.JobStatusFailed, time.Now(), models.JobStatusRunning, time.Now().Add(-maxAge))
+	if err != nil {
+		return fmt.Errorf("failed to clean up interrupted job runs: %w", err)
+	}
+	return nil
+}
+
+// GetJobRuns returns the most recent runs of a job, newest first
+func (s *JobRunService) GetJobRuns(job string, limit int) ([]*models.JobRun, error) {
+	rows, err := s.db.Query(`
+		SELECT `+jobRunColumns+`
+		FROM job_runs
+		WHERE job_name = $1
+		ORDER BY started_at DESC
+		LIMIT $2
+	`, job, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []*models.JobRun{}
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read job runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// GetJobStatuses combines the registered jobs with their latest run and
+// latest failure
+func (s *JobRunService) GetJobStatuses(jobs []scheduler.JobInfo) ([]*models.JobStatus, error) {
+	lastRuns, err := s.latestRuns("")
+	if err != nil {
+		return nil, err
+	}
+	lastErrors, err := s.latestRuns(models.JobStatusFailed)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []*models.JobStatus{}
+	for _, job := range jobs {
+		statuses = append(statuses, &models.JobStatus{
+			Name:      job.Name,
+			Schedule:  job.Schedule,
+			NextRun:   job.NextRun,
+			Running:   job.Running,
+			LastRun:   lastRuns[job.Name],
+			LastError: lastErrors[job.Name],
+		})
+	}
+
+	return statuses, nil
+}
+
+// latestRuns returns the newest run of every job, optionally only among
+// runs with a status
+func (s *JobRunService) latestRuns(status string) (map[string]*models.JobRun, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT ON (job_name) `+jobRunColumns+`
+		FROM job_runs
+		WHERE $1 = '' OR status = $1
+		ORDER BY job_name, started_at DESC
+	`, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest job runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := map[string]*models.JobRun{}
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job run: %w", err)
+		}
+		runs[run.JobName] = run
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read latest job runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// scanJobRun scans a job run selected with jobRunColumns
+func scanJobRun(row rowScanner) (*models.JobRun, error) {
+	var run models.JobRun
+	var completedAt sql.NullTime
+
+	err := row.Scan(&run.ID, &run.JobName, &run.Instance, &run.Status, &run.Error, &run.StartedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if completedAt.Valid {
+		run.CompletedAt = &completedAt.Time
+	}
+	return &run, nil
+}
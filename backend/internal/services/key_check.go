@@ -0,0 +1,219 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/readitlater/backend/internal/encryption"
+	"github.com/readitlater/backend/internal/models"
+)
+
+// execer runs statements on a database or in a transaction
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// ErrNoEncryptionKey is returned when verifying the key of a user who has
+// not encrypted anything yet
+var ErrNoEncryptionKey = errors.New("no encryption key has been set up")
+
+// ErrKeyNotVerified is returned when a zero-knowledge client uploads
+// content for a user whose encrypted articles predate key checks. Its key
+// check cannot be compared with anything until the user key is verified
+// with VerifyEncryptionKey, which records one.
+var ErrKeyNotVerified = errors.New("encrypted articles predate key checks, verify the user key first")
+
+// VerifyEncryptionKey checks a user key against the user's key check and
+// returns encryption.ErrInvalidKey when it does not match. Users who
+// encrypted articles before key checks existed have the key tried on their
+// content instead, and a check is recorded once it opens.
+func (s *ArticleService) VerifyEncryptionKey(userID, userKey string) error {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	err = s.verifyKeyCheck(userUUID, userKey)
+	if !errors.Is(err, ErrNoEncryptionKey) {
+		return err
+	}
+
+	var articleID uuid.UUID
+	err = s.db.QueryRow("SELECT id FROM articles WHERE "+encryptedArticlesCondition+" ORDER BY id LIMIT 1", userUUID).Scan(&articleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoEncryptionKey
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get encrypted article: %w", err)
+	}
+
+	// Only a key that fails to open the content is wrong; storage and
+	// parse errors are the server's
+	_, err = s.storageService.GetDecryptedContent(userID, articleID.String(), userKey)
+	if errors.Is(err, encryption.ErrDecryptionFailed) && !errors.Is(err, encryption.ErrInvalidKey) {
+		return fmt.Errorf("%w: %v", encryption.ErrInvalidKey, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to try key on encrypted article: %w", err)
+	}
+
+	return setKeyCheck(s.db, userUUID, userKey)
+}
+
+// registerEncryptionKey verifies the key a user encrypts content with,
+// recording its key check when the user encrypts for the first time. Users
+// who encrypted articles before key checks existed have the key tried on
+// their content first, so a wrong key is never recorded as theirs.
+func (s *ArticleService) registerEncryptionKey(userID uuid.UUID, userKey string) error {
+	err := s.VerifyEncryptionKey(userID.String(), userKey)
+	if errors.Is(err, ErrNoEncryptionKey) {
+		check, err := encryption.NewKeyCheck(userKey, userID.String())
+		if err != nil {
+			return err
+		}
+		if err := recordKeyCheck(s.db, userID, check); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	verifier, err := s.writeKeyCheck(userID)
+	if err != nil {
+		return err
+	}
+	return encryption.VerifyKeyCheck(verifier, userKey, userID.String())
+}
+
+// registerKeyCheck verifies the key check a zero-knowledge client computed
+// from the key it encrypted content with, recording it when the user
+// encrypts for the first time. Users who encrypted articles before key
+// checks existed must verify their key first, as the server cannot try a
+// key it never sees on their content.
+func (s *ArticleService) registerKeyCheck(userID uuid.UUID, check string) error {
+	if err := encryption.ValidateKeyCheck(check); err != nil {
+		return err
+	}
+
+	verifier, err := s.writeKeyCheck(userID)
+	if errors.Is(err, ErrNoEncryptionKey) {
+		var legacy bool
+		err = s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM articles WHERE "+encryptedArticlesCondition+")", userID).Scan(&legacy)
+		if err != nil {
+			return fmt.Errorf("failed to get encrypted articles: %w", err)
+		}
+		if legacy {
+			return ErrKeyNotVerified
+		}
+
+		if err := recordKeyCheck(s.db, userID, check); err != nil {
+			return err
+		}
+		verifier, err = s.writeKeyCheck(userID)
+	}
+	if err != nil {
+		return err
+	}
+	return encryption.MatchKeyCheck(check, verifier)
+}
+
+// recordKeyCheck records a user's key check unless one is recorded already
+func recordKeyCheck(db execer, userID uuid.UUID, check string) error {
+	_, err := db.Exec(`
+		INSERT INTO encryption_key_checks (user_id, verifier) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO NOTHING
+	`, userID, check)
+	if err != nil {
+		return fmt.Errorf("failed to record key check: %w", err)
+	}
+	return nil
+}
+
+// verifyKeyCheck checks a user key against the recorded key check. While
+// a key rotation is open, its new key is accepted as well.
+func (s *ArticleService) verifyKeyCheck(userID uuid.UUID, userKey string) error {
+	current, rotating, err := s.keyChecks(userID)
+	if err != nil {
+		return err
+	}
+	if current == "" && rotating == "" {
+		return ErrNoEncryptionKey
+	}
+
+	err = encryption.ErrInvalidKey
+	for _, verifier := range []string{current, rotating} {
+		if verifier == "" {
+			continue
+		}
+		if err = encryption.VerifyKeyCheck(verifier, userKey, userID.String()); !errors.Is(err, encryption.ErrInvalidKey) {
+			return err
+		}
+	}
+	return err
+}
+
+// writeKeyCheck returns the key check new content must match: the new key's
+// while a key rotation is open, so no content is left behind on the old key
+func (s *ArticleService) writeKeyCheck(userID uuid.UUID) (string, error) {
+	current, rotating, err := s.keyChecks(userID)
+	if err != nil {
+		return "", err
+	}
+	if rotating != "" {
+		return rotating, nil
+	}
+	if current == "" {
+		return "", ErrNoEncryptionKey
+	}
+	return current, nil
+}
+
+// keyChecks returns a user's recorded key check and, while the user's
+// latest key rotation is open, the key check of its new key. A rotation
+// stays open until it completes without failures, since until then content
+// may need either key.
+func (s *ArticleService) keyChecks(userID uuid.UUID) (string, string, error) {
+	var current string
+	err := s.db.QueryRow("SELECT verifier FROM encryption_key_checks WHERE user_id = $1", userID).Scan(&current)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", "", fmt.Errorf("failed to get key check: %w", err)
+	}
+
+	var status, rotating string
+	err = s.db.QueryRow(`
+		SELECT status, new_key_check FROM key_rotations
+		WHERE user_id = $1
+		ORDER BY started_at DESC
+		LIMIT 1
+	`, userID).Scan(&status, &rotating)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return current, "", nil
+	case err != nil:
+		return "", "", fmt.Errorf("failed to get key rotation: %w", err)
+	case status == models.KeyRotationCompleted:
+		return current, "", nil
+	}
+	return current, rotating, nil
+}
+
+// setKeyCheck records the key check of a user's current key, replacing the
+// previous one
+func setKeyCheck(db execer, userID uuid.UUID, userKey string) error {
+	check, err := encryption.NewKeyCheck(userKey, userID.String())
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO encryption_key_checks (user_id, verifier) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET verifier = EXCLUDED.verifier, updated_at = $3
+	`, userID, check, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to record key check: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/config"
+	"github.com/readitlater/backend/internal/database"
+	"github.com/readitlater/backend/internal/encryption"
+	"github.com/readitlater/backend/internal/models"
+)
+
+// Errors returned by the key recovery service
+var (
+	ErrRecoveryNotFound    = errors.New("key recovery is not set up")
+	ErrRecoveryOutdated    = errors.New("recovery key wraps a previous encryption key")
+	ErrEscrowNotConfigured = errors.New("key escrow is not configured")
+	ErrKeyNotEscrowed      = errors.New("encryption key is not escrowed")
+)
+
+// keyRecoveryColumns selects the fields of a key recovery
+const keyRecoveryColumns = `user_id, escrowed_key IS NOT NULL, coalesce(escrow_key_id, ''), created_at, last_tested_at, last_used_at`
+
+// KeyRecoveryService lets users recover a forgotten encryption key with a
+// recovery key, or with the help of administrators holding the escrow
+// private key. Every attempt is recorded in an audit log.
+type KeyRecoveryService struct {
+	config         *config.Config
+	db             *database.DB
+	articleService *ArticleService
+	encryption     *encryption.Service
+	logger         *logrus.Logger
+}
+
+// NewKeyRecoveryService creates a new key recovery service
+func NewKeyRecoveryService(cfg *config.Config, db *database.DB, articleService *ArticleService, logger *logrus.Logger) *KeyRecoveryService {
+	return &KeyRecoveryService{
+		config:         cfg,
+		db:             db,
+		articleService: articleService,
+		encryption:     encryption.NewService(cfg.EncryptionAlgorithm, cfg.KeyDerivationIterations),
+		logger:         logger,
+	}
+}
+
+// SetupRecovery wraps a user's current key under a new recovery key,
+// replacing any previous recovery, and returns the recovery key. With
+// escrow the key is also sealed to the organization escrow public key.
+func (s *KeyRecoveryService) SetupRecovery(userID string, req *models.KeyRecoverySetupRequest, actor, ip string) (*models.KeyRecoverySetup, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	if req.Escrow && s.config.RecoveryEscrowPublicKey == "" {
+		return nil, ErrEscrowNotConfigured
+	}
+
+	// Only the key the user's content is encrypted with is worth recovering.
+	// Setting up recovery before encrypting anything records the key.
+	err = s.articleService.VerifyEncryptionKey(userID, req.UserKey)
+	if errors.Is(err, ErrNoEncryptionKey) {
+		err = s.articleService.registerEncryptionKey(userUUID, req.UserKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryKey, err := encryption.GenerateRecoveryKey()
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := s.encryption.WrapForRecovery(req.UserKey, recoveryKey, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap key for recovery: %w", err)
+	}
+
+	var escrowedKey, escrowKeyID sql.NullString
+	if req.Escrow {
+		if escrowedKey.String, err = s.encryption.SealForEscrow(req.UserKey, s.config.RecoveryEscrowPublicKey, userID); err != nil {
+			return nil, fmt.Errorf("failed to escrow key: %w", err)
+		}
+		if escrowKeyID.String, err = encryption.EscrowKeyID(s.config.RecoveryEscrowPublicKey); err != nil {
+			return nil, err
+		}
+		escrowedKey.Valid, escrowKeyID.Valid = true, true
+	}
+
+	row := s.db.QueryRow(`
+		INSERT INTO key_recoveries (user_id, wrapped_key, escrowed_key, escrow_key_id, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE SET
+			wrapped_key = EXCLUDED.wrapped_key, escrowed_key = EXCLUDED.escrowed_key,
+			escrow_key_id = EXCLUDED.escrow_key_id, created_at = EXCLUDED.created_at,
+			last_tested_at = NULL, last_used_at = NULL
+		RETURNING `+keyRecoveryColumns, userUUID, wrappedKey, escrowedKey, escrowKeyID, time.Now())
+	recovery, err := scanKeyRecovery(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save key recovery: %w", err)
+	}
+
+	if err := s.audit(userUUID, models.KeyRecoveryEventSetup, true, actor, ip); err != nil {
+		return nil, err
+	}
+
+	return &models.KeyRecoverySetup{RecoveryKey: recoveryKey, Recovery: recovery}, nil
+}
+
+// GetRecovery retrieves the recovery set up for a user
+func (s *KeyRecoveryService) GetRecovery(userID string) (*models.KeyRecovery, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	row := s.db.QueryRow("SELECT "+keyRecoveryColumns+" FROM key_recoveries WHERE user_id = $1", userUUID)
+	recovery, err := scanKeyRecovery(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRecoveryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key recovery: %w", err)
+	}
+
+	return recovery, nil
+}
+
+// DeleteRecovery removes a user's recovery, including any escrowed key
+func (s *KeyRecoveryService) DeleteRecovery(userID, actor, ip string) error {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	result, err := s.db.Exec("DELETE FROM key_recoveries WHERE user_id = $1", userUUID)
+	if err != nil {
+		return fmt.Errorf("failed to delete key recovery: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrRecoveryNotFound
+	}
+
+	return s.audit(userUUID, models.KeyRecoveryEventRemoved, true, actor, ip)
+}
+
+// TestRecovery checks that a recovery key still recovers the user's current
+// key without returning it
+func (s *KeyRecoveryService) TestRecovery(userID, recoveryKey, actor, ip string) (*models.KeyRecovery, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	if _, err := s.recoverKey(userUUID, recoveryKey, models.KeyRecoveryEventTested, actor, ip); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.db.Exec("UPDATE key_recoveries SET last_tested_at = $1 WHERE user_id = $2", time.Now(), userUUID); err != nil {
+		return nil, fmt.Errorf("failed to update key recovery: %w", err)
+	}
+
+	return s.GetRecovery(userID)
+}
+
+// UseRecovery returns the user key wrapped by a recovery key. Clients
+// should start a key rotation to a new key afterwards and set up recovery
+// again.
+func (s *KeyRecoveryService) UseRecovery(userID, recoveryKey, actor, ip string) (string, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return "", fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	userKey, err := s.recoverKey(userUUID, recoveryKey, models.KeyRecoveryEventUsed, actor, ip)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := s.db.Exec("UPDATE key_recoveries SET last_used_at = $1 WHERE user_id = $2", time.Now(), userUUID); err != nil {
+		return "", fmt.Errorf("failed to update key recovery: %w", err)
+	}
+
+	return userKey, nil
+}
+
+// GetEscrowedKey releases a user's escrowed key to an administrator, who
+// opens it offline with the escrow private key
+func (s *KeyRecoveryService) GetEscrowedKey(userID, actor, ip string) (*models.EscrowedKey, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	var escrowedKey, escrowKeyID sql.NullString
+	err = s.db.QueryRow("SELECT escrowed_key, escrow_key_id FROM key_recoveries WHERE user_id = $1", userUUID).Scan(&escrowedKey, &escrowKeyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRecoveryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get escrowed key: %w", err)
+	}
+	if !escrowedKey.Valid {
+		return nil, ErrKeyNotEscrowed
+	}
+
+	// The key is only released once the release is on record
+	if err := s.audit(userUUID, models.KeyRecoveryEventEscrowAccessed, true, actor, ip); err != nil {
+		return nil, err
+	}
+
+	return &models.EscrowedKey{UserID: userUUID, EscrowedKey: escrowedKey.String, EscrowKeyID: escrowKeyID.String}, nil
+}
+
+// GetEvents retrieves a user's key recovery audit log, newest first
+func (s *KeyRecoveryService) GetEvents(userID string, limit int) ([]*models.KeyRecoveryEvent, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, user_id, event, success, actor, ip_address, created_at
+		FROM key_recovery_events
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, userUUID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key recovery events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*models.KeyRecoveryEvent{}
+	for rows.Next() {
+		var event models.KeyRecoveryEvent
+		if err := rows.Scan(&event.ID, &event.UserID, &event.Event, &event.Success, &event.Actor, &event.IPAddress, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan key recovery event: %w", err)
+		}
+		events = append(events, &event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read key recovery events: %w", err)
+	}
+
+	return events, nil
+}
+
+// recoverKey unwraps a user's key with a recovery key and checks that it is
+// still the user's current key. The attempt is audited whatever the outcome,
+// and a recovered key is only returned once its audit entry is recorded.
+func (s *KeyRecoveryService) recoverKey(userID uuid.UUID, recoveryKey, event, actor, ip string) (string, error) {
+	var wrappedKey string
+	err := s.db.QueryRow("SELECT wrapped_key FROM key_recoveries WHERE user_id = $1", userID).Scan(&wrappedKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrRecoveryNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get key recovery: %w", err)
+	}
+
+	userKey, err := encryption.UnwrapWithRecovery(wrappedKey, recoveryKey, userID.String())
+	if err == nil {
+		// Rotating the key leaves the recovery wrapping the previous one
+		if verifyErr := s.articleService.verifyKeyCheck(userID, userKey); errors.Is(verifyErr, encryption.ErrInvalidKey) {
+			err = ErrRecoveryOutdated
+		} else if verifyErr != nil && !errors.Is(verifyErr, ErrNoEncryptionKey) {
+			return "", verifyErr
+		}
+	}
+
+	if auditErr := s.audit(userID, event, err == nil, actor, ip); auditErr != nil {
+		return "", auditErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return userKey, nil
+}
+
+// audit records a key recovery event in the audit log and the server log
+func (s *KeyRecoveryService) audit(userID uuid.UUID, event string, success bool, actor, ip string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO key_recovery_events (user_id, event, success, actor, ip_address)
+		VALUES ($1, $2, $3, $4, $5)
+	`, userID, event, success, actor, ip)
+	if err != nil {
+		return fmt.Errorf("failed to record key recovery event: %w", err)
+	}
+
+	entry := s.logger.WithFields(logrus.Fields{
+		"audit":   "key_recovery",
+		"event":   event,
+		"success": success,
+		"user_id": userID.String(),
+		"actor":   actor,
+		"ip":      ip,
+	})
+	if event == models.KeyRecoveryEventUsed || event == models.KeyRecoveryEventEscrowAccessed || !success {
+		entry.Warn("Key recovery event")
+	} else {
+		entry.Info("Key recovery event")
+	}
+	return nil
+}
+
+// scanKeyRecovery scans a key recovery selected with keyRecoveryColumns
+func scanKeyRecovery(row rowScanner) (*models.KeyRecovery, error) {
+	var recovery models.KeyRecovery
+	var lastTestedAt, lastUsedAt sql.NullTime
+
+	err := row.Scan(&recovery.UserID, &recovery.Escrowed, &recovery.EscrowKeyID, &recovery.CreatedAt, &lastTestedAt, &lastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastTestedAt.Valid {
+		recovery.LastTestedAt = &lastTestedAt.Time
+	}
+	if lastUsedAt.Valid {
+		recovery.LastUsedAt = &lastUsedAt.Time
+	}
+	return &recovery, nil
+}
@@ -0,0 +1,576 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/database"
+	"github.com/readitlater/backend/internal/encryption"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/storage"
+)
+
+// Errors returned by the key rotation service
+var (
+	ErrRotationInProgress   = errors.New("a key rotation is already in progress")
+	ErrRotationNotFound     = errors.New("key rotation not found")
+	ErrRotationNotRunning   = errors.New("key rotation is not running")
+	ErrRotationNotResumable = errors.New("key rotation cannot be resumed")
+	ErrInvalidRotationKeys  = errors.New("invalid rotation keys")
+)
+
+// keyRotationColumns are the columns selected by scanKeyRotation
+const keyRotationColumns = `id, user_id, status, total, rotated, failed, error, started_at, updated_at, completed_at`
+
+// keyRotationBatch is the number of articles loaded at a time
+const keyRotationBatch = 100
+
+// keyRotationStaleAfter is how long a running rotation can go without
+// progress before it is considered abandoned by a crashed server and may be
+// resumed
+const keyRotationStaleAfter = 5 * time.Minute
+
+// encryptedArticlesCondition selects a user's articles with encrypted
+// stored content
+const encryptedArticlesCondition = "user_id = $1 AND is_encrypted = true AND local_path IS NOT NULL"
+
+// KeyRotationService moves a user's encrypted articles from an old key to a
+// new one in the background
+type KeyRotationService struct {
+	db             *database.DB
+	articleService *ArticleService
+	storageService *storage.Service
+	logger         *logrus.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// rotationKeys are the keys of a running rotation
+type rotationKeys struct {
+	old string
+	new string
+}
+
+// NewKeyRotationService creates a new key rotation service
+func NewKeyRotationService(db *database.DB, articleService *ArticleService, storageService *storage.Service, logger *logrus.Logger) *KeyRotationService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &KeyRotationService{
+		db:             db,
+		articleService: articleService,
+		storageService: storageService,
+		logger:         logger,
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// StartRotation checks the keys and starts rotating every encrypted article
+// of a user from the old key to the new one, upgrading content written in
+// older formats on the way. Without a new key it only upgrades. Clients
+// should encrypt new articles with the new key from then on.
+func (s *KeyRotationService) StartRotation(userID string, req *models.KeyRotationRequest) (*models.KeyRotation, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	keys, err := s.checkKeys(userUUID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	newKeyCheck, err := encryption.NewKeyCheck(keys.new, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM articles WHERE "+encryptedArticlesCondition, userUUID).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count encrypted articles: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	token := uuid.New()
+	row := tx.QueryRow(`
+		INSERT INTO key_rotations (user_id, status, total, run_token, new_key_check) VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+keyRotationColumns, userUUID, models.KeyRotationRunning, total, token, newKeyCheck)
+	rotation, err := scanKeyRotation(row)
+	if isUniqueViolation(err) {
+		return nil, ErrRotationInProgress
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key rotation: %w", err)
+	}
+
+	// Both keys read content until the rotation completes; a user who has
+	// not encrypted anything yet starts out with the new key
+	if err := recordKeyCheck(tx, userUUID, newKeyCheck); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit key rotation: %w", err)
+	}
+
+	s.startWorker(rotation, token, uuid.Nil, keys)
+
+	return rotation, nil
+}
+
+// PauseRotation stops a running rotation after the article it is working
+// on. Every article stays readable with one of the two keys.
+func (s *KeyRotationService) PauseRotation(userID, rotationID string) (*models.KeyRotation, error) {
+	userUUID, rotationUUID, err := parseRotationIDs(userID, rotationID)
+	if err != nil {
+		return nil, err
+	}
+
+	row := s.db.QueryRow(`
+		UPDATE key_rotations SET status = $1, updated_at = $2
+		WHERE id = $3 AND user_id = $4 AND status = $5
+		RETURNING `+keyRotationColumns, models.KeyRotationPaused, time.Now(), rotationUUID, userUUID, models.KeyRotationRunning)
+	rotation, err := scanKeyRotation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.GetRotation(userID, rotationID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRotationNotRunning
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to pause key rotation: %w", err)
+	}
+
+	return rotation, nil
+}
+
+// ResumeRotation continues a paused or interrupted rotation where it
+// stopped. A failed rotation is retried from the start; articles already
+// readable with the new key are skipped.
+func (s *KeyRotationService) ResumeRotation(userID, rotationID string, req *models.KeyRotationRequest) (*models.KeyRotation, error) {
+	userUUID, rotationUUID, err := parseRotationIDs(userID, rotationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.GetRotation(userID, rotationID); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.checkKeys(userUUID, req)
+	if err != nil {
+		return nil, err
+	}
+	newKeyCheck, err := encryption.NewKeyCheck(keys.new, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// A new token stops any worker still attached to the rotation
+	token := uuid.New()
+	now := time.Now()
+	row := tx.QueryRow(`
+		UPDATE key_rotations SET
+			status = $1, run_token = $2, error = '', updated_at = $3, completed_at = NULL, new_key_check = $9,
+			last_article_id = CASE WHEN status = $4 THEN NULL ELSE last_article_id END,
+			rotated = CASE WHEN status = $4 THEN 0 ELSE rotated END,
+			failed = CASE WHEN status = $4 THEN 0 ELSE failed END
+		WHERE id = $5 AND user_id = $6
+			AND (status IN ($4, $7) OR (status = $1 AND updated_at < $8))
+		RETURNING `+keyRotationColumns+`, coalesce(last_article_id, '00000000-0000-0000-0000-000000000000')`,
+		models.KeyRotationRunning, token, now, models.KeyRotationFailed, rotationUUID, userUUID,
+		models.KeyRotationPaused, now.Add(-keyRotationStaleAfter), newKeyCheck)
+
+	var after uuid.UUID
+	rotation, err := scanKeyRotation(row, &after)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRotationNotResumable
+	}
+	if isUniqueViolation(err) {
+		return nil, ErrRotationInProgress
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume key rotation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit key rotation: %w", err)
+	}
+
+	s.startWorker(rotation, token, after, keys)
+
+	return rotation, nil
+}
+
+// GetRotations retrieves a user's key rotations, newest first
+func (s *KeyRotationService) GetRotations(userID string, limit int) ([]*models.KeyRotation, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+keyRotationColumns+` FROM key_rotations
+		WHERE user_id = $1
+		ORDER BY started_at DESC
+		LIMIT $2
+	`, userUUID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key rotations: %w", err)
+	}
+	defer rows.Close()
+
+	rotations := []*models.KeyRotation{}
+	for rows.Next() {
+		rotation, err := scanKeyRotation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan key rotation: %w", err)
+		}
+		rotations = append(rotations, rotation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read key rotations: %w", err)
+	}
+
+	return rotations, nil
+}
+
+// GetRotation retrieves a specific key rotation
+func (s *KeyRotationService) GetRotation(userID, rotationID string) (*models.KeyRotation, error) {
+	userUUID, rotationUUID, err := parseRotationIDs(userID, rotationID)
+	if err != nil {
+		return nil, err
+	}
+
+	row := s.db.QueryRow("SELECT "+keyRotationColumns+" FROM key_rotations WHERE id = $1 AND user_id = $2", rotationUUID, userUUID)
+	rotation, err := scanKeyRotation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRotationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key rotation: %w", err)
+	}
+
+	return rotation, nil
+}
+
+// Stop pauses running rotations and waits for their workers to exit. They
+// can be resumed once the server is back.
+func (s *KeyRotationService) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// checkKeys validates rotation keys and makes sure the old key is the
+// user's current key, so a mistyped old key fails here rather than on every
+// article. While a previous rotation is open, its new key is accepted as
+// the new key even when the old key no longer verifies, so the rotation
+// can be finished.
+func (s *KeyRotationService) checkKeys(userID uuid.UUID, req *models.KeyRotationRequest) (*rotationKeys, error) {
+	keys := &rotationKeys{old: req.OldKey, new: req.NewKey}
+	if keys.new == "" {
+		keys.new = keys.old
+	}
+	// Only the new key must be in the user key format, so content written
+	// before data keys under a passphrase can still be moved to a valid key
+	if _, err := encryption.DeriveUserKEK(keys.new, userID.String()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRotationKeys, err)
+	}
+
+	err := s.articleService.VerifyEncryptionKey(userID.String(), keys.old)
+	if err == nil || errors.Is(err, ErrNoEncryptionKey) {
+		return keys, nil
+	}
+	if !errors.Is(err, encryption.ErrInvalidKey) {
+		return nil, err
+	}
+
+	_, rotating, err := s.articleService.keyChecks(userID)
+	if err != nil {
+		return nil, err
+	}
+	if rotating != "" && encryption.VerifyKeyCheck(rotating, keys.new, userID.String()) == nil {
+		return keys, nil
+	}
+	return nil, fmt.Errorf("%w: encrypted articles cannot be read with the old key", ErrInvalidRotationKeys)
+}
+
+// startWorker runs a rotation in the background
+func (s *KeyRotationService) startWorker(rotation *models.KeyRotation, token, after uuid.UUID, keys *rotationKeys) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.runRotation(rotation, token, after, keys)
+	}()
+}
+
+// runRotation rotates the articles after a cursor in ID order, recording
+// the cursor after each one so the rotation can be resumed. It stops when
+// the rotation is paused or its token is replaced by a resume.
+func (s *KeyRotationService) runRotation(rotation *models.KeyRotation, token, after uuid.UUID, keys *rotationKeys) {
+	logger := s.logger.WithFields(logrus.Fields{
+		"user_id":     rotation.UserID.String(),
+		"rotation_id": rotation.ID.String(),
+	})
+
+	failed := rotation.Failed
+	for {
+		batch, err := s.nextRotationBatch(rotation.UserID, after)
+		if err != nil {
+			s.finishRotation(rotation.ID, token, models.KeyRotationFailed, err.Error(), logger)
+			return
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, articleID := range batch {
+			if s.ctx.Err() != nil {
+				s.finishRotation(rotation.ID, token, models.KeyRotationPaused, "interrupted by server shutdown", logger)
+				return
+			}
+
+			rotated, failedCount := 1, 0
+			if err := s.rotateArticle(rotation.UserID, articleID, keys); err != nil {
+				logger.WithError(err).WithField("article_id", articleID.String()).Warn("Failed to rotate article key")
+				rotated, failedCount = 0, 1
+				failed++
+			}
+			after = articleID
+
+			var status string
+			err := s.db.QueryRow(`
+				UPDATE key_rotations SET rotated = rotated + $1, failed = failed + $2, last_article_id = $3, updated_at = $4
+				WHERE id = $5 AND run_token = $6
+				RETURNING status
+			`, rotated, failedCount, articleID, time.Now(), rotation.ID, token).Scan(&status)
+			if errors.Is(err, sql.ErrNoRows) {
+				logger.Info("Key rotation taken over by a resumed run")
+				return
+			}
+			if err != nil {
+				logger.WithError(err).Error("Failed to record key rotation progress")
+				return
+			}
+			if status != models.KeyRotationRunning {
+				logger.Info("Key rotation paused")
+				return
+			}
+		}
+	}
+
+	if failed > 0 {
+		s.finishRotation(rotation.ID, token, models.KeyRotationFailed,
+			fmt.Sprintf("%d articles could not be rotated and still need the old key", failed), logger)
+		return
+	}
+	s.finishRotation(rotation.ID, token, models.KeyRotationCompleted, "", logger)
+}
+
+// nextRotationBatch returns the IDs of a user's encrypted articles after a
+// cursor
+func (s *KeyRotationService) nextRotationBatch(userID, after uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := s.db.Query(`
+		SELECT id FROM articles
+		WHERE `+encryptedArticlesCondition+` AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`, userID, after, keyRotationBatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get articles to rotate: %w", err)
+	}
+	defer rows.Close()
+
+	batch := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan article: %w", err)
+		}
+		batch = append(batch, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read articles to rotate: %w", err)
+	}
+
+	return batch, nil
+}
+
+// rotateArticle moves one article to the new key. The stored content is
+// replaced in a single write under the article's storage lock, so it is
+// readable with the old key before and the new key after.
+func (s *KeyRotationService) rotateArticle(userID, articleID uuid.UUID, keys *rotationKeys) error {
+	// Writes still in the outbox must reach storage before they are rotated
+	if err := s.articleService.flushStorageOutbox(articleID); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "storage_outbox:"+articleID.String()); err != nil {
+		return fmt.Errorf("failed to lock storage outbox: %w", err)
+	}
+
+	entries, err := pendingStorageOperations(tx, articleID)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("article has pending storage operations")
+	}
+
+	var encrypted bool
+	var storageSize int64
+	err = tx.QueryRow(`
+		SELECT coalesce(is_encrypted, false), coalesce(storage_size, 0) FROM articles WHERE id = $1 AND user_id = $2
+	`, articleID, userID).Scan(&encrypted, &storageSize)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to get article: %w", err)
+	}
+	if errors.Is(err, sql.ErrNoRows) || !encrypted {
+		return nil
+	}
+
+	content, err := s.storageService.GetContent(userID.String(), articleID.String())
+	if err != nil {
+		return err
+	}
+
+	rekeyed, err := s.storageService.RekeyContent(userID.String(), articleID.String(), []byte(content), keys.old, keys.new)
+	if err != nil || rekeyed == nil {
+		return err
+	}
+
+	// Content re-encrypted from an older format may grow
+	if growth := int64(len(rekeyed)) - storageSize; growth > 0 {
+		if _, err := s.articleService.reserveStorage(tx, userID, growth); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE articles SET storage_size = $1 WHERE id = $2", len(rekeyed), articleID); err != nil {
+		return fmt.Errorf("failed to record rekeyed content: %w", err)
+	}
+
+	// The rekeyed content is written through the outbox, so storage never
+	// holds it unless the transaction commits
+	if err := enqueueStorage(tx, userID, articleID, outboxWrite, rekeyed); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// A failed write stays in the outbox and is replayed before the next
+	// attempt at this article
+	return s.articleService.flushStorageOutbox(articleID)
+}
+
+// finishRotation records the final status of a worker's run. A rotation
+// completed without failures makes its new key the user's only key.
+func (s *KeyRotationService) finishRotation(rotationID, token uuid.UUID, status, message string, logger *logrus.Entry) {
+	if err := s.recordRotationStatus(rotationID, token, status, message); err != nil {
+		logger.WithError(err).Error("Failed to record key rotation status")
+		return
+	}
+
+	logger.WithFields(logrus.Fields{
+		"status": status,
+		"error":  message,
+	}).Info("Key rotation finished")
+}
+
+// recordRotationStatus records the final status of a run, replacing the
+// user's key check with the new key's when it completed
+func (s *KeyRotationService) recordRotationStatus(rotationID, token uuid.UUID, status, message string) error {
+	now := time.Now()
+	var completedAt *time.Time
+	if status != models.KeyRotationPaused {
+		completedAt = &now
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE key_rotations SET status = $1, error = $2, updated_at = $3, completed_at = $4
+		WHERE id = $5 AND run_token = $6 AND status = $7
+	`, status, message, now, completedAt, rotationID, token, models.KeyRotationRunning)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := result.RowsAffected(); n > 0 && status == models.KeyRotationCompleted {
+		_, err = tx.Exec(`
+			INSERT INTO encryption_key_checks (user_id, verifier)
+			SELECT user_id, new_key_check FROM key_rotations WHERE id = $1 AND new_key_check <> ''
+			ON CONFLICT (user_id) DO UPDATE SET verifier = EXCLUDED.verifier, updated_at = $2
+		`, rotationID, now)
+		if err != nil {
+			return fmt.Errorf("failed to record key check: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// parseRotationIDs parses the user and rotation IDs of a request
+func parseRotationIDs(userID, rotationID string) (uuid.UUID, uuid.UUID, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	rotationUUID, err := uuid.Parse(rotationID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, ErrRotationNotFound
+	}
+
+	return userUUID, rotationUUID, nil
+}
+
+// scanKeyRotation scans a key rotation selected with keyRotationColumns,
+// followed by any extra columns
+func scanKeyRotation(row rowScanner, extra ...interface{}) (*models.KeyRotation, error) {
+	var rotation models.KeyRotation
+	var completedAt sql.NullTime
+
+	dest := []interface{}{&rotation.ID, &rotation.UserID, &rotation.Status, &rotation.Total, &rotation.Rotated,
+		&rotation.Failed, &rotation.Error, &rotation.StartedAt, &rotation.UpdatedAt, &completedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	if completedAt.Valid {
+		rotation.CompletedAt = &completedAt.Time
+	}
+	return &rotation, nil
+}
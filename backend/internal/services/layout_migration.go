@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/database"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/storage"
+)
+
+// defaultLayoutMigrationBatch is the number of articles moved between
+// progress reports when no batch size is given
+const defaultLayoutMigrationBatch = 500
+
+// olderLayoutCondition selects articles whose content is not stored in the
+// current layout, where every content file is named content.json
+const olderLayoutCondition = "local_path IS NOT NULL AND local_path NOT LIKE '%/content.json'"
+
+// LayoutMigrationService moves stored content to the current layout while
+// the server keeps running
+type LayoutMigrationService struct {
+	db             *database.DB
+	storageService *storage.Service
+	logger         *logrus.Logger
+}
+
+// NewLayoutMigrationService creates a new layout migration service
+func NewLayoutMigrationService(db *database.DB, storageService *storage.Service, logger *logrus.Logger) *LayoutMigrationService {
+	return &LayoutMigrationService{
+		db:             db,
+		storageService: storageService,
+		logger:         logger,
+	}
+}
+
+// Run moves every article stored in an older layout, batch by batch,
+// calling progress after each batch. Reads work in either layout
+// throughout. Each article is moved under the same lock as its storage
+// writes and recorded in its row, so a run can be stopped at any point and
+// started again to resume.
+func (s *LayoutMigrationService) Run(ctx context.Context, options *models.LayoutMigrationOptions, progress func(*models.LayoutMigrationProgress)) (*models.LayoutMigrationProgress, error) {
+	if options.UserID != "" {
+		if _, err := uuid.Parse(options.UserID); err != nil {
+			return nil, fmt.Errorf("invalid user ID: %w", err)
+		}
+	}
+	batchSize := options.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultLayoutMigrationBatch
+	}
+
+	started := time.Now()
+	report := &models.LayoutMigrationProgress{}
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM articles WHERE `+olderLayoutCondition+` AND ($1 = '' OR user_id::text = $1)
+	`, options.UserID).Scan(&report.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count articles to migrate: %w", err)
+	}
+	if options.DryRun || report.Total == 0 {
+		return report, nil
+	}
+
+	after := uuid.Nil
+	for {
+		batch, err := s.nextBatch(ctx, options.UserID, after, batchSize)
+		if err != nil {
+			return report, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, article := range batch {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+
+			moved, err := s.moveArticle(article.ID, article.UserID)
+			switch {
+			case err != nil:
+				report.Failed++
+				s.logger.WithError(err).WithField("article_id", article.ID).Warn("Failed to move article content")
+			case moved:
+				report.Moved++
+			default:
+				report.Skipped++
+			}
+			after = article.ID
+		}
+
+		report.Elapsed = time.Since(started)
+		if progress != nil {
+			progress(report)
+		}
+
+		if options.Pause > 0 {
+			select {
+			case <-ctx.Done():
+				return report, ctx.Err()
+			case <-time.After(options.Pause):
+			}
+		}
+	}
+
+	report.Elapsed = time.Since(started)
+	s.logger.WithFields(logrus.Fields{
+		"moved":   report.Moved,
+		"skipped": report.Skipped,
+		"failed":  report.Failed,
+	}).Info("Storage layout migration completed")
+
+	return report, nil
+}
+
+// layoutMigrationArticle is an article waiting to be moved
+type layoutMigrationArticle struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+// nextBatch returns the next articles in an older layout after a cursor
+func (s *LayoutMigrationService) nextBatch(ctx context.Context, userID string, after uuid.UUID, limit int) ([]layoutMigrationArticle, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id
+		FROM articles
+		WHERE `+olderLayoutCondition+` AND ($1 = '' OR user_id::text = $1) AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`, userID, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get articles to migrate: %w", err)
+	}
+	defer rows.Close()
+
+	batch := []layoutMigrationArticle{}
+	for rows.Next() {
+		var article layoutMigrationArticle
+		if err := rows.Scan(&article.ID, &article.UserID); err != nil {
+			return nil, fmt.Errorf("failed to scan article: %w", err)
+		}
+		batch = append(batch, article)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read articles to migrate: %w", err)
+	}
+
+	return batch, nil
+}
+
+// moveArticle moves the content of one article and records its new key.
+// It returns false when the article no longer needs moving.
+func (s *LayoutMigrationService) moveArticle(articleID, userID uuid.UUID) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialize with the article's storage writes and deletes
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "storage_outbox:"+articleID.String()); err != nil {
+		return false, fmt.Errorf("failed to lock storage outbox: %w", err)
+	}
+
+	// Pending operations write the current layout when they are applied
+	entries, err := pendingStorageOperations(tx, articleID)
+	if err != nil {
+		return false, err
+	}
+	if len(entries) > 0 {
+		return false, nil
+	}
+
+	var localPath sql.NullString
+	err = tx.QueryRow("SELECT local_path FROM articles WHERE id = $1", articleID).Scan(&localPath)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get article: %w", err)
+	}
+	if location, ok := storage.ParseContentKey(localPath.String); !localPath.Valid || (ok && location.Layout == storage.CurrentLayout) {
+		return false, nil
+	}
+
+	key, err := s.storageService.MoveContent(userID.String(), articleID.String())
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec("UPDATE articles SET local_path = $1 WHERE id = $2", key, articleID); err != nil {
+		return false, fmt.Errorf("failed to record content key: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
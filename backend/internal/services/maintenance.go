@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/config"
+	"github.com/readitlater/backend/internal/database"
+	"github.com/readitlater/backend/internal/scheduler"
+	"github.com/readitlater/backend/internal/search"
+	"github.com/readitlater/backend/internal/storage"
+)
+
+// tempFileMaxAge is how old a temporary file must be before it is treated
+// as left behind by a crash
+const tempFileMaxAge = time.Hour
+
+// MaintenanceService provides the built-in background maintenance jobs
+type MaintenanceService struct {
+	cfg            *config.Config
+	db             *database.DB
+	articleService *ArticleService
+	storageBackend storage.Backend
+	searchIndex    search.Index
+	logger         *logrus.Logger
+}
+
+// NewMaintenanceService creates a new maintenance service
+func NewMaintenanceService(cfg *config.Config, db *database.DB, articleService *ArticleService, storageBackend storage.Backend, searchIndex search.Index, logger *logrus.Logger) *MaintenanceService {
+	return &MaintenanceService{
+		cfg:            cfg,
+		db:             db,
+		articleService: articleService,
+		storageBackend: storageBackend,
+		searchIndex:    searchIndex,
+		logger:         logger,
+	}
+}
+
+// Jobs returns the built-in maintenance jobs
+func (s *MaintenanceService) Jobs() ([]scheduler.Job, error) {
+	statsSchedule, err := scheduler.ParseCron(s.cfg.StatsRecomputeSchedule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stats recompute schedule: %w", err)
+	}
+
+	cleanup := scheduler.Every(s.cfg.CleanupInterval)
+
+	return []scheduler.Job{
+		{Name: "session-cleanup", Schedule: cleanup, Run: s.CleanupSessions},
+		{Name: "temp-file-cleanup", Schedule: cleanup, Run: s.CleanupTempFiles},
+		{Name: "content-gc", Schedule: cleanup, Run: s.CollectContent},
+		{Name: "stats-recompute", Schedule: statsSchedule, Run: s.RecomputeStats},
+	}, nil
+}
+
+// CleanupSessions deletes expired sessions
+func (s *MaintenanceService) CleanupSessions(ctx context.Context) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE expires_at < $1", time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+
+	if deleted, err := result.RowsAffected(); err == nil && deleted > 0 {
+		s.logger.WithField("sessions", deleted).Info("Deleted expired sessions")
+	}
+	return nil
+}
+
+// CleanupTempFiles removes temporary files left behind by writes to the
+// storage backend and search index that were interrupted by a crash
+func (s *MaintenanceService) CleanupTempFiles(ctx context.Context) error {
+	cleaners := map[string]interface{}{
+		"storage": s.storageBackend,
+		"search":  s.searchIndex,
+	}
+
+	for name, target := range cleaners {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		cleaner, ok := target.(storage.TempCleaner)
+		if !ok {
+			continue
+		}
+
+		removed, err := cleaner.CleanupTempFiles(tempFileMaxAge)
+		if err != nil {
+			return fmt.Errorf("failed to clean up %s temporary files: %w", name, err)
+		}
+		if removed > 0 {
+			s.logger.WithFields(logrus.Fields{
+				"target": name,
+				"files":  removed,
+			}).Info("Removed stale temporary files")
+		}
+	}
+
+	return nil
+}
+
+// CollectContent removes content blobs no article refers to any more
+func (s *MaintenanceService) CollectContent(ctx context.Context) error {
+	_, err := s.articleService.CollectBlobs(ctx)
+	return err
+}
+
+// RecomputeStats rebuilds the storage counters of every user
+func (s *MaintenanceService) RecomputeStats(ctx context.Context) error {
+	_, err := s.articleService.RecomputeStorageStats(ctx)
+	return err
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/readitlater/backend/internal/storage"
+)
+
+// Storage outbox operations
+const (
+	outboxWrite  = "write"
+	outboxDelete = "delete"
+)
+
+// outboxEntry is a pending storage operation
+type outboxEntry struct {
+	ID        int64
+	UserID    uuid.UUID
+	Operation string
+	Payload   []byte
+}
+
+// enqueueStorage records a storage operation for an article in the
+// transaction that changes the article, so the change and its storage
+// operation are committed together
+func enqueueStorage(tx *sql.Tx, userID, articleID uuid.UUID, operation string, payload []byte) error {
+	_, err := tx.Exec(`
+		INSERT INTO storage_outbox (user_id, article_id, operation, payload)
+		VALUES ($1, $2, $3, $4)
+	`, userID, articleID, operation, payload)
+	if err != nil {
+		return fmt.Errorf("failed to record storage operation: %w", err)
+	}
+	return nil
+}
+
+// flushStorageOutbox applies the pending storage operations of an article
+// in the order they were recorded. Operations for the same article are
+// serialized across requests and replicas, so a delete can never be
+// overtaken by an earlier write. Failed operations stay in the outbox.
+func (s *ArticleService) flushStorageOutbox(articleID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "storage_outbox:"+articleID.String()); err != nil {
+		return fmt.Errorf("failed to lock storage outbox: %w", err)
+	}
+
+	entries, err := pendingStorageOperations(tx, articleID)
+	if err != nil {
+		return err
+	}
+
+	applied := []int64{}
+	var applyErr error
+	for _, entry := range entries {
+		if applyErr = s.applyStorageOperation(tx, articleID, entry); applyErr != nil {
+			_, err := tx.Exec(`
+				UPDATE storage_outbox SET attempts = attempts + 1, last_error = $1 WHERE id = $2
+			`, applyErr.Error(), entry.ID)
+			if err != nil {
+				return fmt.Errorf("failed to record storage failure: %w", err)
+			}
+			break
+		}
+		applied = append(applied, entry.ID)
+	}
+
+	if len(applied) > 0 {
+		if _, err := tx.Exec("DELETE FROM storage_outbox WHERE id = ANY($1)", pq.Array(applied)); err != nil {
+			return fmt.Errorf("failed to complete storage operations: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit storage outbox: %w", err)
+	}
+
+	return applyErr
+}
+
+// pendingStorageOperations loads the outbox entries of an article in order
+func pendingStorageOperations(tx *sql.Tx, articleID uuid.UUID) ([]outboxEntry, error) {
+	rows, err := tx.Query(`
+		SELECT id, user_id, operation, payload
+		FROM storage_outbox
+		WHERE article_id = $1
+		ORDER BY id
+	`, articleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage operations: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []outboxEntry{}
+	for rows.Next() {
+		var entry outboxEntry
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.Operation, &entry.Payload); err != nil {
+			return nil, fmt.Errorf("failed to scan storage operation: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read storage operations: %w", err)
+	}
+
+	return entries, nil
+}
+
+// applyStorageOperation performs an outbox entry. Operations are idempotent
+// so replaying an entry that already took effect is harmless.
+func (s *ArticleService) applyStorageOperation(tx *sql.Tx, articleID uuid.UUID, entry outboxEntry) error {
+	switch entry.Operation {
+	case outboxWrite:
+		object, err := s.storageService.WriteContent(entry.UserID.String(), articleID.String(), entry.Payload)
+		if err != nil {
+			return err
+		}
+
+		// Record where the content now lives, which moves articles written
+		// in an older layout, and its compressed size for the storage stats
+		if _, err := tx.Exec("UPDATE articles SET local_path = $1, stored_size = $2 WHERE id = $3", object.Key, object.Size, articleID); err != nil {
+			return fmt.Errorf("failed to record stored content: %w", err)
+		}
+		return nil
+	case outboxDelete:
+		err := s.storageService.DeleteContent(entry.UserID.String(), articleID.String())
+		if errors.Is(err, storage.ErrContentNotFound) {
+			return nil
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown storage operation: %s", entry.Operation)
+	}
+}
+
+// ReconcileStorage replays storage operations left in the outbox by a
+// crash or a storage failure. It returns the number of articles whose
+// storage was brought up to date.
+func (s *ArticleService) ReconcileStorage() (int, error) {
+	rows, err := s.db.Query("SELECT DISTINCT article_id FROM storage_outbox")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get storage outbox: %w", err)
+	}
+
+	articleIDs := []uuid.UUID{}
+	for rows.Next() {
+		var articleID uuid.UUID
+		if err := rows.Scan(&articleID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan storage outbox: %w", err)
+		}
+		articleIDs = append(articleIDs, articleID)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read storage outbox: %w", err)
+	}
+
+	reconciled := 0
+	for _, articleID := range articleIDs {
+		if err := s.flushStorageOutbox(articleID); err != nil {
+			s.logger.WithError(err).WithField("article_id", articleID.String()).Warn("Failed to reconcile article storage")
+			continue
+		}
+		reconciled++
+	}
+
+	return reconciled, nil
+}
+
+// discardArticle removes an article whose content could not be stored,
+// together with its pending storage operations
+func (s *ArticleService) discardArticle(articleID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM storage_outbox WHERE article_id = $1", articleID); err != nil {
+		return fmt.Errorf("failed to discard storage operations: %w", err)
+	}
+	var contentHash sql.NullString
+	err = tx.QueryRow("DELETE FROM articles WHERE id = $1 RETURNING content_hash", articleID).Scan(&contentHash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to discard article: %w", err)
+	}
+	if err := releaseBlob(tx, contentHash); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit article removal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/config"
+	"github.com/readitlater/backend/internal/models"
+)
+
+// ErrStorageQuotaExceeded is returned when saving content would take a
+// user past their storage quota
+var ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+
+// GetStorageUsage returns a user's stored content size against their quota
+func (s *ArticleService) GetStorageUsage(userID string) (*models.StorageUsage, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	var used, limit int64
+	err = s.db.QueryRow(`
+		SELECT coalesce(storage_used, 0), coalesce(max_storage_limit, 0) FROM users WHERE id = $1
+	`, userUUID).Scan(&used, &limit)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+
+	return s.storageUsage(used, limit), nil
+}
+
+// reserveStorage checks that size more bytes fit in a user's quota and
+// returns their usage before the save. The user's row stays locked until
+// tx ends, so concurrent saves cannot both pass the check.
+func (s *ArticleService) reserveStorage(tx *sql.Tx, userID uuid.UUID, size int64) (*models.StorageUsage, error) {
+	var used, limit int64
+	err := tx.QueryRow(`
+		SELECT coalesce(storage_used, 0), coalesce(max_storage_limit, 0) FROM users WHERE id = $1 FOR UPDATE
+	`, userID).Scan(&used, &limit)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+
+	usage := s.storageUsage(used, limit)
+	if usage.Limit > 0 && used+size > usage.Limit {
+		return nil, fmt.Errorf("%w: %s of %s used, article needs %s", ErrStorageQuotaExceeded,
+			config.FormatSize(used), config.FormatSize(usage.Limit), config.FormatSize(size))
+	}
+
+	return usage, nil
+}
+
+// storageUsage describes usage against the effective quota: the user's
+// max_storage_limit capped by MAX_STORAGE_SIZE, where zero means unlimited
+func (s *ArticleService) storageUsage(used, userLimit int64) *models.StorageUsage {
+	limit := userLimit
+	if serverLimit := s.config.MaxStorageBytes; serverLimit > 0 && (limit <= 0 || serverLimit < limit) {
+		limit = serverLimit
+	}
+	if limit < 0 {
+		limit = 0
+	}
+
+	usage := &models.StorageUsage{Used: used, Limit: limit}
+	if limit > 0 {
+		usage.Percent = float64(used) / float64(limit) * 100
+		for _, threshold := range s.config.StorageWarningThresholds {
+			if usage.Percent >= float64(threshold) {
+				usage.Warning = threshold
+			}
+		}
+	}
+	return usage
+}
+
+// warnStorageThreshold logs when a save of size bytes takes a user across
+// a storage warning threshold
+func (s *ArticleService) warnStorageThreshold(userID string, before *models.StorageUsage, size int64) {
+	after := s.storageUsage(before.Used+size, before.Limit)
+	if after.Warning <= before.Warning {
+		return
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"used":      after.Used,
+		"limit":     after.Limit,
+		"threshold": after.Warning,
+	}).Warn("User storage usage crossed warning threshold")
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/search"
+)
+
+// facetValues maps each facet to the SQL expression producing its value and
+// the FROM clause it is selected from
+var facetValues = map[string]struct{ value, from string }{
+	models.FacetTag:       {"tag", "matched, " + articleTags + " AS tag"},
+	models.FacetDomain:    {articleDomain, "matched"},
+	models.FacetCategory:  {"category", "matched"},
+	models.FacetAuthor:    {"author", "matched"},
+	models.FacetReadState: {"CASE WHEN is_read THEN 'read' ELSE 'unread' END", "matched"},
+	models.FacetYear:      {articleYear + "::text", "matched"},
+}
+
+// maxFacetBuckets caps the number of buckets returned per facet
+const maxFacetBuckets = 20
+
+// maxSearchHits caps the number of index hits passed to the database.
+// Results and facets only cover the best ranked hits.
+const maxSearchHits = 1000
+
+// SearchArticles searches articles by text
+func (s *ArticleService) SearchArticles(userID, query string, filter *models.ArticleFilter) ([]*models.Article, error) {
+	where, args, score, err := s.buildSearchCondition(userID, query, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	searchQuery := fmt.Sprintf(`
+		SELECT id, user_id, coalesce(title, ''), url, coalesce(description, ''), tags, coalesce(category, ''),
+			is_read, is_favorite, is_archived, created_at, updated_at, is_private, coalesce(private_fields, ''),
+			is_encrypted, server_encrypted, client_encrypted
+		FROM articles
+		WHERE %s
+		ORDER BY %s
+	`, where, articleOrder(filter, score))
+
+	// Add pagination
+	if filter != nil && filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		searchQuery += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	if filter != nil && filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		searchQuery += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := s.db.Query(searchQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search articles: %w", err)
+	}
+	defer rows.Close()
+
+	return scanArticles(rows)
+}
+
+// GetSearchFacets counts the articles matching a search and computes the
+// requested facet buckets for them in a single query. Selecting a bucket is
+// done by setting the corresponding ArticleFilter field.
+func (s *ArticleService) GetSearchFacets(userID, query string, filter *models.ArticleFilter, facets []string) (*models.SearchFacets, error) {
+	where, args, _, err := s.buildSearchCondition(userID, query, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := []string{"SELECT '' AS facet, '' AS value, COUNT(*) AS count FROM matched"}
+	for _, facet := range facets {
+		source, ok := facetValues[facet]
+		if !ok {
+			return nil, fmt.Errorf("invalid facet: %s", facet)
+		}
+		parts = append(parts, fmt.Sprintf(`
+			SELECT '%s', value, COUNT(*)
+			FROM (SELECT %s AS value FROM %s) f
+			WHERE coalesce(value, '') <> ''
+			GROUP BY value`, facet, source.value, source.from))
+	}
+
+	args = append(args, maxFacetBuckets)
+	facetQuery := fmt.Sprintf(`
+		WITH matched AS (
+			SELECT url, tags, category, author, is_read, created_at
+			FROM articles
+			WHERE %s
+		), buckets AS (
+			%s
+		)
+		SELECT facet, value, count
+		FROM (
+			SELECT facet, value, count,
+				row_number() OVER (PARTITION BY facet ORDER BY count DESC, value) AS rank
+			FROM buckets
+		) ranked
+		WHERE facet = '' OR rank <= $%d
+		ORDER BY facet, rank
+	`, where, strings.Join(parts, "\n\t\t\tUNION ALL"), len(args))
+
+	rows, err := s.db.Query(facetQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute search facets: %w", err)
+	}
+	defer rows.Close()
+
+	result := &models.SearchFacets{Facets: map[string][]models.FacetBucket{}}
+	for _, facet := range facets {
+		result.Facets[facet] = []models.FacetBucket{}
+	}
+
+	for rows.Next() {
+		var facet string
+		var bucket models.FacetBucket
+		if err := rows.Scan(&facet, &bucket.Value, &bucket.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan facet bucket: %w", err)
+		}
+
+		// The unnamed facet carries the total number of matches
+		if facet == "" {
+			result.Total = bucket.Count
+			continue
+		}
+		result.Facets[facet] = append(result.Facets[facet], bucket)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read facet buckets: %w", err)
+	}
+
+	return result, nil
+}
+
+// buildSearchCondition returns the WHERE clause, arguments and ranking
+// expression shared by search results and facets. Matching is delegated to
+// the search index; its best maxSearchHits hits are passed to the query in
+// rank order.
+func (s *ArticleService) buildSearchCondition(userID, query string, filter *models.ArticleFilter) (string, []interface{}, string, error) {
+	// Parse user ID
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	mode := models.SearchModeFullText
+	if filter != nil && filter.SearchMode != "" {
+		mode = filter.SearchMode
+	}
+	if !models.IsValidSearchMode(mode) {
+		return "", nil, "", fmt.Errorf("invalid search mode: %s", mode)
+	}
+
+	hits, err := s.searchIndex.Search(userID, query, mode)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("failed to search index: %w", err)
+	}
+	if len(hits) > maxSearchHits {
+		hits = hits[:maxSearchHits]
+	}
+
+	ids := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ArticleID)
+	}
+
+	where := "user_id = $1 AND id = ANY($2::uuid[])"
+	args := []interface{}{userUUID, pq.Array(ids)}
+	where, args = applyArticleFilter(where, args, filter)
+
+	return where, args, "array_position($2::uuid[], id)", nil
+}
+
+// indexArticle refreshes the search index entry of an article from the
+// database. Index failures are logged; RebuildSearchIndex repairs them.
+func (s *ArticleService) indexArticle(articleID uuid.UUID) {
+	query := "SELECT " + searchDocumentColumns + " FROM articles WHERE id = $1"
+	doc, err := scanSearchDocument(s.db.QueryRow(query, articleID))
+	if err != nil {
+		s.logger.WithError(err).WithField("article_id", articleID.String()).Warn("Failed to load article for search index")
+		return
+	}
+
+	if err := s.searchIndex.Index(doc); err != nil {
+		s.logger.WithError(err).WithField("article_id", articleID.String()).Warn("Failed to index article")
+	}
+}
+
+// unindexArticle removes an article from the search index
+func (s *ArticleService) unindexArticle(userID, articleID string) {
+	if err := s.searchIndex.Delete(userID, articleID); err != nil {
+		s.logger.WithError(err).WithField("article_id", articleID).Warn("Failed to remove article from search index")
+	}
+}
+
+// RebuildSearchIndex clears the search index and indexes every article
+// again. It returns the number of indexed articles.
+func (s *ArticleService) RebuildSearchIndex() (int, error) {
+	if err := s.searchIndex.Clear(); err != nil {
+		return 0, err
+	}
+
+	rows, err := s.db.Query("SELECT " + searchDocumentColumns + " FROM articles ORDER BY user_id, created_at")
+	if err != nil {
+		return 0, fmt.Errorf("failed to query articles: %w", err)
+	}
+	defer rows.Close()
+
+	const batchSize = 500
+	batch := make([]*search.Document, 0, batchSize)
+	indexed := 0
+	for rows.Next() {
+		doc, err := scanSearchDocument(rows)
+		if err != nil {
+			return indexed, fmt.Errorf("failed to scan article: %w", err)
+		}
+
+		batch = append(batch, doc)
+		if len(batch) == batchSize {
+			if err := s.searchIndex.Index(batch...); err != nil {
+				return indexed, fmt.Errorf("failed to index articles: %w", err)
+			}
+			indexed += len(batch)
+			batch = batch[:0]
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return indexed, fmt.Errorf("failed to read articles: %w", err)
+	}
+
+	if err := s.searchIndex.Index(batch...); err != nil {
+		return indexed, fmt.Errorf("failed to index articles: %w", err)
+	}
+	indexed += len(batch)
+
+	s.logger.WithFields(logrus.Fields{
+		"backend":  s.searchIndex.Name(),
+		"articles": indexed,
+	}).Info("Search index rebuilt successfully")
+
+	return indexed, nil
+}
+
+// searchDocumentColumns selects the fields of a search document. Only the
+// IDs of full-privacy articles are selected, marked private so the index
+// never matches them.
+const searchDocumentColumns = `id, user_id,
+	CASE WHEN is_private THEN '' ELSE coalesce(title, '') END,
+	CASE WHEN is_private THEN '' ELSE coalesce(description, '') END,
+	CASE WHEN is_private THEN '' ELSE coalesce(content_text, '') END,
+	CASE WHEN is_private THEN '' ELSE url END,
+	CASE WHEN is_private THEN '' ELSE coalesce(author, '') END,
+	CASE WHEN is_private THEN '' ELSE coalesce(site_name, '') END,
+	CASE WHEN is_private THEN '[]' ELSE tags END,
+	created_at, is_private`
+
+// scanSearchDocument scans a search document selected with searchDocumentColumns
+func scanSearchDocument(row rowScanner) (*search.Document, error) {
+	var doc search.Document
+	var tagsJSON string
+
+	err := row.Scan(&doc.ArticleID, &doc.UserID, &doc.Title, &doc.Description, &doc.ContentText,
+		&doc.URL, &doc.Author, &doc.SiteName, &tagsJSON, &doc.CreatedAt, &doc.Private)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse tags
+	if tagsJSON != "" {
+		json.Unmarshal([]byte(tagsJSON), &doc.Tags)
+	}
+
+	return &doc, nil
+}
+
+// GetSearchSuggestions returns title, tag, domain and author suggestions
+// for a partial query. Prefix matches come first, followed by fuzzy matches;
+// within each group suggestions are ranked by frequency and recency.
+func (s *ArticleService) GetSearchSuggestions(userID, query string, limit int) ([]*models.SearchSuggestion, error) {
+	// Parse user ID
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	suggestionQuery := `
+		WITH candidates AS (
+			SELECT title AS text, 'title' AS type, created_at
+			FROM articles
+			WHERE user_id = $1 AND NOT is_private AND coalesce(title, '') <> ''
+			UNION ALL
+			SELECT tag, 'tag', created_at
+			FROM articles, ` + articleTags + ` AS tag
+			WHERE user_id = $1
+			UNION ALL
+			SELECT ` + articleDomain + `, 'domain', created_at
+			FROM articles
+			WHERE user_id = $1 AND NOT is_private
+			UNION ALL
+			SELECT author, 'author', created_at
+			FROM articles
+			WHERE user_id = $1 AND NOT is_private AND coalesce(author, '') <> ''
+		)
+		SELECT text, type, COUNT(*) AS count, MAX(created_at) AS last_seen,
+			word_similarity($2, text) AS score,
+			text ILIKE $3 AS is_prefix
+		FROM candidates
+		WHERE coalesce(text, '') <> '' AND (text ILIKE $3 OR $2 <% text)
+		GROUP BY text, type
+		ORDER BY is_prefix DESC, count DESC, last_seen DESC, score DESC
+		LIMIT $4
+	`
+
+	rows, err := s.db.Query(suggestionQuery, userUUID, query, search.EscapeLike(query)+"%", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get search suggestions: %w", err)
+	}
+	defer rows.Close()
+
+	suggestions := []*models.SearchSuggestion{}
+	for rows.Next() {
+		var suggestion models.SearchSuggestion
+		if err := rows.Scan(
+			&suggestion.Text, &suggestion.Type, &suggestion.Count, &suggestion.LastSeen,
+			&suggestion.Score, &suggestion.IsPrefix,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan search suggestion: %w", err)
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read search suggestions: %w", err)
+	}
+
+	return suggestions, nil
+}
+
+// scanArticles scans article rows selected with the standard column list
+func scanArticles(rows *sql.Rows) ([]*models.Article, error) {
+	articles := []*models.Article{}
+	for rows.Next() {
+		var article models.Article
+		var tagsJSON string
+
+		err := rows.Scan(
+			&article.ID, &article.UserID, &article.Title, &article.URL, &article.Description,
+			&tagsJSON, &article.Category, &article.IsRead, &article.IsFavorite, &article.IsArchived,
+			&article.CreatedAt, &article.UpdatedAt, &article.IsPrivate, &article.PrivateFields,
+			&article.IsEncrypted, &article.ServerEncrypted, &article.ClientEncrypted,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan article: %w", err)
+		}
+
+		// Parse tags
+		if tagsJSON != "" {
+			json.Unmarshal([]byte(tagsJSON), &article.Tags)
+		}
+
+		articles = append(articles, &article)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read articles: %w", err)
+	}
+
+	return articles, nil
+}
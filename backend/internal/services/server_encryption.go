@@ -0,0 +1,241 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/readitlater/backend/internal/encryption"
+	"github.com/readitlater/backend/internal/models"
+)
+
+// ErrMasterKeyUnavailable is returned when a server-managed key is wrapped
+// by a master key that is neither ENCRYPTION_KEY nor in
+// ENCRYPTION_KEY_PREVIOUS
+var ErrMasterKeyUnavailable = errors.New("server-managed key is wrapped by a master key that is not configured")
+
+// EncryptionStatus returns the encryption modes the server offers
+func (s *ArticleService) EncryptionStatus() *models.EncryptionStatus {
+	status := &models.EncryptionStatus{
+		ZeroKnowledge: true,
+		ServerManaged: s.config.ServerSideEncryption,
+		DefaultMode:   models.EncryptionModeNone,
+	}
+	if status.ServerManaged {
+		status.DefaultMode = models.EncryptionModeServerManaged
+	}
+	return status
+}
+
+// serverKey returns a user's server-managed key, creating it the first
+// time the user saves content under server-side encryption
+func (s *ArticleService) serverKey(userID uuid.UUID) (string, error) {
+	serverKey, err := s.loadServerKey(userID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return serverKey, err
+	}
+
+	if serverKey, err = s.encryption.GenerateUserKey(); err != nil {
+		return "", err
+	}
+	masterKeyID, err := encryption.MasterKeyID(s.config.EncryptionKey)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := s.encryption.WrapServerKey(s.config.EncryptionKey, userID.String(), serverKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap server key: %w", err)
+	}
+
+	// A concurrent request may have created the key first, in which case
+	// its key is the one to use
+	_, err = s.db.Exec(`
+		INSERT INTO server_encryption_keys (user_id, wrapped_key, master_key_id) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO NOTHING
+	`, userID, wrapped, masterKeyID)
+	if err != nil {
+		return "", fmt.Errorf("failed to store server key: %w", err)
+	}
+
+	return s.loadServerKey(userID)
+}
+
+// loadServerKey reads and unwraps a user's server-managed key. It returns
+// sql.ErrNoRows when the user has none.
+func (s *ArticleService) loadServerKey(userID uuid.UUID) (string, error) {
+	var wrapped, masterKeyID string
+	err := s.db.QueryRow("SELECT wrapped_key, master_key_id FROM server_encryption_keys WHERE user_id = $1", userID).Scan(&wrapped, &masterKeyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get server key: %w", err)
+	}
+
+	masterKeys, _, err := s.masterKeys()
+	if err != nil {
+		return "", err
+	}
+	masterKey, ok := masterKeys[masterKeyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrMasterKeyUnavailable, masterKeyID)
+	}
+
+	return s.encryption.UnwrapServerKey(masterKey, userID.String(), wrapped)
+}
+
+// masterKeys returns the configured master keys by ID and the ID of the
+// current one
+func (s *ArticleService) masterKeys() (map[string]string, string, error) {
+	currentID, err := encryption.MasterKeyID(s.config.EncryptionKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keys := map[string]string{currentID: s.config.EncryptionKey}
+	for _, key := range s.config.PreviousEncryptionKeys {
+		id, err := encryption.MasterKeyID(key)
+		if err != nil {
+			return nil, "", err
+		}
+		keys[id] = key
+	}
+	return keys, currentID, nil
+}
+
+// serverDecryptedContent reads the content of a server-encrypted article
+// and decrypts it with the user's server-managed key
+func (s *ArticleService) serverDecryptedContent(userID, articleID string) (string, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return "", fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	serverKey, err := s.loadServerKey(userUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("server-encrypted article has no server key")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return s.storageService.GetDecryptedContent(userID, articleID, serverKey)
+}
+
+// RewrapServerKeys rewraps every server-managed key wrapped by a previous
+// master key under the current one and returns how many were rewrapped.
+// Content is untouched. Keys wrapped by a master key that is no longer
+// configured are skipped; MasterKeyUsage reports them.
+func (s *ArticleService) RewrapServerKeys() (int, error) {
+	masterKeys, currentID, err := s.masterKeys()
+	if err != nil {
+		return 0, err
+	}
+
+	type wrappedKey struct {
+		userID      uuid.UUID
+		wrapped     string
+		masterKeyID string
+	}
+
+	rows, err := s.db.Query("SELECT user_id, wrapped_key, master_key_id FROM server_encryption_keys WHERE master_key_id <> $1", currentID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query server keys: %w", err)
+	}
+	var stale []wrappedKey
+	for rows.Next() {
+		var key wrappedKey
+		if err := rows.Scan(&key.userID, &key.wrapped, &key.masterKeyID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan server key: %w", err)
+		}
+		stale = append(stale, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read server keys: %w", err)
+	}
+
+	rewrapped := 0
+	for _, key := range stale {
+		masterKey, ok := masterKeys[key.masterKeyID]
+		if !ok {
+			continue
+		}
+
+		serverKey, err := s.encryption.UnwrapServerKey(masterKey, key.userID.String(), key.wrapped)
+		if err != nil {
+			return rewrapped, fmt.Errorf("failed to unwrap server key of user %s: %w", key.userID, err)
+		}
+		wrapped, err := s.encryption.WrapServerKey(s.config.EncryptionKey, key.userID.String(), serverKey)
+		if err != nil {
+			return rewrapped, fmt.Errorf("failed to wrap server key: %w", err)
+		}
+
+		// Another replica may have rewrapped the key in the meantime
+		result, err := s.db.Exec(`
+			UPDATE server_encryption_keys SET wrapped_key = $1, master_key_id = $2, updated_at = $3
+			WHERE user_id = $4 AND master_key_id = $5
+		`, wrapped, currentID, time.Now(), key.userID, key.masterKeyID)
+		if err != nil {
+			return rewrapped, fmt.Errorf("failed to update server key: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			rewrapped++
+		}
+	}
+
+	return rewrapped, nil
+}
+
+// MasterKeyUsage returns how many server-managed keys each master key
+// wraps and how many retained backups are encrypted under it. A master key
+// can be dropped from ENCRYPTION_KEY_PREVIOUS once it has neither.
+func (s *ArticleService) MasterKeyUsage() ([]*models.MasterKeyUsage, error) {
+	masterKeys, currentID, err := s.masterKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT master_key_id, sum(keys), sum(backups)
+		FROM (
+			SELECT master_key_id, count(*) AS keys, 0 AS backups
+			FROM server_encryption_keys
+			GROUP BY master_key_id
+			UNION ALL
+			SELECT master_key_id, 0, count(*)
+			FROM backups
+			WHERE status = $1 AND master_key_id <> ''
+			GROUP BY master_key_id
+		) usage
+		GROUP BY master_key_id
+		ORDER BY master_key_id
+	`, models.BackupStatusCompleted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query master key usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := []*models.MasterKeyUsage{{MasterKeyID: currentID, Current: true, Configured: true}}
+	for rows.Next() {
+		var id string
+		var keys, backups int
+		if err := rows.Scan(&id, &keys, &backups); err != nil {
+			return nil, fmt.Errorf("failed to scan master key usage: %w", err)
+		}
+		if id == currentID {
+			usage[0].Keys, usage[0].Backups = keys, backups
+			continue
+		}
+		_, configured := masterKeys[id]
+		usage = append(usage, &models.MasterKeyUsage{MasterKeyID: id, Configured: configured, Keys: keys, Backups: backups})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read master key usage: %w", err)
+	}
+
+	return usage, nil
+}
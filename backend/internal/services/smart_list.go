@@ -0,0 +1,338 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/database"
+	"github.com/readitlater/backend/internal/models"
+)
+
+// ErrSmartListNotFound is returned when a smart list does not exist or
+// belongs to another user
+var ErrSmartListNotFound = errors.New("smart list not found")
+
+// SmartListService handles saved searches
+type SmartListService struct {
+	db             *database.DB
+	articleService *ArticleService
+	logger         *logrus.Logger
+}
+
+// NewSmartListService creates a new smart list service
+func NewSmartListService(db *database.DB, articleService *ArticleService, logger *logrus.Logger) *SmartListService {
+	return &SmartListService{
+		db:             db,
+		articleService: articleService,
+		logger:         logger,
+	}
+}
+
+const smartListColumns = `id, user_id, name, query, filter, sort_by, sort_order, position, is_pinned, created_at, updated_at`
+
+// CreateSmartList creates a new smart list at the end of the user's lists
+func (s *SmartListService) CreateSmartList(userID string, create *models.SmartListCreate) (*models.SmartList, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	list := &models.SmartList{
+		ID:        uuid.New(),
+		UserID:    userUUID,
+		Name:      strings.TrimSpace(create.Name),
+		Query:     create.Query,
+		Filter:    create.Filter,
+		SortBy:    create.SortBy,
+		SortOrder: create.SortOrder,
+		IsPinned:  create.IsPinned,
+	}
+	if err := validateSmartList(list); err != nil {
+		return nil, err
+	}
+
+	filterJSON, err := marshalListFilter(list.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO smart_lists (id, user_id, name, query, filter, sort_by, sort_order, position, is_pinned)
+		VALUES ($1, $2, $3, $4, $5, $6, $7,
+			(SELECT COALESCE(MAX(position), -1) + 1 FROM smart_lists WHERE user_id = $2), $8)
+		RETURNING position, created_at, updated_at
+	`
+	err = s.db.QueryRow(query, list.ID, list.UserID, list.Name, list.Query, filterJSON,
+		list.SortBy, list.SortOrder, list.IsPinned,
+	).Scan(&list.Position, &list.CreatedAt, &list.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create smart list: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id":       userID,
+		"smart_list_id": list.ID.String(),
+	}).Info("Smart list created successfully")
+
+	return list, nil
+}
+
+// GetSmartList retrieves a smart list by ID
+func (s *SmartListService) GetSmartList(userID, listID string) (*models.SmartList, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	listUUID, err := uuid.Parse(listID)
+	if err != nil {
+		return nil, ErrSmartListNotFound
+	}
+
+	row := s.db.QueryRow("SELECT "+smartListColumns+" FROM smart_lists WHERE id = $1 AND user_id = $2", listUUID, userUUID)
+	list, err := scanSmartList(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSmartListNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get smart list: %w", err)
+	}
+
+	return list, nil
+}
+
+// GetSmartLists retrieves all smart lists of a user, pinned lists first,
+// with live article counts
+func (s *SmartListService) GetSmartLists(userID string) ([]*models.SmartList, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	rows, err := s.db.Query(
+		"SELECT "+smartListColumns+" FROM smart_lists WHERE user_id = $1 ORDER BY is_pinned DESC, position, created_at",
+		userUUID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query smart lists: %w", err)
+	}
+	defer rows.Close()
+
+	lists := []*models.SmartList{}
+	for rows.Next() {
+		list, err := scanSmartList(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan smart list: %w", err)
+		}
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read smart lists: %w", err)
+	}
+
+	for _, list := range lists {
+		count, err := s.articleService.CountArticles(list.ApplyTo(nil))
+		if err != nil {
+			s.logger.WithError(err).WithField("smart_list_id", list.ID.String()).Warn("Failed to count smart list articles")
+			continue
+		}
+		list.Count = count
+	}
+
+	return lists, nil
+}
+
+// UpdateSmartList updates a smart list
+func (s *SmartListService) UpdateSmartList(userID, listID string, update *models.SmartListUpdate) (*models.SmartList, error) {
+	list, err := s.GetSmartList(userID, listID)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Name != nil {
+		list.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Query != nil {
+		list.Query = *update.Query
+	}
+	if update.Filter != nil {
+		list.Filter = *update.Filter
+	}
+	if update.SortBy != nil {
+		list.SortBy = *update.SortBy
+	}
+	if update.SortOrder != nil {
+		list.SortOrder = *update.SortOrder
+	}
+	if update.IsPinned != nil {
+		list.IsPinned = *update.IsPinned
+	}
+	if err := validateSmartList(list); err != nil {
+		return nil, err
+	}
+
+	filterJSON, err := marshalListFilter(list.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE smart_lists
+		SET name = $1, query = $2, filter = $3, sort_by = $4, sort_order = $5, is_pinned = $6
+		WHERE id = $7 AND user_id = $8
+		RETURNING updated_at
+	`
+	err = s.db.QueryRow(query, list.Name, list.Query, filterJSON, list.SortBy, list.SortOrder,
+		list.IsPinned, list.ID, list.UserID,
+	).Scan(&list.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update smart list: %w", err)
+	}
+
+	return list, nil
+}
+
+// DeleteSmartList deletes a smart list
+func (s *SmartListService) DeleteSmartList(userID, listID string) error {
+	list, err := s.GetSmartList(userID, listID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec("DELETE FROM smart_lists WHERE id = $1 AND user_id = $2", list.ID, list.UserID); err != nil {
+		return fmt.Errorf("failed to delete smart list: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id":       userID,
+		"smart_list_id": listID,
+	}).Info("Smart list deleted successfully")
+
+	return nil
+}
+
+// TogglePin toggles the pinned status of a smart list
+func (s *SmartListService) TogglePin(userID, listID string) error {
+	list, err := s.GetSmartList(userID, listID)
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE smart_lists SET is_pinned = NOT is_pinned WHERE id = $1 AND user_id = $2"
+	if _, err := s.db.Exec(query, list.ID, list.UserID); err != nil {
+		return fmt.Errorf("failed to toggle pinned status: %w", err)
+	}
+	return nil
+}
+
+// ReorderSmartLists sets the position of each list to its index in ids.
+// Lists not mentioned keep their relative order after the listed ones.
+func (s *SmartListService) ReorderSmartLists(userID string, ids []uuid.UUID) error {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Move every list behind the explicitly ordered ones first
+	if _, err := tx.Exec("UPDATE smart_lists SET position = position + $1 WHERE user_id = $2", len(ids), userUUID); err != nil {
+		return fmt.Errorf("failed to reorder smart lists: %w", err)
+	}
+
+	for position, id := range ids {
+		result, err := tx.Exec("UPDATE smart_lists SET position = $1 WHERE id = $2 AND user_id = $3", position, id, userUUID)
+		if err != nil {
+			return fmt.Errorf("failed to reorder smart lists: %w", err)
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+			return ErrSmartListNotFound
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit smart list order: %w", err)
+	}
+	return nil
+}
+
+// ResolveFilter returns the article filter of a smart list, keeping the
+// pagination of the request filter
+func (s *SmartListService) ResolveFilter(userID, listID string, request *models.ArticleFilter) (*models.ArticleFilter, error) {
+	list, err := s.GetSmartList(userID, listID)
+	if err != nil {
+		return nil, err
+	}
+	return list.ApplyTo(request), nil
+}
+
+// GetSmartListArticles retrieves the articles currently matching a smart list
+func (s *SmartListService) GetSmartListArticles(userID, listID string, request *models.ArticleFilter) ([]*models.Article, error) {
+	filter, err := s.ResolveFilter(userID, listID, request)
+	if err != nil {
+		return nil, err
+	}
+	return s.articleService.FindArticles(filter)
+}
+
+// validateSmartList checks the name, search mode and sort of a smart list
+func validateSmartList(list *models.SmartList) error {
+	if list.Name == "" {
+		return fmt.Errorf("smart list name is required")
+	}
+
+	filter := list.ApplyTo(nil)
+	if filter.SearchMode != "" && !models.IsValidSearchMode(filter.SearchMode) {
+		return fmt.Errorf("invalid search mode: %s", filter.SearchMode)
+	}
+	return filter.ValidateSort()
+}
+
+// marshalListFilter serializes the stored part of a smart list filter.
+// Ownership, query, sort and pagination are kept in their own columns.
+func marshalListFilter(filter models.ArticleFilter) (string, error) {
+	filter.UserID = uuid.Nil
+	filter.Search = ""
+	filter.SortBy = ""
+	filter.SortOrder = ""
+	filter.Limit = 0
+	filter.Offset = 0
+
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize smart list filter: %w", err)
+	}
+	return string(filterJSON), nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSmartList scans a smart list selected with smartListColumns
+func scanSmartList(row rowScanner) (*models.SmartList, error) {
+	var list models.SmartList
+	var filterJSON string
+
+	err := row.Scan(&list.ID, &list.UserID, &list.Name, &list.Query, &filterJSON, &list.SortBy,
+		&list.SortOrder, &list.Position, &list.IsPinned, &list.CreatedAt, &list.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(filterJSON), &list.Filter); err != nil {
+		return nil, fmt.Errorf("failed to parse smart list filter: %w", err)
+	}
+
+	return &list, nil
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/models"
+)
+
+// GetStorageStats returns a user's storage counters. They are maintained
+// by the update_storage_stats_content trigger, so this is a single row read
+// however many articles the user has.
+func (s *ArticleService) GetStorageStats(userID string) (*models.StorageStats, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	var stats models.StorageStats
+	err = s.db.QueryRow(`
+		SELECT articles, encrypted_articles, logical_size, physical_size, updated_at
+		FROM storage_stats
+		WHERE user_id = $1
+	`, userUUID).Scan(&stats.Articles, &stats.EncryptedArticles, &stats.LogicalSize, &stats.PhysicalSize, &stats.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return &stats, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage stats: %w", err)
+	}
+
+	return &stats, nil
+}
+
+// RecomputeStorageStats rebuilds every user's storage counters and
+// storage_used from the articles table, correcting drift from manual
+// changes or missed updates. It returns the number of users whose counters
+// were wrong.
+func (s *ArticleService) RecomputeStorageStats(ctx context.Context) (int, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id FROM users UNION SELECT user_id FROM storage_stats")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	userIDs := []uuid.UUID{}
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read users: %w", err)
+	}
+
+	corrected := 0
+	for _, userID := range userIDs {
+		if err := ctx.Err(); err != nil {
+			return corrected, err
+		}
+
+		drifted, err := s.recomputeUserStorageStats(userID)
+		if err != nil {
+			return corrected, err
+		}
+		if drifted {
+			corrected++
+		}
+	}
+
+	if corrected > 0 {
+		s.logger.WithFields(logrus.Fields{
+			"users": corrected,
+		}).Warn("Corrected drifted storage stats")
+	}
+
+	return corrected, nil
+}
+
+// recomputeUserStorageStats rebuilds one user's counters. The users and
+// storage_stats rows are locked in the order the article triggers lock
+// them, so article changes made meanwhile wait and are then applied on top
+// of the new totals.
+func (s *ArticleService) recomputeUserStorageStats(userID uuid.UUID) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var storageUsed int64
+	err = tx.QueryRow("SELECT coalesce(storage_used, 0) FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&storageUsed)
+	if err == sql.ErrNoRows {
+		// Counters of deleted users are dropped
+		if _, err := tx.Exec("DELETE FROM storage_stats WHERE user_id = $1", userID); err != nil {
+			return false, fmt.Errorf("failed to delete storage stats: %w", err)
+		}
+		return false, tx.Commit()
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	if _, err := tx.Exec("INSERT INTO storage_stats (user_id) VALUES ($1) ON CONFLICT DO NOTHING", userID); err != nil {
+		return false, fmt.Errorf("failed to create storage stats: %w", err)
+	}
+
+	var current models.StorageStats
+	err = tx.QueryRow(`
+		SELECT articles, encrypted_articles, logical_size, physical_size
+		FROM storage_stats
+		WHERE user_id = $1
+		FOR UPDATE
+	`, userID).Scan(&current.Articles, &current.EncryptedArticles, &current.LogicalSize, &current.PhysicalSize)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock storage stats: %w", err)
+	}
+
+	var actual models.StorageStats
+	var totalSize int64
+	err = tx.QueryRow(`
+		SELECT
+			COUNT(*) FILTER (WHERE local_path IS NOT NULL),
+			COUNT(*) FILTER (WHERE local_path IS NOT NULL AND is_encrypted = true),
+			COALESCE(SUM(storage_size) FILTER (WHERE local_path IS NOT NULL), 0),
+			COALESCE(SUM(stored_size) FILTER (WHERE local_path IS NOT NULL), 0),
+			COALESCE(SUM(storage_size + assets_size), 0)
+		FROM articles
+		WHERE user_id = $1
+	`, userID).Scan(&actual.Articles, &actual.EncryptedArticles, &actual.LogicalSize, &actual.PhysicalSize, &totalSize)
+	if err != nil {
+		return false, fmt.Errorf("failed to compute storage stats: %w", err)
+	}
+
+	if actual == current && totalSize == storageUsed {
+		return false, tx.Commit()
+	}
+
+	_, err = tx.Exec(`
+		UPDATE storage_stats
+		SET articles = $1, encrypted_articles = $2, logical_size = $3, physical_size = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $5
+	`, actual.Articles, actual.EncryptedArticles, actual.LogicalSize, actual.PhysicalSize, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update storage stats: %w", err)
+	}
+
+	if _, err := tx.Exec("UPDATE users SET storage_used = $1 WHERE id = $2", totalSize, userID); err != nil {
+		return false, fmt.Errorf("failed to update storage usage: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit storage stats: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id":  userID.String(),
+		"expected": actual,
+		"recorded": current,
+	}).Debug("Recomputed storage stats")
+
+	return true, nil
+}
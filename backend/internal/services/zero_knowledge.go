@@ -0,0 +1,78 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/readitlater/backend/internal/models"
+	"github.com/readitlater/backend/internal/storage"
+)
+
+// ErrInvalidEncryptedContent is returned when content uploaded by a
+// zero-knowledge client is malformed or comes with plaintext it should
+// have encrypted
+var ErrInvalidEncryptedContent = errors.New("invalid encrypted content")
+
+// clientEncryptedContent builds the stored content of an article a
+// zero-knowledge client encrypted itself. The server only checks the
+// structure of the ciphertext; it never sees the key to open it.
+func clientEncryptedContent(userID string, articleID uuid.UUID, create *models.ArticleCreate, now time.Time) (*storage.ArticleContent, error) {
+	encrypted := create.Encrypted
+	switch {
+	case create.UserKey != "":
+		return nil, fmt.Errorf("%w: send either a user key or encrypted content", ErrInvalidEncryptedContent)
+	case create.Content != "" || create.Summary != "":
+		return nil, fmt.Errorf("%w: plaintext content sent with encrypted content", ErrInvalidEncryptedContent)
+	case create.FullPrivacy && (create.Title != "" || create.URL != "" || create.Description != "" ||
+		len(create.Tags) > 0 || create.Category != "" || len(create.Metadata) > 0):
+		return nil, fmt.Errorf("%w: plaintext fields sent with a full-privacy article", ErrInvalidEncryptedContent)
+	case !create.FullPrivacy && (encrypted.Title != "" || encrypted.URL != "" || encrypted.Description != "" || encrypted.PrivateFields != ""):
+		return nil, fmt.Errorf("%w: encrypted fields are only stored for full-privacy articles", ErrInvalidEncryptedContent)
+	}
+
+	content := &storage.ArticleContent{
+		ID:          articleID.String(),
+		UserID:      userID,
+		Title:       create.Title,
+		URL:         create.URL,
+		Content:     encrypted.Content,
+		Summary:     encrypted.Summary,
+		Tags:        create.Tags,
+		Metadata:    create.Metadata,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		IsEncrypted: true,
+		WrappedKey:  encrypted.WrappedKey,
+	}
+	if create.FullPrivacy {
+		content.Title = encrypted.Title
+		content.URL = encrypted.URL
+		content.Description = encrypted.Description
+		content.PrivateFields = encrypted.PrivateFields
+		content.FullPrivacy = true
+	}
+
+	if err := storage.ValidateEncryptedContent(content); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncryptedContent, err)
+	}
+	return content, nil
+}
+
+// encryptedArticleContent returns the ciphertext of a zero-knowledge
+// article for the client to decrypt
+func encryptedArticleContent(content *storage.ArticleContent) *models.EncryptedContent {
+	encrypted := &models.EncryptedContent{
+		WrappedKey: content.WrappedKey,
+		Content:    content.Content,
+		Summary:    content.Summary,
+	}
+	if content.FullPrivacy {
+		encrypted.Title = content.Title
+		encrypted.URL = content.URL
+		encrypted.Description = content.Description
+		encrypted.PrivateFields = content.PrivateFields
+	}
+	return encrypted
+}
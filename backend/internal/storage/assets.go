@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/encryption"
+)
+
+// ErrNoDataKey is returned when an article's content has no wrapped data
+// key to encrypt assets under. Rotating the user key gives it one.
+var ErrNoDataKey = errors.New("article content has no data key")
+
+// WriteEncryptedAsset encrypts an asset of an encrypted article, such as a
+// PDF or an image, from r into storage. size is the number of bytes r will
+// produce, or -1 if unknown. Assets are stored as encryption streams under
+// the article's data key, so they never sit whole in memory and rotating
+// the user key only rewraps the data key.
+func (s *Service) WriteEncryptedAsset(userID, articleID, name string, r io.Reader, size int64, userKey string) (*ObjectInfo, error) {
+	key, dataKey, err := s.assetDataKey(userID, articleID, name, userKey)
+	if err != nil {
+		return nil, err
+	}
+
+	streamSize := int64(-1)
+	if size >= 0 {
+		if streamSize, err = s.encryption.StreamSize(size); err != nil {
+			return nil, err
+		}
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		w, err := s.encryption.EncryptStreamWithKey(pw, dataKey, assetAAD(userID, articleID, name))
+		if err == nil {
+			_, err = io.Copy(w, r)
+		}
+		if err == nil {
+			err = w.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	err = s.backend.Put(key, pr, streamSize)
+	pr.CloseWithError(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write asset: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"article_id": articleID,
+		"key":        key,
+	}).Info("Asset saved successfully")
+
+	return s.backend.Stat(key)
+}
+
+// WriteAsset stores an asset as is from r, such as one of an unencrypted
+// article or one restored from a backup. size is the number of bytes r
+// will produce, or -1 if unknown. Assets of encrypted articles must
+// already be encrypted.
+func (s *Service) WriteAsset(userID, articleID, name string, r io.Reader, size int64) (*ObjectInfo, error) {
+	key, err := assetKey(userID, articleID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.backend.Put(key, r, size); err != nil {
+		return nil, fmt.Errorf("failed to write asset: %w", err)
+	}
+	return s.backend.Stat(key)
+}
+
+// StatAsset returns the stored key and size of an asset, or ErrNotFound
+func (s *Service) StatAsset(userID, articleID, name string) (*ObjectInfo, error) {
+	key, err := assetKey(userID, articleID, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.backend.Stat(key)
+}
+
+// EncryptedAssetSize returns the stored size of an asset of size bytes
+// written by WriteEncryptedAsset
+func (s *Service) EncryptedAssetSize(size int64) (int64, error) {
+	return s.encryption.StreamSize(size)
+}
+
+// AssetReaderAt opens an asset stored as is for range reads, with range
+// requests on backends that support them
+func (s *Service) AssetReaderAt(userID, articleID, name string) (*io.SectionReader, error) {
+	info, err := s.StatAsset(userID, articleID, name)
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(&backendReaderAt{backend: s.backend, key: info.Key}, 0, info.Size), nil
+}
+
+// EncryptedAssetReaderAt opens an encrypted asset for range reads. userKey
+// is the server key for assets of server-encrypted articles. Only the
+// chunks a read covers are fetched, with range requests on backends that
+// support them, and every chunk is authenticated before it is returned.
+func (s *Service) EncryptedAssetReaderAt(userID, articleID, name, userKey string) (*encryption.StreamReaderAt, error) {
+	key, dataKey, err := s.assetDataKey(userID, articleID, name, userKey)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.backend.Stat(key)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.encryption.NewStreamReaderAt(&backendReaderAt{backend: s.backend, key: key}, info.Size, dataKey, assetAAD(userID, articleID, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open asset: %w", err)
+	}
+	return r, nil
+}
+
+// ListAssets returns the names of an article's assets
+func (s *Service) ListAssets(userID, articleID string) ([]string, error) {
+	keys, err := ArticleKeysFor(userID, articleID)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := s.backend.List(keys.Assets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list assets: %w", err)
+	}
+	names := []string{}
+	for _, object := range objects {
+		names = append(names, strings.TrimPrefix(object.Key, keys.Assets))
+	}
+	return names, nil
+}
+
+// AssetEncrypted reports whether an asset of an encrypted article is
+// stored encrypted under the article's data key, rather than as it was
+// written before the article was encrypted. The first chunk is
+// authenticated, so a plain asset that happens to start like a stream is
+// not mistaken for one.
+func (s *Service) AssetEncrypted(userID, articleID, name, userKey string) (bool, error) {
+	r, err := s.EncryptedAssetReaderAt(userID, articleID, name, userKey)
+	if errors.Is(err, encryption.ErrNotStream) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if r.Size() == 0 {
+		return true, nil
+	}
+
+	_, err = r.ReadAt(make([]byte, 1), 0)
+	switch {
+	case err == nil, errors.Is(err, io.EOF):
+		return true, nil
+	case errors.Is(err, encryption.ErrDecryptionFailed):
+		return false, nil
+	}
+	return false, err
+}
+
+// EncryptAsset encrypts an asset stored as is in place, under the data key
+// of its article, and returns the stored asset. The asset is streamed, so
+// it never sits whole in memory.
+func (s *Service) EncryptAsset(userID, articleID, name, userKey string) (*ObjectInfo, error) {
+	key, err := assetKey(userID, articleID, name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.backend.Stat(key)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := s.backend.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read asset: %w", err)
+	}
+	defer r.Close()
+
+	return s.WriteEncryptedAsset(userID, articleID, name, r, info.Size, userKey)
+}
+
+// assetDataKey returns the key of an asset and the data key of its
+// article, unwrapped from the article's content file
+func (s *Service) assetDataKey(userID, articleID, name, userKey string) (string, []byte, error) {
+	key, err := assetKey(userID, articleID, name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	content, err := s.GetContent(userID, articleID)
+	if err != nil {
+		return "", nil, err
+	}
+	var articleContent ArticleContent
+	if err := json.Unmarshal([]byte(content), &articleContent); err != nil {
+		return "", nil, fmt.Errorf("failed to parse article content: %w", err)
+	}
+	if !articleContent.IsEncrypted && !articleContent.ServerEncrypted {
+		return "", nil, ErrNotEncrypted
+	}
+	if articleContent.WrappedKey == "" {
+		return "", nil, ErrNoDataKey
+	}
+
+	dataKey, err := s.unwrapDataKey(userID, articleID, &articleContent, userKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return key, dataKey, nil
+}
+
+// assetKey returns the key of an asset of an article
+func assetKey(userID, articleID, name string) (string, error) {
+	keys, err := ArticleKeysFor(userID, articleID)
+	if err != nil {
+		return "", err
+	}
+	return keys.AssetKey(name)
+}
+
+// assetAAD binds an encrypted asset to its article and name
+func assetAAD(userID, articleID, name string) []byte {
+	return fieldAAD(userID, articleID, "asset/"+name)
+}
+
+// backendReaderAt reads parts of an object, with range requests when the
+// backend supports them and by skipping ahead otherwise
+type backendReaderAt struct {
+	backend Backend
+	key     string
+}
+
+// ReadAt reads len(p) bytes of the object starting at off
+func (b *backendReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	var reader io.ReadCloser
+	var err error
+	if ranger, ok := b.backend.(RangeGetter); ok {
+		reader, err = ranger.GetRange(b.key, off, int64(len(p)))
+	} else if reader, err = b.backend.Get(b.key); err == nil {
+		if _, err = io.CopyN(io.Discard, reader, off); err != nil {
+			reader.Close()
+			return 0, err
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	n, err := io.ReadFull(reader, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
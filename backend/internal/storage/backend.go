@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/readitlater/backend/internal/config"
+)
+
+// Supported storage backends
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// ErrInsufficientStorage is returned when a write fails because the
+// underlying storage is full
+var ErrInsufficientStorage = errors.New("insufficient storage")
+
+// Backend stores opaque objects under slash-separated keys such as
+// "users/<user id>/ab/cd/<article id>/content.json". Implementations must
+// be safe for concurrent use.
+type Backend interface {
+	// Name returns the backend name
+	Name() string
+
+	// Put stores an object, replacing any existing one. size is the number
+	// of bytes r will produce, or -1 if unknown.
+	Put(key string, r io.Reader, size int64) error
+
+	// Get opens an object for reading. The caller must close the reader.
+	Get(key string) (io.ReadCloser, error)
+
+	// Delete removes an object. Deleting a missing object is not an error.
+	Delete(key string) error
+
+	// List returns the objects whose keys start with prefix, sorted by key
+	List(prefix string) ([]ObjectInfo, error)
+
+	// Stat returns information about an object
+	Stat(key string) (*ObjectInfo, error)
+}
+
+// TempCleaner is implemented by backends that leave temporary files behind
+// when a write is interrupted by a crash
+type TempCleaner interface {
+	// CleanupTempFiles removes temporary files older than maxAge and
+	// returns how many were removed
+	CleanupTempFiles(maxAge time.Duration) (int, error)
+}
+
+// PermissionChecker is implemented by backends whose stored objects could
+// be exposed by lax permissions on the host
+type PermissionChecker interface {
+	// CheckPermissions fails if other users can access stored objects
+	CheckPermissions() error
+}
+
+// RangeGetter is implemented by backends that can read part of an object
+// without fetching all of it
+type RangeGetter interface {
+	// GetRange opens length bytes of an object starting at offset. The
+	// reader ends early when the object does. The caller must close it.
+	GetRange(key string, offset, length int64) (io.ReadCloser, error)
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// NewBackend creates the storage backend configured by STORAGE_BACKEND
+func NewBackend(cfg *config.Config) (Backend, error) {
+	switch cfg.StorageBackend {
+	case BackendLocal, "":
+		return NewLocalBackend(cfg.LocalStoragePath)
+	case BackendS3:
+		return NewS3Backend(S3Config{
+			Endpoint:  cfg.CloudStorageEndpoint,
+			Bucket:    cfg.CloudStorageBucket,
+			Region:    cfg.CloudStorageRegion,
+			AccessKey: cfg.CloudAccessKey,
+			SecretKey: cfg.CloudSecretKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.StorageBackend)
+	}
+}
+
+// validateKey rejects keys that are empty, absolute, contain empty, "." or
+// ".." segments, or contain backslashes or NUL bytes, which some platforms
+// treat as separators or terminators
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.ContainsAny(key, "\\\x00") {
+		return fmt.Errorf("invalid object key: %q", key)
+	}
+
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid object key: %q", key)
+		}
+	}
+	return nil
+}
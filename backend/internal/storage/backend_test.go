@@ -0,0 +1,351 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testBackend runs the behaviour every Backend implementation must share
+func testBackend(t *testing.T, backend Backend) {
+	put := func(t *testing.T, key, data string) {
+		if err := backend.Put(key, strings.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("Failed to put %s: %v", key, err)
+		}
+	}
+
+	get := func(t *testing.T, key string) string {
+		reader, err := backend.Get(key)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", key, err)
+		}
+		defer reader.Close()
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", key, err)
+		}
+		return string(data)
+	}
+
+	t.Run("PutGet", func(t *testing.T) {
+		put(t, "users/a/1.json", `{"title":"one"}`)
+		if got := get(t, "users/a/1.json"); got != `{"title":"one"}` {
+			t.Errorf("Expected stored content, got %q", got)
+		}
+	})
+
+	t.Run("PutReplaces", func(t *testing.T) {
+		put(t, "users/a/1.json", `{"title":"replaced"}`)
+		if got := get(t, "users/a/1.json"); got != `{"title":"replaced"}` {
+			t.Errorf("Expected replaced content, got %q", got)
+		}
+	})
+
+	t.Run("PutUnknownSize", func(t *testing.T) {
+		if err := backend.Put("users/a/2.json", bytes.NewBufferString("streamed"), -1); err != nil {
+			t.Fatalf("Failed to put with unknown size: %v", err)
+		}
+		if got := get(t, "users/a/2.json"); got != "streamed" {
+			t.Errorf("Expected streamed content, got %q", got)
+		}
+	})
+
+	t.Run("PutEmpty", func(t *testing.T) {
+		put(t, "users/a/empty", "")
+		if got := get(t, "users/a/empty"); got != "" {
+			t.Errorf("Expected empty content, got %q", got)
+		}
+	})
+
+	t.Run("FailedPutKeepsOldContent", func(t *testing.T) {
+		failing := io.MultiReader(strings.NewReader("partial"), &failingReader{})
+		if err := backend.Put("users/a/2.json", failing, 100); err == nil {
+			t.Fatal("Put should fail when the reader fails")
+		}
+		if got := get(t, "users/a/2.json"); got != "streamed" {
+			t.Errorf("Failed put should leave the old content, got %q", got)
+		}
+	})
+
+	t.Run("Stat", func(t *testing.T) {
+		info, err := backend.Stat("users/a/2.json")
+		if err != nil {
+			t.Fatalf("Failed to stat: %v", err)
+		}
+		if info.Key != "users/a/2.json" || info.Size != int64(len("streamed")) {
+			t.Errorf("Unexpected object info: %+v", info)
+		}
+		if info.ModTime.IsZero() {
+			t.Error("Modification time should be set")
+		}
+	})
+
+	t.Run("MissingObject", func(t *testing.T) {
+		if _, err := backend.Get("users/a/missing.json"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get should return ErrNotFound, got %v", err)
+		}
+		if _, err := backend.Stat("users/a/missing.json"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat should return ErrNotFound, got %v", err)
+		}
+		if err := backend.Delete("users/a/missing.json"); err != nil {
+			t.Errorf("Deleting a missing object should succeed, got %v", err)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		put(t, "users/b/1.json", "b1")
+		put(t, "users/ab/1.json", "ab1")
+		put(t, "users/a/nested/3.json", "nested")
+
+		objects, err := backend.List("users/a/")
+		if err != nil {
+			t.Fatalf("Failed to list: %v", err)
+		}
+
+		keys := []string{}
+		for _, object := range objects {
+			keys = append(keys, object.Key)
+		}
+		expected := []string{"users/a/1.json", "users/a/2.json", "users/a/empty", "users/a/nested/3.json"}
+		if strings.Join(keys, ",") != strings.Join(expected, ",") {
+			t.Errorf("Expected %v, got %v", expected, keys)
+		}
+
+		objects, err = backend.List("users/missing/")
+		if err != nil || len(objects) != 0 {
+			t.Errorf("Listing an empty prefix should return nothing, got %v (%v)", objects, err)
+		}
+
+		objects, err = backend.List("users/a")
+		if err != nil || len(objects) != 5 {
+			t.Errorf("Partial prefixes should match across directories, got %d objects (%v)", len(objects), err)
+		}
+	})
+
+	t.Run("GetRange", func(t *testing.T) {
+		ranger, ok := backend.(RangeGetter)
+		if !ok {
+			t.Skip("Backend does not support range reads")
+		}
+		put(t, "users/a/range.bin", "0123456789")
+
+		for _, tc := range []struct {
+			offset, length int64
+			expected       string
+		}{{0, 3, "012"}, {4, 2, "45"}, {8, 5, "89"}, {12, 3, ""}} {
+			reader, err := ranger.GetRange("users/a/range.bin", tc.offset, tc.length)
+			if err != nil {
+				t.Fatalf("Failed to get range %d+%d: %v", tc.offset, tc.length, err)
+			}
+			data, _ := io.ReadAll(reader)
+			reader.Close()
+			if string(data) != tc.expected {
+				t.Errorf("Expected %q for range %d+%d, got %q", tc.expected, tc.offset, tc.length, data)
+			}
+		}
+
+		if _, err := ranger.GetRange("users/a/missing.bin", 0, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := backend.Delete("users/a/1.json"); err != nil {
+			t.Fatalf("Failed to delete: %v", err)
+		}
+		if _, err := backend.Stat("users/a/1.json"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Deleted object should be gone, got %v", err)
+		}
+	})
+
+	t.Run("InvalidKeys", func(t *testing.T) {
+		for _, key := range []string{"", "/etc/passwd", "../outside", "users/../../outside", "users//a", "users/./a"} {
+			if err := backend.Put(key, strings.NewReader("x"), 1); err == nil {
+				t.Errorf("Put should reject key %q", key)
+			}
+			if _, err := backend.Get(key); err == nil {
+				t.Errorf("Get should reject key %q", key)
+			}
+		}
+	})
+}
+
+func TestLocalBackend(t *testing.T) {
+	root := t.TempDir()
+	backend, err := NewLocalBackend(root)
+	if err != nil {
+		t.Fatalf("Failed to create local backend: %v", err)
+	}
+
+	testBackend(t, backend)
+
+	t.Run("NoTempFilesLeft", func(t *testing.T) {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && strings.HasPrefix(d.Name(), tempFilePrefix) {
+				t.Errorf("Temporary file left behind: %s", path)
+			}
+			return err
+		})
+	})
+
+	t.Run("CleanupTempFiles", func(t *testing.T) {
+		dir := filepath.Join(root, "users", "a")
+		stale := filepath.Join(dir, tempFilePrefix+"stale")
+		fresh := filepath.Join(dir, tempFilePrefix+"fresh")
+		for _, path := range []string{stale, fresh} {
+			if err := os.WriteFile(path, []byte("partial"), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+		}
+		old := time.Now().Add(-2 * time.Hour)
+		if err := os.Chtimes(stale, old, old); err != nil {
+			t.Fatalf("Failed to age temp file: %v", err)
+		}
+
+		removed, err := backend.CleanupTempFiles(time.Hour)
+		if err != nil {
+			t.Fatalf("Failed to clean up temp files: %v", err)
+		}
+		if removed != 1 {
+			t.Errorf("Expected 1 file removed, got %d", removed)
+		}
+		if _, err := os.Stat(stale); !os.IsNotExist(err) {
+			t.Error("Stale temp file should be removed")
+		}
+		if _, err := os.Stat(fresh); err != nil {
+			t.Error("Temp file of a write in progress should be kept")
+		}
+		os.Remove(fresh)
+	})
+
+	t.Run("CheckPermissions", func(t *testing.T) {
+		if err := os.Chmod(root, 0700); err != nil {
+			t.Fatalf("Failed to change mode: %v", err)
+		}
+		if err := backend.CheckPermissions(); err != nil {
+			t.Errorf("Private directory should pass: %v", err)
+		}
+
+		if err := os.Chmod(root, 0755); err != nil {
+			t.Fatalf("Failed to change mode: %v", err)
+		}
+		if err := backend.CheckPermissions(); err == nil {
+			t.Error("Directory readable by other users should fail")
+		}
+	})
+
+	t.Run("PrivateFileModes", func(t *testing.T) {
+		if err := backend.Put("users/p/private.json", strings.NewReader("{}"), 2); err != nil {
+			t.Fatalf("Failed to put: %v", err)
+		}
+		for _, path := range []string{filepath.Join(root, "users", "p"), filepath.Join(root, "users", "p", "private.json")} {
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("Failed to stat %s: %v", path, err)
+			}
+			if info.Mode().Perm()&0077 != 0 {
+				t.Errorf("%s is accessible by other users: %v", path, info.Mode().Perm())
+			}
+		}
+	})
+
+	t.Run("SymlinksOutsideRoot", func(t *testing.T) {
+		outside := t.TempDir()
+		if err := os.WriteFile(filepath.Join(outside, "secret.json"), []byte("secret"), 0600); err != nil {
+			t.Fatalf("Failed to write outside file: %v", err)
+		}
+		links := map[string]string{
+			filepath.Join(root, "users", "escape"):        outside,
+			filepath.Join(root, "users", "file.json"):     filepath.Join(outside, "secret.json"),
+			filepath.Join(root, "users", "dangling.json"): filepath.Join(outside, "missing.json"),
+		}
+		for link, target := range links {
+			if err := os.Symlink(target, link); err != nil {
+				t.Fatalf("Failed to create symlink: %v", err)
+			}
+			defer os.Remove(link)
+		}
+
+		for _, key := range []string{"users/escape/secret.json", "users/escape/new/x.json", "users/file.json", "users/dangling.json"} {
+			if _, err := backend.Get(key); err == nil || errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected %s to be rejected, got %v", key, err)
+			}
+			if err := backend.Put(key, strings.NewReader("x"), 1); err == nil {
+				t.Errorf("Expected writing %s to be rejected", key)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(outside, "new")); !os.IsNotExist(err) {
+			t.Error("Expected nothing to be created outside the root")
+		}
+
+		// Links that stay under the root are followed
+		if err := backend.Put("users/inside/a.json", strings.NewReader("a"), 1); err != nil {
+			t.Fatalf("Failed to put: %v", err)
+		}
+		link := filepath.Join(root, "users", "alias")
+		if err := os.Symlink(filepath.Join(root, "users", "inside"), link); err != nil {
+			t.Fatalf("Failed to create symlink: %v", err)
+		}
+		defer os.Remove(link)
+		if _, err := backend.Get("users/alias/a.json"); err != nil {
+			t.Errorf("Expected a symlink within the root to be followed: %v", err)
+		}
+	})
+
+	t.Run("FullDiskIsInsufficientStorage", func(t *testing.T) {
+		err := spaceError(&fs.PathError{Op: "write", Path: "x", Err: syscall.ENOSPC})
+		if !errors.Is(err, ErrInsufficientStorage) {
+			t.Errorf("Expected ErrInsufficientStorage, got %v", err)
+		}
+		if err := spaceError(errors.New("other")); errors.Is(err, ErrInsufficientStorage) {
+			t.Error("Other errors should not be ErrInsufficientStorage")
+		}
+	})
+}
+
+// FuzzLocalBackendPath checks that no key resolves to a path outside the
+// storage root
+func FuzzLocalBackendPath(f *testing.F) {
+	for _, key := range []string{
+		"users/a/1.json", "../etc/passwd", "users/../../x", "/abs", "users/./a",
+		"users\\..\\..\\x", "users/a\x00/b", "..", "a//b", "users/a/..",
+	} {
+		f.Add(key)
+	}
+
+	root := f.TempDir()
+	backend, err := NewLocalBackend(root)
+	if err != nil {
+		f.Fatalf("Failed to create local backend: %v", err)
+	}
+
+	f.Fuzz(func(t *testing.T, key string) {
+		path, err := backend.path(key)
+		if err != nil {
+			return
+		}
+
+		rel, err := filepath.Rel(backend.root, path)
+		if err != nil || rel == "." || !filepath.IsLocal(rel) {
+			t.Fatalf("Key %q resolves outside the root: %s", key, path)
+		}
+		if strings.ContainsAny(key, "\\\x00") {
+			t.Fatalf("Key %q with a backslash or NUL byte accepted", key)
+		}
+	})
+}
+
+// failingReader fails every read
+type failingReader struct{}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
@@ -0,0 +1,542 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/readitlater/backend/internal/config"
+	"github.com/readitlater/backend/internal/encryption"
+)
+
+// Backup key derivation labels
+const (
+	backupEncryptionInfo = "readitlater backup encryption v1"
+	backupHashInfo       = "readitlater backup hash v1"
+)
+
+// snapshotIDFormat sorts snapshots chronologically by key
+const snapshotIDFormat = "20060102T150405.000000000Z"
+
+// ErrSnapshotNotFound is returned when a backup snapshot does not exist
+var ErrSnapshotNotFound = errors.New("backup snapshot not found")
+
+// Backup makes incremental, encrypted snapshots of users' content in a
+// remote backend. Remote objects are laid out as
+//
+//	backups/<user id>/blobs/<content hash>        encrypted object content
+//	backups/<user id>/snapshots/<snapshot id>     encrypted snapshot manifest
+//
+// Everything is sealed before upload in an encryption envelope under a
+// per-user key derived from the server encryption key, with the configured
+// content encryption algorithm. Content hashes are keyed HMACs, so the remote only
+// learns which objects are identical, not what they contain. A blob is
+// uploaded once and shared by every snapshot that references it. After the
+// server encryption key is rotated, backups made under previous keys stay
+// readable as long as those keys are configured. Blobs are only shared
+// between snapshots made under the same key, so the first backup after a
+// rotation no longer depends on the previous key.
+type Backup struct {
+	source       Backend
+	remote       Backend
+	encryption   *encryption.Service
+	masterKey    []byte
+	previousKeys [][]byte
+	retention    int
+	now          func() time.Time
+}
+
+// backupKeys are the keys of a user's backups under one master key
+type backupKeys struct {
+	enc  []byte
+	hash []byte
+}
+
+// Snapshot is the manifest of one backup
+type Snapshot struct {
+	ID           string           `json:"id"`
+	UserID       string           `json:"user_id"`
+	CreatedAt    time.Time        `json:"created_at"`
+	Objects      []SnapshotObject `json:"objects,omitempty"`
+	ObjectCount  int              `json:"object_count"`
+	TotalSize    int64            `json:"total_size"`
+	UploadedSize int64            `json:"uploaded_size"`
+	Uploaded     int              `json:"uploaded"`
+	MasterKeyID  string           `json:"master_key_id,omitempty"` // master key every blob is encrypted under
+}
+
+// SnapshotObject is an object captured by a snapshot. Key is relative to
+// the user's storage prefix.
+type SnapshotObject struct {
+	Key     string    `json:"key"`
+	Blob    string    `json:"blob"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// NewBackupBackend creates the remote backend configured by
+// CLOUD_STORAGE_PROVIDER
+func NewBackupBackend(cfg *config.Config) (Backend, error) {
+	switch cfg.CloudStorageProvider {
+	case BackendLocal, "":
+		return NewLocalBackend(cfg.BackupPath)
+	case BackendS3:
+		return NewS3Backend(S3Config{
+			Endpoint:  cfg.CloudStorageEndpoint,
+			Bucket:    cfg.CloudStorageBucket,
+			Region:    cfg.CloudStorageRegion,
+			AccessKey: cfg.CloudAccessKey,
+			SecretKey: cfg.CloudSecretKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown cloud storage provider: %s", cfg.CloudStorageProvider)
+	}
+}
+
+// NewBackup creates a backup of source into remote that keeps the newest
+// retention snapshots per user
+func NewBackup(source, remote Backend, encService *encryption.Service, masterKey string, retention int) *Backup {
+	if retention < 1 {
+		retention = 1
+	}
+
+	return &Backup{
+		source:     source,
+		remote:     remote,
+		encryption: encService,
+		masterKey:  []byte(masterKey),
+		retention:  retention,
+		now:        time.Now,
+	}
+}
+
+// WithPreviousKeys sets master keys replaced by the current one, which
+// snapshots and blobs uploaded before the rotation are encrypted with
+func (b *Backup) WithPreviousKeys(keys []string) *Backup {
+	for _, key := range keys {
+		b.previousKeys = append(b.previousKeys, []byte(key))
+	}
+	return b
+}
+
+// Run takes a snapshot of a user's content, uploading only objects whose
+// content is not already in the remote, then applies the retention policy.
+// It returns the new snapshot and the IDs of snapshots removed by retention.
+// Cancelling ctx stops the backup without saving a snapshot.
+func (b *Backup) Run(ctx context.Context, userID string) (*Snapshot, []string, error) {
+	keys, err := b.userKeys(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	encKey, hashKey := keys[0].enc, keys[0].hash
+	masterKeyID, err := encryption.MasterKeyID(string(b.masterKey))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	objects, err := b.source.List(userPrefix(userID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list content: %w", err)
+	}
+
+	// Objects unchanged since the latest snapshot reuse its blob without
+	// being read again, unless it was made under a previous master key
+	previous := map[string]SnapshotObject{}
+	if latest, err := b.latestSnapshot(userID); err != nil {
+		return nil, nil, err
+	} else if latest != nil && latest.MasterKeyID == masterKeyID {
+		for _, object := range latest.Objects {
+			previous[object.Key] = object
+		}
+	}
+
+	blobs, err := b.remoteBlobs(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	snapshot := &Snapshot{
+		ID:          b.now().UTC().Format(snapshotIDFormat),
+		UserID:      userID,
+		CreatedAt:   b.now().UTC(),
+		Objects:     []SnapshotObject{},
+		MasterKeyID: masterKeyID,
+	}
+
+	for _, object := range objects {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		key := strings.TrimPrefix(object.Key, userPrefix(userID))
+
+		if prev, ok := previous[key]; ok && prev.Size == object.Size && prev.ModTime.Equal(object.ModTime) && blobs[prev.Blob] {
+			snapshot.add(prev)
+			continue
+		}
+
+		stored, err := readAll(b.source, object.Key)
+		if errors.Is(err, ErrNotFound) {
+			// Deleted while the backup was running
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Backups hold the article JSON itself so they never depend on
+		// shared blobs, which may be collected before a restore
+		data, err := DecodeContent(b.source, stored)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", key, err)
+		}
+
+		blob := contentHash(hashKey, data)
+		if !blobs[blob] {
+			sealed, err := b.encryption.SealWithKey(encKey, data, []byte(blob))
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to encrypt %s: %w", key, err)
+			}
+			if err := b.remote.Put(blobKey(userID, blob), bytes.NewReader(sealed), int64(len(sealed))); err != nil {
+				return nil, nil, fmt.Errorf("failed to upload %s: %w", key, err)
+			}
+			blobs[blob] = true
+			snapshot.Uploaded++
+			snapshot.UploadedSize += int64(len(data))
+		}
+
+		snapshot.add(SnapshotObject{Key: key, Blob: blob, Size: object.Size, ModTime: object.ModTime})
+	}
+
+	if err := b.saveSnapshot(encKey, snapshot); err != nil {
+		return nil, nil, err
+	}
+
+	pruned, err := b.Prune(userID)
+	if err != nil {
+		return snapshot, nil, err
+	}
+
+	return snapshot, pruned, nil
+}
+
+// Snapshots returns the manifests of a user's snapshots, newest first
+func (b *Backup) Snapshots(userID string) ([]*Snapshot, error) {
+	ids, err := b.snapshotIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []*Snapshot{}
+	for i := len(ids) - 1; i >= 0; i-- {
+		snapshot, err := b.Snapshot(userID, ids[i])
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// Snapshot loads and decrypts the manifest of a snapshot
+func (b *Backup) Snapshot(userID, snapshotID string) (*Snapshot, error) {
+	if _, err := time.Parse(snapshotIDFormat, snapshotID); err != nil {
+		return nil, ErrSnapshotNotFound
+	}
+
+	keys, err := b.userKeys(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := readAll(b.remote, snapshotKey(userID, snapshotID))
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := openBackup(keys, sealed, []byte(userID+"/"+snapshotID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt snapshot %s: %w", snapshotID, err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", snapshotID, err)
+	}
+	return &snapshot, nil
+}
+
+// RestoredObject is an article's content file or asset decrypted from a
+// snapshot
+type RestoredObject struct {
+	ArticleID string
+	Asset     string // asset name, empty for the content file
+	Data      []byte
+}
+
+// Restore decrypts the objects of a snapshot one at a time and passes them
+// to restore, which writes them back. Only articles' content files and
+// assets are restored, content files first so an article can be checked
+// before its assets. When articleID is set only that article's objects are
+// passed, and ErrNotFound is returned if the snapshot has none.
+func (b *Backup) Restore(userID, snapshotID, articleID string, restore func(*RestoredObject) error) error {
+	keys, err := b.userKeys(userID)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := b.Snapshot(userID, snapshotID)
+	if err != nil {
+		return err
+	}
+
+	var contents, assets []SnapshotObject
+	for _, object := range snapshot.Objects {
+		location, isContent := ParseContentKey(userPrefix(userID) + object.Key)
+		if !isContent || location.UserID != userID {
+			continue
+		}
+		if articleID != "" && location.ArticleID != articleID {
+			continue
+		}
+		if location.Asset {
+			assets = append(assets, object)
+		} else {
+			contents = append(contents, object)
+		}
+	}
+
+	for _, object := range append(contents, assets...) {
+		key := userPrefix(userID) + object.Key
+		location, _ := ParseContentKey(key)
+
+		sealed, err := readAll(b.remote, blobKey(userID, object.Blob))
+		if err != nil {
+			return fmt.Errorf("failed to download %s: %w", object.Key, err)
+		}
+
+		data, objectKeys, err := openBackup(keys, sealed, []byte(object.Blob))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", object.Key, err)
+		}
+		if contentHash(objectKeys.hash, data) != object.Blob {
+			return fmt.Errorf("backup of %s is corrupt", object.Key)
+		}
+
+		restored := &RestoredObject{ArticleID: location.ArticleID, Data: data}
+		if location.Asset {
+			articleKeys, err := ArticleKeysFor(userID, location.ArticleID)
+			if err != nil {
+				return err
+			}
+			restored.Asset = strings.TrimPrefix(key, articleKeys.Assets)
+		}
+		if err := restore(restored); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", object.Key, err)
+		}
+	}
+
+	if articleID != "" && len(contents)+len(assets) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Prune deletes snapshots beyond the retention limit and every blob no
+// longer referenced by a remaining snapshot. It returns the deleted
+// snapshot IDs.
+func (b *Backup) Prune(userID string) ([]string, error) {
+	ids, err := b.snapshotIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	pruned := []string{}
+	if len(ids) > b.retention {
+		pruned = ids[:len(ids)-b.retention]
+		ids = ids[len(ids)-b.retention:]
+	}
+
+	// Collect live blobs before deleting anything so a failure cannot
+	// remove blobs of a snapshot that is kept
+	live := map[string]bool{}
+	for _, id := range ids {
+		snapshot, err := b.Snapshot(userID, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range snapshot.Objects {
+			live[object.Blob] = true
+		}
+	}
+
+	for _, id := range pruned {
+		if err := b.remote.Delete(snapshotKey(userID, id)); err != nil {
+			return nil, fmt.Errorf("failed to delete snapshot %s: %w", id, err)
+		}
+	}
+
+	blobs, err := b.remoteBlobs(userID)
+	if err != nil {
+		return pruned, err
+	}
+	for blob := range blobs {
+		if !live[blob] {
+			if err := b.remote.Delete(blobKey(userID, blob)); err != nil {
+				return pruned, fmt.Errorf("failed to delete blob: %w", err)
+			}
+		}
+	}
+
+	return pruned, nil
+}
+
+// add appends an object to the snapshot and updates its totals
+func (s *Snapshot) add(object SnapshotObject) {
+	s.Objects = append(s.Objects, object)
+	s.ObjectCount++
+	s.TotalSize += object.Size
+}
+
+// userKeys validates a user ID and derives the user's backup encryption
+// and hashing keys under the current master key, followed by those under
+// previous master keys
+func (b *Backup) userKeys(userID string) ([]backupKeys, error) {
+	if err := validateID(userID); err != nil {
+		return nil, err
+	}
+
+	keys := []backupKeys{}
+	for _, masterKey := range append([][]byte{b.masterKey}, b.previousKeys...) {
+		encKey, err := encryption.DeriveSubkey(masterKey, []byte(userID), backupEncryptionInfo)
+		if err != nil {
+			return nil, err
+		}
+
+		hashKey, err := encryption.DeriveSubkey(masterKey, []byte(userID), backupHashInfo)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, backupKeys{enc: encKey, hash: hashKey})
+	}
+	return keys, nil
+}
+
+// openBackup decrypts a snapshot or blob with the first keys that open it
+// and returns those keys
+func openBackup(keys []backupKeys, sealed, aad []byte) ([]byte, backupKeys, error) {
+	var err error
+	for _, k := range keys {
+		var data []byte
+		if data, err = encryption.OpenWithKey(k.enc, sealed, aad); err == nil {
+			return data, k, nil
+		}
+	}
+	return nil, backupKeys{}, err
+}
+
+// saveSnapshot encrypts and uploads a snapshot manifest
+func (b *Backup) saveSnapshot(encKey []byte, snapshot *Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to serialize snapshot: %w", err)
+	}
+
+	sealed, err := b.encryption.SealWithKey(encKey, data, []byte(snapshot.UserID+"/"+snapshot.ID))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt snapshot: %w", err)
+	}
+
+	if err := b.remote.Put(snapshotKey(snapshot.UserID, snapshot.ID), bytes.NewReader(sealed), int64(len(sealed))); err != nil {
+		return fmt.Errorf("failed to upload snapshot: %w", err)
+	}
+	return nil
+}
+
+// latestSnapshot returns the newest snapshot of a user, or nil if none exist
+func (b *Backup) latestSnapshot(userID string) (*Snapshot, error) {
+	ids, err := b.snapshotIDs(userID)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	return b.Snapshot(userID, ids[len(ids)-1])
+}
+
+// snapshotIDs returns the IDs of a user's snapshots, oldest first
+func (b *Backup) snapshotIDs(userID string) ([]string, error) {
+	prefix := backupPrefix(userID) + "snapshots/"
+	objects, err := b.remote.List(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	ids := []string{}
+	for _, object := range objects {
+		ids = append(ids, strings.TrimPrefix(object.Key, prefix))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// remoteBlobs returns the set of blobs stored for a user
+func (b *Backup) remoteBlobs(userID string) (map[string]bool, error) {
+	prefix := backupPrefix(userID) + "blobs/"
+	objects, err := b.remote.List(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup blobs: %w", err)
+	}
+
+	blobs := map[string]bool{}
+	for _, object := range objects {
+		blobs[strings.TrimPrefix(object.Key, prefix)] = true
+	}
+	return blobs, nil
+}
+
+// readAll reads a whole object from a backend
+func readAll(backend Backend, key string) ([]byte, error) {
+	reader, err := backend.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// contentHash returns the keyed hash naming a blob
+func contentHash(hashKey, data []byte) string {
+	mac := hmac.New(sha256.New, hashKey)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// backupPrefix returns the remote key prefix of a user's backups
+func backupPrefix(userID string) string {
+	return "backups/" + userID + "/"
+}
+
+// blobKey returns the remote key of a blob
+func blobKey(userID, blob string) string {
+	return backupPrefix(userID) + "blobs/" + blob
+}
+
+// snapshotKey returns the remote key of a snapshot manifest
+func snapshotKey(userID, snapshotID string) string {
+	return backupPrefix(userID) + "snapshots/" + snapshotID
+}
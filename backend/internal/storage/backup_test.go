@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/readitlater/backend/internal/encryption"
+)
+
+func TestBackup(t *testing.T) {
+	source, err := NewLocalBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create source backend: %v", err)
+	}
+	_, remote := newFakeS3(t)
+
+	// Backups use the configured content encryption algorithm
+	encService := encryption.NewService(encryption.AlgorithmXChaCha20Poly1305, 0)
+	backup := NewBackup(source, remote, encService, "test-master-key-with-32-characters", 2)
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	backup.now = func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+
+	userID := uuid.New().String()
+	first, second := uuid.New().String(), uuid.New().String()
+
+	put := func(t *testing.T, articleID, data string) {
+		if err := source.Put(keysFor(t, userID, articleID).Content, strings.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("Failed to write content: %v", err)
+		}
+	}
+	get := func(t *testing.T, articleID string) string {
+		data, err := readAll(source, keysFor(t, userID, articleID).Content)
+		if err != nil {
+			t.Fatalf("Failed to read content: %v", err)
+		}
+		return string(data)
+	}
+	restore := func(t *testing.T, b *Backup, snapshotID, articleID string) (int, error) {
+		restored := 0
+		err := b.Restore(userID, snapshotID, articleID, func(object *RestoredObject) error {
+			restored++
+			data := object.Data
+			return source.Put(keysFor(t, userID, object.ArticleID).Content, bytes.NewReader(data), int64(len(data)))
+		})
+		return restored, err
+	}
+
+	put(t, first, `{"content":"first secret article"}`)
+	put(t, second, `{"content":"second article"}`)
+
+	var initial *Snapshot
+	t.Run("FirstBackupUploadsEverything", func(t *testing.T) {
+		snapshot, _, err := backup.Run(context.Background(), userID)
+		if err != nil {
+			t.Fatalf("Backup failed: %v", err)
+		}
+		if snapshot.ObjectCount != 2 || snapshot.Uploaded != 2 {
+			t.Errorf("Expected 2 objects uploaded, got %d of %d", snapshot.Uploaded, snapshot.ObjectCount)
+		}
+		initial = snapshot
+	})
+
+	t.Run("RemoteIsEncrypted", func(t *testing.T) {
+		objects, err := remote.List("backups/")
+		if err != nil {
+			t.Fatalf("Failed to list remote: %v", err)
+		}
+		for _, object := range objects {
+			data, _ := readAll(remote, object.Key)
+			if bytes.Contains(data, []byte("secret")) || bytes.Contains(data, []byte(first)) {
+				t.Errorf("Remote object %s contains plaintext", object.Key)
+			}
+		}
+	})
+
+	t.Run("UnchangedBackupUploadsNothing", func(t *testing.T) {
+		snapshot, _, err := backup.Run(context.Background(), userID)
+		if err != nil {
+			t.Fatalf("Backup failed: %v", err)
+		}
+		if snapshot.ObjectCount != 2 || snapshot.Uploaded != 0 {
+			t.Errorf("Expected nothing uploaded, got %d of %d", snapshot.Uploaded, snapshot.ObjectCount)
+		}
+	})
+
+	t.Run("ChangedObjectIsUploaded", func(t *testing.T) {
+		put(t, first, `{"content":"first article, edited"}`)
+
+		snapshot, pruned, err := backup.Run(context.Background(), userID)
+		if err != nil {
+			t.Fatalf("Backup failed: %v", err)
+		}
+		if snapshot.Uploaded != 1 {
+			t.Errorf("Expected 1 object uploaded, got %d", snapshot.Uploaded)
+		}
+		if len(pruned) != 1 || pruned[0] != initial.ID {
+			t.Errorf("Expected the first snapshot to be pruned, got %v", pruned)
+		}
+	})
+
+	t.Run("RestoreSingleArticle", func(t *testing.T) {
+		snapshots, _ := backup.Snapshots(userID)
+		older := snapshots[1]
+
+		put(t, first, "corrupted")
+		put(t, second, "also changed")
+
+		restored, err := restore(t, backup, older.ID, first)
+		if err != nil || restored != 1 {
+			t.Fatalf("Expected 1 restored object, got %d (%v)", restored, err)
+		}
+		if got := get(t, first); got != `{"content":"first secret article"}` {
+			t.Errorf("Expected the backed up content, got %q", got)
+		}
+		if got := get(t, second); got != "also changed" {
+			t.Errorf("Other articles should be untouched, got %q", got)
+		}
+
+		if _, err := restore(t, backup, older.ID, uuid.New().String()); err != ErrNotFound {
+			t.Errorf("Restoring an unknown article should fail, got %v", err)
+		}
+	})
+
+	t.Run("RestoreWholeSnapshot", func(t *testing.T) {
+		snapshots, _ := backup.Snapshots(userID)
+		latest := snapshots[0]
+
+		restored, err := restore(t, backup, latest.ID, "")
+		if err != nil || restored != 2 {
+			t.Fatalf("Expected 2 restored objects, got %d (%v)", restored, err)
+		}
+		if got := get(t, first); got != `{"content":"first article, edited"}` {
+			t.Errorf("Expected the latest backed up content, got %q", got)
+		}
+		if got := get(t, second); got != `{"content":"second article"}` {
+			t.Errorf("Expected the backed up content, got %q", got)
+		}
+	})
+
+	t.Run("RetentionRemovesUnreferencedBlobs", func(t *testing.T) {
+		// Restored files have new modification times but unchanged content
+		snapshot, _, err := backup.Run(context.Background(), userID)
+		if err != nil {
+			t.Fatalf("Backup failed: %v", err)
+		}
+		if snapshot.Uploaded != 0 {
+			t.Errorf("Expected nothing uploaded, got %d", snapshot.Uploaded)
+		}
+
+		snapshots, err := backup.Snapshots(userID)
+		if err != nil {
+			t.Fatalf("Failed to list snapshots: %v", err)
+		}
+		if len(snapshots) != 2 {
+			t.Fatalf("Expected 2 retained snapshots, got %d", len(snapshots))
+		}
+
+		// The original version of the first article is only referenced by
+		// pruned snapshots
+		blobs, err := backup.remoteBlobs(userID)
+		if err != nil {
+			t.Fatalf("Failed to list blobs: %v", err)
+		}
+		if len(blobs) != 2 {
+			t.Errorf("Expected 2 live blobs, got %d", len(blobs))
+		}
+
+		if _, err := restore(t, backup, initial.ID, ""); err != ErrSnapshotNotFound {
+			t.Errorf("Pruned snapshot should not be restorable, got %v", err)
+		}
+	})
+
+	t.Run("WrongKeyCannotRead", func(t *testing.T) {
+		other := NewBackup(source, remote, encService, "another-master-key-with-32-chars!!", 2)
+		if _, err := other.Snapshots(userID); err == nil {
+			t.Error("Snapshots should not decrypt with another key")
+		}
+	})
+
+	t.Run("RotatedMasterKey", func(t *testing.T) {
+		rotated := NewBackup(source, remote, encService, "another-master-key-with-32-chars!!", 2).WithPreviousKeys([]string{"test-master-key-with-32-characters"})
+		rotated.now = backup.now
+
+		snapshots, err := rotated.Snapshots(userID)
+		if err != nil || len(snapshots) == 0 {
+			t.Fatalf("Expected snapshots under the previous key to stay readable, got %d, %v", len(snapshots), err)
+		}
+
+		put(t, first, `{"content":"after rotation"}`)
+		snapshot, _, err := rotated.Run(context.Background(), userID)
+		if err != nil {
+			t.Fatalf("Backup failed: %v", err)
+		}
+		if snapshot.Uploaded != 2 {
+			t.Errorf("Expected unchanged objects to be uploaded again under the new key, got %d uploads", snapshot.Uploaded)
+		}
+
+		// The new snapshot no longer depends on the previous key
+		current := NewBackup(source, remote, encService, "another-master-key-with-32-chars!!", 2)
+		put(t, first, `{"content":"lost"}`)
+		put(t, second, `{"content":"lost"}`)
+		if _, err := restore(t, current, snapshot.ID, ""); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		if get(t, first) != `{"content":"after rotation"}` || get(t, second) != `{"content":"second article"}` {
+			t.Errorf("Unexpected restored content: %q, %q", get(t, first), get(t, second))
+		}
+	})
+}
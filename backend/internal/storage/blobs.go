@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// minBlobSize is the smallest article body moved to a shared blob. Smaller
+// bodies stay inline, where a separate object would cost more than it saves.
+const minBlobSize = 4096
+
+// blobPrefix is the key prefix of content-addressed blobs
+const blobPrefix = "blobs/"
+
+// ErrBlobNotFound is returned when a content file refers to a missing blob
+var ErrBlobNotFound = errors.New("content blob not found")
+
+// contentRefField is the JSON field naming the blob of a content file
+var contentRefField = []byte(`"content_ref"`)
+
+// BlobKey returns the key of the blob with a hash. Blobs are spread over
+// 256 directories by the first byte of their hash.
+func BlobKey(hash string) string {
+	return blobPrefix + hash[:2] + "/" + hash
+}
+
+// IsValidBlobHash checks that a hash has the shape produced by ContentHash
+func IsValidBlobHash(hash string) bool {
+	if len(hash) != hex.EncodedLen(sha256.Size) {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// ContentHash returns the hash of the blob that encoded article content
+// is deduplicated into, or "" if it is stored inline. Encrypted content is
+// never shared: ciphertext is unique per user and sharing it would reveal
+// which users saved the same page.
+func (s *Service) ContentHash(contentBytes []byte) (string, error) {
+	if !s.config.StorageDeduplication {
+		return "", nil
+	}
+
+	var article ArticleContent
+	if err := json.Unmarshal(contentBytes, &article); err != nil {
+		return "", fmt.Errorf("failed to parse article content: %w", err)
+	}
+
+	if article.IsEncrypted || article.ServerEncrypted || len(article.Content) < minBlobSize {
+		return "", nil
+	}
+
+	sum := sha256.Sum256([]byte(article.Content))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// putBlob stores the body of a blob unless it already exists
+func (s *Service) putBlob(hash string, body []byte) error {
+	key := BlobKey(hash)
+	if _, err := s.backend.Stat(key); err == nil {
+		return nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to check content blob: %w", err)
+	}
+
+	stored, err := encodeFormat(body, s.config.StorageCompression)
+	if err != nil {
+		return err
+	}
+
+	if err := s.backend.Put(key, bytes.NewReader(stored), int64(len(stored))); err != nil {
+		return fmt.Errorf("failed to write content blob: %w", err)
+	}
+	return nil
+}
+
+// DeleteBlob removes a blob. Callers must ensure nothing refers to it.
+func (s *Service) DeleteBlob(hash string) error {
+	if !IsValidBlobHash(hash) {
+		return fmt.Errorf("invalid blob hash: %s", hash)
+	}
+
+	if err := s.backend.Delete(BlobKey(hash)); err != nil {
+		return fmt.Errorf("failed to delete content blob: %w", err)
+	}
+	return nil
+}
+
+// DecodeContent turns a stored content file into article JSON: it is
+// decompressed and a shared body is read back from its blob. The result is
+// byte for byte what was passed to WriteContent.
+func DecodeContent(backend Backend, stored []byte) ([]byte, error) {
+	data, err := decodeFormat(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Contains(data, contentRefField) {
+		return data, nil
+	}
+
+	var article ArticleContent
+	if err := json.Unmarshal(data, &article); err != nil {
+		return nil, fmt.Errorf("failed to parse article content: %w", err)
+	}
+	if article.ContentRef == "" {
+		return data, nil
+	}
+	if !IsValidBlobHash(article.ContentRef) {
+		return nil, fmt.Errorf("invalid blob hash: %s", article.ContentRef)
+	}
+
+	blob, err := readAll(backend, BlobKey(article.ContentRef))
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrBlobNotFound, article.ContentRef)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := decodeFormat(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	article.Content = string(body)
+	article.ContentRef = ""
+
+	data, err = json.Marshal(article)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize content: %w", err)
+	}
+	return data, nil
+}
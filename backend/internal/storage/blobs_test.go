@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/config"
+)
+
+func TestContentFormat(t *testing.T) {
+	data := []byte(`{"id":"1","content":"` + strings.Repeat("hello ", 1000) + `"}`)
+
+	t.Run("GzipRoundTrip", func(t *testing.T) {
+		stored, err := encodeFormat(data, CompressionGzip)
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		if !bytes.HasPrefix(stored, []byte(formatMagic)) {
+			t.Error("Compressed content should start with the format marker")
+		}
+		if len(stored) >= len(data) {
+			t.Errorf("Expected compression, got %d bytes from %d", len(stored), len(data))
+		}
+
+		decoded, err := decodeFormat(stored)
+		if err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Error("Decoded content doesn't match original")
+		}
+	})
+
+	t.Run("LegacyPlainJSON", func(t *testing.T) {
+		decoded, err := decodeFormat(data)
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Errorf("Plain JSON should read as it is, got error %v", err)
+		}
+	})
+
+	t.Run("UnknownCodec", func(t *testing.T) {
+		if _, err := decodeFormat([]byte(formatMagic + "\x7fdata")); err == nil {
+			t.Error("Unknown codecs should be rejected")
+		}
+	})
+}
+
+func TestContentDeduplication(t *testing.T) {
+	backend, err := NewLocalBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local backend: %v", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := &config.Config{StorageCompression: CompressionGzip, StorageDeduplication: true}
+	service := NewService(cfg, backend, logger)
+
+	page := "<p>" + strings.Repeat("A page saved by several users. ", 500) + "</p>"
+	save := func(t *testing.T, content string) (string, string, []byte, *ObjectInfo) {
+		userID, articleID := uuid.New().String(), uuid.New().String()
+		contentJSON, _ := json.Marshal(ArticleContent{Title: "Page", Content: content})
+		encoded, err := service.EncodeContent(userID, articleID, string(contentJSON))
+		if err != nil {
+			t.Fatalf("Failed to encode content: %v", err)
+		}
+		object, err := service.WriteContent(userID, articleID, encoded)
+		if err != nil {
+			t.Fatalf("Failed to write content: %v", err)
+		}
+		return userID, articleID, encoded, object
+	}
+
+	firstUser, firstArticle, firstEncoded, firstObject := save(t, page)
+	secondUser, secondArticle, _, _ := save(t, page)
+
+	t.Run("IdenticalBodiesShareOneBlob", func(t *testing.T) {
+		blobs, err := backend.List(blobPrefix)
+		if err != nil {
+			t.Fatalf("Failed to list blobs: %v", err)
+		}
+		if len(blobs) != 1 {
+			t.Fatalf("Expected 1 blob, got %d", len(blobs))
+		}
+
+		hash, _ := service.ContentHash(firstEncoded)
+		if blobs[0].Key != BlobKey(hash) {
+			t.Errorf("Expected blob %s, got %s", BlobKey(hash), blobs[0].Key)
+		}
+	})
+
+	t.Run("ReadsReturnWhatWasWritten", func(t *testing.T) {
+		content, err := service.GetContent(firstUser, firstArticle)
+		if err != nil {
+			t.Fatalf("Failed to get content: %v", err)
+		}
+		if content != string(firstEncoded) {
+			t.Error("Content read back doesn't match what was written")
+		}
+
+		var article ArticleContent
+		content, err = service.GetContent(secondUser, secondArticle)
+		if err != nil || json.Unmarshal([]byte(content), &article) != nil || article.Content != page {
+			t.Errorf("Second article should read the shared body, got error %v", err)
+		}
+	})
+
+	t.Run("SmallAndEncryptedContentStaysInline", func(t *testing.T) {
+		small, _ := json.Marshal(ArticleContent{Content: "short"})
+		if hash, _ := service.ContentHash(small); hash != "" {
+			t.Errorf("Small content should not be shared, got %s", hash)
+		}
+
+		encrypted, _ := json.Marshal(ArticleContent{Content: page, IsEncrypted: true})
+		if hash, _ := service.ContentHash(encrypted); hash != "" {
+			t.Errorf("Encrypted content should not be shared, got %s", hash)
+		}
+	})
+
+	t.Run("WriteReportsStoredSize", func(t *testing.T) {
+		info, err := backend.Stat(firstObject.Key)
+		if err != nil {
+			t.Fatalf("Failed to stat content: %v", err)
+		}
+		if firstObject.Size != info.Size {
+			t.Errorf("Expected stored size %d, got %d", info.Size, firstObject.Size)
+		}
+		if firstObject.Size >= int64(len(firstEncoded)) {
+			t.Errorf("Stored file should be smaller than the %d byte article, got %d", len(firstEncoded), firstObject.Size)
+		}
+	})
+
+	t.Run("MissingBlob", func(t *testing.T) {
+		hash, _ := service.ContentHash(firstEncoded)
+		if err := service.DeleteBlob(hash); err != nil {
+			t.Fatalf("Failed to delete blob: %v", err)
+		}
+
+		if _, err := service.GetContent(firstUser, firstArticle); !errors.Is(err, ErrBlobNotFound) {
+			t.Errorf("Expected ErrBlobNotFound, got %v", err)
+		}
+	})
+}
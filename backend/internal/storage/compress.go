@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// Compression codecs for stored content
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// formatMagic starts every content file and blob written with a codec and
+// is followed by a codec byte. Files written before compression existed are
+// plain JSON without the marker and are read as they are.
+const formatMagic = "RIL\x00"
+
+// Codec bytes following formatMagic. New codecs such as zstd get new bytes
+// so existing files keep reading.
+const (
+	codecGzip byte = 1
+)
+
+// encodeFormat compresses data with a codec and prefixes the format marker.
+// With no compression the data is stored as it is.
+func encodeFormat(data []byte, compression string) ([]byte, error) {
+	switch compression {
+	case CompressionNone, "":
+		return data, nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		buf.WriteString(formatMagic)
+		buf.WriteByte(codecGzip)
+
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to compress content: %w", err)
+		}
+		if err := writer.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress content: %w", err)
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", compression)
+	}
+}
+
+// decodeFormat returns the original bytes of stored data, decompressing it
+// when it carries the format marker
+func decodeFormat(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(formatMagic)) {
+		return data, nil
+	}
+	if len(data) < len(formatMagic)+1 {
+		return nil, fmt.Errorf("truncated content header")
+	}
+
+	payload := data[len(formatMagic)+1:]
+	switch codec := data[len(formatMagic)]; codec {
+	case codecGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress content: %w", err)
+		}
+		defer reader.Close()
+
+		decoded, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress content: %w", err)
+		}
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("unsupported content codec %d", codec)
+	}
+}
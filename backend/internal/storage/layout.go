@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Content layouts. Flat keeps every article of a user in one directory as
+// users/<user id>/<article id>.json. Sharded spreads articles over 65536
+// directories by the first four hex digits of their ID and gives each
+// article its own directory for content and assets:
+// users/<user id>/ab/cd/<article id>/content.json
+const (
+	LayoutFlat    = 1
+	LayoutSharded = 2
+
+	// CurrentLayout is the layout new content is written in
+	CurrentLayout = LayoutSharded
+)
+
+// ErrInvalidID is returned when a user or article ID is not a canonical
+// UUID
+var ErrInvalidID = errors.New("invalid ID")
+
+// ErrInvalidAssetName is returned when an asset name is not a single key
+// segment
+var ErrInvalidAssetName = errors.New("invalid asset name")
+
+// contentFileName is the name of the content file in an article directory
+const contentFileName = "content.json"
+
+// assetsDirName is the directory of an article's assets
+const assetsDirName = "assets"
+
+// ContentLocation describes the article a key belongs to
+type ContentLocation struct {
+	UserID    string
+	ArticleID string
+	Layout    int
+	Asset     bool // the key is an asset, not the content file
+}
+
+// ArticleKeys are the keys of an article's stored content and assets
+type ArticleKeys struct {
+	Content string // content file in the current layout
+	Flat    string // content file in the flat layout
+	Assets  string // prefix of the article's asset keys
+}
+
+// ArticleKeysFor validates a user and an article ID and returns the keys
+// of the article. It is the only place keys are built from IDs: both must
+// be canonical UUIDs, so no ID can add a path segment or leave the user's
+// directory.
+func ArticleKeysFor(userID, articleID string) (*ArticleKeys, error) {
+	if err := validateID(userID); err != nil {
+		return nil, err
+	}
+	if err := validateID(articleID); err != nil {
+		return nil, err
+	}
+
+	dir := articleDir(userID, articleID)
+	return &ArticleKeys{
+		Content: dir + contentFileName,
+		Flat:    userPrefix(userID) + articleID + ".json",
+		Assets:  dir + assetsDirName + "/",
+	}, nil
+}
+
+// UserPrefix validates a user ID and returns the key prefix of the user's
+// content
+func UserPrefix(userID string) (string, error) {
+	if err := validateID(userID); err != nil {
+		return "", err
+	}
+	return userPrefix(userID), nil
+}
+
+// ContentKey returns the key of an article's content in the current layout
+func ContentKey(userID, articleID string) (string, error) {
+	keys, err := ArticleKeysFor(userID, articleID)
+	if err != nil {
+		return "", err
+	}
+	return keys.Content, nil
+}
+
+// contentKeys returns the keys an article's content may be stored under,
+// current layout first
+func (k *ArticleKeys) contentKeys() []string {
+	return []string{k.Content, k.Flat}
+}
+
+// AssetKey returns the key of an asset of the article. The name must be a
+// single key segment, so it cannot leave the article's assets directory.
+func (k *ArticleKeys) AssetKey(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidAssetName, name)
+	}
+	return k.Assets + name, nil
+}
+
+// validateID accepts only UUIDs in canonical lower-case hyphenated form
+func validateID(id string) error {
+	parsed, err := uuid.Parse(id)
+	if err != nil || parsed.String() != id {
+		return fmt.Errorf("%w: %q", ErrInvalidID, id)
+	}
+	return nil
+}
+
+// articleDir returns the directory of an article in the sharded layout.
+// The IDs must already be validated.
+func articleDir(userID, articleID string) string {
+	shard := strings.ReplaceAll(articleID, "-", "")
+	return userPrefix(userID) + shard[:2] + "/" + shard[2:4] + "/" + articleID + "/"
+}
+
+// userPrefix returns the key prefix of a user's content. The ID must
+// already be validated.
+func userPrefix(userID string) string {
+	return "users/" + userID + "/"
+}
+
+// ParseContentKey returns the article a content or asset key belongs to in
+// either layout. When the key is not one, the user ID is still filled in if
+// the key is under a valid user directory.
+func ParseContentKey(key string) (ContentLocation, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) < 2 || parts[0] != "users" {
+		return ContentLocation{}, false
+	}
+
+	if validateID(parts[1]) != nil {
+		return ContentLocation{}, false
+	}
+	location := ContentLocation{UserID: parts[1]}
+
+	// users/<user id>/<article id>.json
+	if len(parts) == 3 {
+		articleID := strings.TrimSuffix(parts[2], ".json")
+		if articleID == parts[2] || validateID(articleID) != nil {
+			return location, false
+		}
+		location.ArticleID = articleID
+		location.Layout = LayoutFlat
+		return location, true
+	}
+
+	// users/<user id>/ab/cd/<article id>/content.json or .../assets/...
+	if len(parts) < 6 || validateID(parts[4]) != nil {
+		return location, false
+	}
+	if articleDir(location.UserID, parts[4]) != strings.Join(parts[:5], "/")+"/" {
+		return location, false
+	}
+
+	switch {
+	case len(parts) == 6 && parts[5] == contentFileName:
+	case len(parts) > 6 && parts[5] == assetsDirName && parts[len(parts)-1] != "":
+		location.Asset = true
+	default:
+		return location, false
+	}
+
+	location.ArticleID = parts[4]
+	location.Layout = LayoutSharded
+	return location, true
+}
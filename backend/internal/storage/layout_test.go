@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/config"
+)
+
+func TestParseContentKey(t *testing.T) {
+	userID, articleID := uuid.New().String(), "3f2a9c1e-7b4d-4e0a-9c5f-1d2e3f4a5b6c"
+	keys := keysFor(t, userID, articleID)
+
+	t.Run("BothLayouts", func(t *testing.T) {
+		for key, layout := range map[string]int{
+			keys.Content: LayoutSharded,
+			keys.Flat:    LayoutFlat,
+		} {
+			location, ok := ParseContentKey(key)
+			if !ok || location.UserID != userID || location.ArticleID != articleID || location.Layout != layout || location.Asset {
+				t.Errorf("Unexpected location for %s: %+v, %t", key, location, ok)
+			}
+		}
+	})
+
+	t.Run("ShardedKeyShape", func(t *testing.T) {
+		hex := strings.ReplaceAll(articleID, "-", "")
+		expected := "users/" + userID + "/" + hex[:2] + "/" + hex[2:4] + "/" + articleID + "/content.json"
+		if keys.Content != expected {
+			t.Errorf("Expected %s, got %s", expected, keys.Content)
+		}
+	})
+
+	t.Run("Asset", func(t *testing.T) {
+		location, ok := ParseContentKey(keys.Assets + "images/cover.png")
+		if !ok || !location.Asset || location.ArticleID != articleID {
+			t.Errorf("Expected an asset of %s, got %+v, %t", articleID, location, ok)
+		}
+	})
+
+	t.Run("InvalidKeys", func(t *testing.T) {
+		hex := strings.ReplaceAll(articleID, "-", "")
+		for _, key := range []string{
+			"blobs/ab/abcdef",
+			"users/" + userID + "/notes.txt",
+			"users/not-a-uuid/" + articleID + ".json",
+			"users/" + userID + "/00/00/" + articleID + "/content.json",
+			"users/" + userID + "/" + hex[:2] + "/" + hex[2:4] + "/" + articleID + "/other.json",
+			"users/" + userID + "/" + hex[:2] + "/" + hex[2:4] + "/" + articleID + "/assets/",
+		} {
+			if location, ok := ParseContentKey(key); ok {
+				t.Errorf("Expected %s to be rejected, got %+v", key, location)
+			}
+		}
+	})
+}
+
+func TestArticleKeysFor(t *testing.T) {
+	userID, articleID := uuid.New().String(), uuid.New().String()
+
+	t.Run("RejectsNonCanonicalIDs", func(t *testing.T) {
+		for _, id := range []string{
+			"",
+			"..",
+			"../" + articleID,
+			articleID + "/..",
+			strings.ToUpper(articleID),
+			"{" + articleID + "}",
+			"urn:uuid:" + articleID,
+			strings.ReplaceAll(articleID, "-", ""),
+			articleID + "\x00",
+		} {
+			if _, err := ArticleKeysFor(userID, id); !errors.Is(err, ErrInvalidID) {
+				t.Errorf("Expected article ID %q to be rejected, got %v", id, err)
+			}
+			if _, err := ArticleKeysFor(id, articleID); !errors.Is(err, ErrInvalidID) {
+				t.Errorf("Expected user ID %q to be rejected, got %v", id, err)
+			}
+			if _, err := UserPrefix(id); !errors.Is(err, ErrInvalidID) {
+				t.Errorf("Expected user prefix for %q to be rejected", id)
+			}
+		}
+	})
+
+	t.Run("ServiceRejectsInvalidIDs", func(t *testing.T) {
+		logger := logrus.New()
+		logger.SetOutput(io.Discard)
+		backend, err := NewLocalBackend(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create local backend: %v", err)
+		}
+		service := NewService(&config.Config{}, backend, logger)
+
+		if _, err := service.GetContent(userID, "../../etc/passwd"); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Expected GetContent to reject the ID, got %v", err)
+		}
+		if err := service.DeleteContent("..", articleID); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Expected DeleteContent to reject the ID, got %v", err)
+		}
+		if _, err := service.WriteContent(userID, articleID+"/x", []byte(`{}`)); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Expected WriteContent to reject the ID, got %v", err)
+		}
+		if _, err := service.ListUserContent("../" + userID); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Expected ListUserContent to reject the ID, got %v", err)
+		}
+	})
+}
+
+// FuzzArticleKeysFor checks that keys are only ever built for canonical
+// IDs and always stay inside the user's directory
+func FuzzArticleKeysFor(f *testing.F) {
+	id := "3f2a9c1e-7b4d-4e0a-9c5f-1d2e3f4a5b6c"
+	f.Add(id, id)
+	f.Add(id, "../"+id)
+	f.Add("..", id)
+	f.Add(strings.ToUpper(id), id)
+	f.Add(id, id+"/../../x")
+	f.Add(id, "\\..\\"+id)
+
+	root := f.TempDir()
+	backend, err := NewLocalBackend(root)
+	if err != nil {
+		f.Fatalf("Failed to create local backend: %v", err)
+	}
+
+	f.Fuzz(func(t *testing.T, userID, articleID string) {
+		keys, err := ArticleKeysFor(userID, articleID)
+		if err != nil {
+			return
+		}
+
+		if validateID(userID) != nil || validateID(articleID) != nil {
+			t.Fatalf("Keys built for invalid IDs %q, %q", userID, articleID)
+		}
+
+		for _, key := range []string{keys.Content, keys.Flat, keys.Assets + "file"} {
+			if !strings.HasPrefix(key, "users/"+userID+"/") {
+				t.Fatalf("Key %q is outside the user's directory", key)
+			}
+			location, ok := ParseContentKey(key)
+			if !ok || location.UserID != userID || location.ArticleID != articleID {
+				t.Fatalf("Key %q does not parse back to its IDs: %+v", key, location)
+			}
+			path, err := backend.path(key)
+			if err != nil {
+				t.Fatalf("Valid key %q rejected: %v", key, err)
+			}
+			if !strings.HasPrefix(path, filepath.Join(root, "users", userID)+string(filepath.Separator)) {
+				t.Fatalf("Key %q resolves outside the user's directory: %s", key, path)
+			}
+		}
+	})
+}
+
+func TestContentLayouts(t *testing.T) {
+	backend, err := NewLocalBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local backend: %v", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	service := NewService(&config.Config{StorageCompression: CompressionNone}, backend, logger)
+
+	userID := uuid.New().String()
+	putFlat := func(t *testing.T, articleID string) {
+		data := `{"id":"` + articleID + `","title":"flat"}`
+		if err := backend.Put(keysFor(t, userID, articleID).Flat, strings.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("Failed to write flat content: %v", err)
+		}
+	}
+	exists := func(key string) bool {
+		_, err := backend.Stat(key)
+		return err == nil
+	}
+
+	t.Run("ReadsFlatLayout", func(t *testing.T) {
+		articleID := uuid.New().String()
+		putFlat(t, articleID)
+
+		content, err := service.GetContent(userID, articleID)
+		if err != nil || !strings.Contains(content, `"flat"`) {
+			t.Errorf("Expected flat content, got %q, %v", content, err)
+		}
+	})
+
+	t.Run("WriteReplacesFlatCopy", func(t *testing.T) {
+		articleID := uuid.New().String()
+		putFlat(t, articleID)
+
+		encoded, _ := service.EncodeContent(userID, articleID, `{"title":"sharded"}`)
+		object, err := service.WriteContent(userID, articleID, encoded)
+		if err != nil {
+			t.Fatalf("Failed to write content: %v", err)
+		}
+		if object.Key != keysFor(t, userID, articleID).Content {
+			t.Errorf("Expected content in the current layout, got %s", object.Key)
+		}
+		if exists(keysFor(t, userID, articleID).Flat) {
+			t.Error("Flat copy should be removed")
+		}
+	})
+
+	t.Run("MoveContent", func(t *testing.T) {
+		articleID := uuid.New().String()
+		putFlat(t, articleID)
+
+		for i := 0; i < 2; i++ {
+			key, err := service.MoveContent(userID, articleID)
+			if err != nil || key != keysFor(t, userID, articleID).Content {
+				t.Fatalf("Move %d failed: %s, %v", i, key, err)
+			}
+		}
+		keys := keysFor(t, userID, articleID)
+		if exists(keys.Flat) || !exists(keys.Content) {
+			t.Error("Content should only exist in the current layout")
+		}
+
+		if _, err := service.MoveContent(userID, uuid.New().String()); !errors.Is(err, ErrContentNotFound) {
+			t.Errorf("Expected ErrContentNotFound, got %v", err)
+		}
+	})
+
+	t.Run("ListPrefersCurrentLayout", func(t *testing.T) {
+		otherUser := userID
+		userID = uuid.New().String()
+		defer func() { userID = otherUser }()
+
+		articleID := uuid.New().String()
+		putFlat(t, articleID)
+		encoded, _ := service.EncodeContent(userID, articleID, `{"title":"sharded"}`)
+		data := string(encoded)
+		if err := backend.Put(keysFor(t, userID, articleID).Content, strings.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("Failed to write content: %v", err)
+		}
+		putFlat(t, uuid.New().String())
+
+		articles, err := service.ListUserContent(userID)
+		if err != nil {
+			t.Fatalf("Failed to list content: %v", err)
+		}
+		if len(articles) != 2 {
+			t.Fatalf("Expected 2 articles, got %d", len(articles))
+		}
+		for _, article := range articles {
+			if article.ID == articleID && article.Title != "sharded" {
+				t.Errorf("Expected the current layout copy, got %q", article.Title)
+			}
+		}
+	})
+
+	t.Run("DeleteRemovesAssets", func(t *testing.T) {
+		articleID := uuid.New().String()
+		putFlat(t, articleID)
+		asset := keysFor(t, userID, articleID).Assets + "cover.png"
+		if err := backend.Put(asset, strings.NewReader("png"), 3); err != nil {
+			t.Fatalf("Failed to write asset: %v", err)
+		}
+
+		if err := service.DeleteContent(userID, articleID); err != nil {
+			t.Fatalf("Failed to delete content: %v", err)
+		}
+		if exists(keysFor(t, userID, articleID).Flat) || exists(asset) {
+			t.Error("Content and assets should be removed")
+		}
+	})
+}
+
+// keysFor returns the keys of an article, failing the test on invalid IDs
+func keysFor(t *testing.T, userID, articleID string) *ArticleKeys {
+	t.Helper()
+	keys, err := ArticleKeysFor(userID, articleID)
+	if err != nil {
+		t.Fatalf("Failed to build keys: %v", err)
+	}
+	return keys
+}
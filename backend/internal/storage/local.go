@@ -0,0 +1,355 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// tempFilePrefix marks files being written by Put. They are never listed
+// and can be removed once stale.
+const tempFilePrefix = ".tmp-"
+
+// Stored content is private to the server's user
+const (
+	dirMode  fs.FileMode = 0700
+	fileMode fs.FileMode = 0600
+)
+
+// LocalBackend stores objects as files under a root directory
+type LocalBackend struct {
+	root     string
+	realRoot string // root with symlinks resolved
+}
+
+// NewLocalBackend creates a local filesystem backend rooted at root
+func NewLocalBackend(root string) (*LocalBackend, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage directory: %w", err)
+	}
+
+	if err := os.MkdirAll(root, dirMode); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage directory: %w", err)
+	}
+
+	return &LocalBackend{root: root, realRoot: realRoot}, nil
+}
+
+// Name returns the backend name
+func (l *LocalBackend) Name() string {
+	return BackendLocal
+}
+
+// Put writes an object atomically: the data is written to a temporary file
+// in the same directory, synced and renamed over the object's file, so
+// readers and crashes only ever see the old or the new content
+func (l *LocalBackend) Put(key string, r io.Reader, size int64) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, dirMode); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, tempFilePrefix+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", spaceError(err))
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", spaceError(err))
+	}
+	if err := tmp.Chmod(fileMode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync file: %w", spaceError(err))
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", spaceError(err))
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	// Persist the rename itself
+	return syncDir(dir)
+}
+
+// Get opens the file of an object
+func (l *LocalBackend) Get(key string) (io.ReadCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return file, nil
+}
+
+// GetRange opens part of the file of an object
+func (l *LocalBackend) GetRange(key string, offset, length int64) (io.ReadCloser, error) {
+	reader, err := l.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file := reader.(*os.File)
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(file, offset, length), file}, nil
+}
+
+// Delete removes the file of an object
+func (l *LocalBackend) Delete(key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// List walks the directory containing prefix and returns the matching files
+func (l *LocalBackend) List(prefix string) ([]ObjectInfo, error) {
+	// Only the deepest directory named by the prefix needs to be walked
+	dir := l.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		var err error
+		if dir, err = l.path(prefix[:i]); err != nil {
+			return nil, err
+		}
+	}
+
+	objects := []ObjectInfo{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if d.IsDir() || strings.HasPrefix(d.Name(), tempFilePrefix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	return objects, nil
+}
+
+// Stat returns the size and modification time of an object's file
+func (l *LocalBackend) Stat(key string) (*ObjectInfo, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.IsDir() {
+		return nil, ErrNotFound
+	}
+
+	return &ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// CleanupTempFiles removes temporary files left by interrupted writes. Only
+// files older than maxAge are removed so writes in progress are untouched.
+func (l *LocalBackend) CleanupTempFiles(maxAge time.Duration) (int, error) {
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+
+	err := filepath.WalkDir(l.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasPrefix(d.Name(), tempFilePrefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(cutoff) {
+			return nil
+		}
+
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("failed to clean up temporary files: %w", err)
+	}
+	return removed, nil
+}
+
+// path validates a key and maps it to a file under the root. Every file
+// the backend touches goes through here. Symlinks within the root are
+// followed only as far as they stay under it.
+func (l *LocalBackend) path(key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(l.root, filepath.FromSlash(key))
+	if !within(l.root, path) {
+		return "", fmt.Errorf("invalid object key: %q", key)
+	}
+
+	resolved, err := resolveExisting(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve object key %q: %w", key, err)
+	}
+	if !within(l.realRoot, resolved) {
+		return "", fmt.Errorf("invalid object key: %q resolves outside the storage directory", key)
+	}
+	return path, nil
+}
+
+// within reports whether path is strictly inside dir
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && filepath.IsLocal(rel)
+}
+
+// resolveExisting resolves the symlinks of the longest existing prefix of
+// a path and appends the rest, which does not exist yet. A dangling symlink
+// is an error, as whatever it points to could be created later.
+func resolveExisting(path string) (string, error) {
+	rest := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if info, lerr := os.Lstat(path); lerr == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("dangling symlink %s", path)
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
+	}
+}
+
+// CheckPermissions fails if the root directory can be accessed by users
+// other than its owner. Directories created before files were made private
+// may still be open, so the server checks this at startup.
+func (l *LocalBackend) CheckPermissions() error {
+	return checkDirPermissions(l.root)
+}
+
+// checkDirPermissions fails if a directory is not a directory or can be
+// accessed by users other than its owner
+func checkDirPermissions(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to check storage directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("storage path %s is not a directory", dir)
+	}
+	if mode := info.Mode().Perm(); mode&^dirMode != 0 {
+		return fmt.Errorf("storage directory %s is accessible by other users (mode %04o), run chmod %04o %s", dir, mode, dirMode, dir)
+	}
+	return nil
+}
+
+// syncDir flushes a directory so renames and removals within it are durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
+
+// spaceError marks errors caused by a full disk or an exhausted filesystem
+// quota as ErrInsufficientStorage
+func spaceError(err error) error {
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) {
+		return fmt.Errorf("%w: %v", ErrInsufficientStorage, err)
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/readitlater/backend/internal/encryption"
+)
+
+// ErrNotEncrypted is returned when rekeying content that is not encrypted
+var ErrNotEncrypted = errors.New("article content is not encrypted")
+
+// RekeyContent moves decoded encrypted content from oldKey to newKey in the
+// current format and returns its new encoded form, or nil when nothing
+// needs to change. Content with a wrapped data key only has the data key
+// rewrapped, which also covers assets encrypted under it, and fields sealed
+// without associated data are re-encrypted bound to the article. Older
+// content is re-encrypted under a new data key. Passing the same key twice
+// only upgrades the format. The result is readable with newKey only, so it
+// must replace the stored content in a single write.
+func (s *Service) RekeyContent(userID, articleID string, content []byte, oldKey, newKey string) ([]byte, error) {
+	var articleContent ArticleContent
+	if err := json.Unmarshal(content, &articleContent); err != nil {
+		return nil, fmt.Errorf("failed to parse article content: %w", err)
+	}
+	if !articleContent.IsEncrypted {
+		return nil, ErrNotEncrypted
+	}
+
+	if articleContent.WrappedKey == "" {
+		if err := s.decryptFields(userID, articleID, &articleContent, oldKey); err != nil {
+			return nil, err
+		}
+		if err := s.encryptFields(userID, articleID, &articleContent, newKey); err != nil {
+			return nil, err
+		}
+		return marshalContent(&articleContent)
+	}
+
+	changed, err := s.rewrapDataKey(userID, articleID, &articleContent, oldKey, newKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fields written before associated data was bound
+	dataKey, err := s.unwrapDataKey(userID, articleID, &articleContent, newKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range encryptedFields(&articleContent) {
+		if encryption.HasAssociatedData(*field.value) {
+			continue
+		}
+
+		plaintext, err := s.encryption.DecryptWithKey(*field.value, dataKey, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", field.name, err)
+		}
+		*field.value = plaintext
+		if err := s.sealField(userID, articleID, field, dataKey); err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil, nil
+	}
+	return marshalContent(&articleContent)
+}
+
+// rewrapDataKey wraps an article's data key under newKey unless it already
+// is, for instance after an interrupted rotation. It reports whether the
+// wrapped key changed.
+func (s *Service) rewrapDataKey(userID, articleID string, content *ArticleContent, oldKey, newKey string) (bool, error) {
+	if _, err := s.unwrapDataKey(userID, articleID, content, newKey); err == nil {
+		return false, nil
+	}
+
+	dataKey, err := s.unwrapDataKey(userID, articleID, content, oldKey)
+	if err != nil {
+		return false, err
+	}
+
+	newKEK, err := encryption.DeriveUserKEK(newKey, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to derive key: %w", err)
+	}
+	if content.WrappedKey, err = s.encryption.WrapKey(newKEK, dataKey, dataKeyAAD(userID, articleID)); err != nil {
+		return false, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return true, nil
+}
+
+// unwrapDataKey unwraps an article's data key with a user key. A data key
+// that does not open under the user key is reported as ErrInvalidKey.
+func (s *Service) unwrapDataKey(userID, articleID string, content *ArticleContent, userKey string) ([]byte, error) {
+	kek, err := encryption.DeriveUserKEK(userKey, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	dataKey, err := s.encryption.UnwrapKey(kek, content.WrappedKey, dataKeyAAD(userID, articleID))
+	if errors.Is(err, encryption.ErrDecryptionFailed) {
+		return nil, fmt.Errorf("%w: %v", encryption.ErrInvalidKey, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// marshalContent serializes rekeyed content
+func marshalContent(content *ArticleContent) ([]byte, error) {
+	contentBytes, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize encrypted content: %w", err)
+	}
+	return contentBytes, nil
+}
@@ -1,11 +1,10 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/fs"
-	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -13,9 +12,13 @@ import (
 	"github.com/readitlater/backend/internal/encryption"
 )
 
-// Service handles file storage operations
+// ErrContentNotFound is returned when an article has no stored content
+var ErrContentNotFound = errors.New("article content not found")
+
+// Service handles article content storage on top of a storage backend
 type Service struct {
 	config     *config.Config
+	backend    Backend
 	logger     *logrus.Logger
 	encryption *encryption.Service
 }
@@ -33,30 +36,78 @@ type ArticleContent struct {
 	CreatedAt   time.Time         `json:"created_at"`
 	UpdatedAt   time.Time         `json:"updated_at"`
 	IsEncrypted bool              `json:"is_encrypted"`
+
+	// Description and Category are only kept in content files of
+	// full-privacy articles, where they are encrypted with the other fields
+	Description string `json:"description,omitempty"`
+	Category    string `json:"category,omitempty"`
+
+	// FullPrivacy also encrypts the title, URL and description, and packs
+	// tags, category and metadata into the encrypted PrivateFields
+	FullPrivacy   bool   `json:"full_privacy,omitempty"`
+	PrivateFields string `json:"private_fields,omitempty"`
+
+	// ServerEncrypted content has its fields encrypted like IsEncrypted
+	// content, but under the user's server-managed key instead of a key
+	// only the user holds
+	ServerEncrypted bool `json:"server_encrypted,omitempty"`
+
+	// WrappedKey is the article's data key wrapped by the user's
+	// key-encryption key. Encrypted content written before data keys
+	// existed has none and is encrypted under the user key directly.
+	WrappedKey string `json:"wrapped_key,omitempty"`
+
+	// ContentRef is the hash of the shared blob holding Content, which is
+	// then left empty in the stored file
+	ContentRef string `json:"content_ref,omitempty"`
 }
 
 // NewService creates a new storage service
-func NewService(cfg *config.Config, logger *logrus.Logger) *Service {
-	encService := encryption.NewService(100000) // 100k iterations for PBKDF2
+func NewService(cfg *config.Config, backend Backend, logger *logrus.Logger) *Service {
+	encService := encryption.NewService(cfg.EncryptionAlgorithm, cfg.KeyDerivationIterations)
 	return &Service{
 		config:     cfg,
+		backend:    backend,
 		logger:     logger,
 		encryption: encService,
 	}
 }
 
-// SaveContent saves content to local storage
+// SaveContent saves content to the storage backend and returns its key
 func (s *Service) SaveContent(userID, articleID, content string) (string, error) {
+	contentBytes, err := s.EncodeContent(userID, articleID, content)
+	if err != nil {
+		return "", err
+	}
+
+	object, err := s.WriteContent(userID, articleID, contentBytes)
+	if err != nil {
+		return "", err
+	}
+	return object.Key, nil
+}
+
+// SaveEncryptedContent saves encrypted content to the storage backend
+func (s *Service) SaveEncryptedContent(userID, articleID, content, userKey string) (string, error) {
+	contentBytes, err := s.EncodeEncryptedContent(userID, articleID, content, userKey)
+	if err != nil {
+		return "", err
+	}
+
+	object, err := s.WriteContent(userID, articleID, contentBytes)
+	if err != nil {
+		return "", err
+	}
+	return object.Key, nil
+}
+
+// EncodeContent returns the stored representation of article content
+// without writing it
+func (s *Service) EncodeContent(userID, articleID, content string) ([]byte, error) {
 	// Parse content as ArticleContent
 	var articleContent ArticleContent
 	if err := json.Unmarshal([]byte(content), &articleContent); err != nil {
-		return "", fmt.Errorf("failed to parse article content: %w", err)
-	}
-
-	// Ensure user directory exists
-	userDir := s.getUserStoragePath(userID)
-	if err := os.MkdirAll(userDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create user directory: %w", err)
+		return nil, fmt.Errorf("failed to parse article content: %w", err)
 	}
 
 	// Set metadata
@@ -70,118 +121,189 @@ func (s *Service) SaveContent(userID, articleID, content string) (string, error)
 	// Serialize content
 	contentBytes, err := json.Marshal(articleContent)
 	if err != nil {
-		return "", fmt.Errorf("failed to serialize content: %w", err)
+		return nil, fmt.Errorf("failed to serialize content: %w", err)
+	}
+
+	return contentBytes, nil
+}
+
+// EncodeEncryptedContent returns the stored representation of article
+// content with its content and summary encrypted, without writing it.
+// Content marked FullPrivacy also has its title, URL, description, tags,
+// category and metadata encrypted.
+func (s *Service) EncodeEncryptedContent(userID, articleID, content, userKey string) ([]byte, error) {
+	// Parse content as ArticleContent
+	var articleContent ArticleContent
+	if err := json.Unmarshal([]byte(content), &articleContent); err != nil {
+		return nil, fmt.Errorf("failed to parse article content: %w", err)
 	}
 
-	// Save to file
-	filePath := filepath.Join(userDir, fmt.Sprintf("%s.json", articleID))
-	if err := os.WriteFile(filePath, contentBytes, 0644); err != nil {
-		return "", fmt.Errorf("failed to write file: %w", err)
+	if err := s.encryptFields(userID, articleID, &articleContent, userKey); err != nil {
+		return nil, err
 	}
 
-	s.logger.WithFields(logrus.Fields{
-		"user_id":    userID,
-		"article_id": articleID,
-		"file_path":  filePath,
-	}).Info("Content saved successfully")
+	// Convert back to JSON
+	contentBytes, err := json.Marshal(articleContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize encrypted content: %w", err)
+	}
 
-	return filePath, nil
+	return s.EncodeContent(userID, articleID, string(contentBytes))
 }
 
-// SaveEncryptedContent saves encrypted content to local storage
-func (s *Service) SaveEncryptedContent(userID, articleID, content, userKey string) (string, error) {
-	// Parse content as ArticleContent
+// EncodeServerEncryptedContent returns the stored representation of
+// article content encrypted at rest under the user's server-managed key,
+// without writing it
+func (s *Service) EncodeServerEncryptedContent(userID, articleID, content, serverKey string) ([]byte, error) {
 	var articleContent ArticleContent
 	if err := json.Unmarshal([]byte(content), &articleContent); err != nil {
-		return "", fmt.Errorf("failed to parse article content: %w", err)
+		return nil, fmt.Errorf("failed to parse article content: %w", err)
+	}
+	if articleContent.FullPrivacy {
+		return nil, fmt.Errorf("full-privacy content must be encrypted with a user key")
 	}
 
-	// Encrypt the content field
-	encryptedContent, err := s.encryption.Encrypt(articleContent.Content, userKey)
+	if err := s.encryptFields(userID, articleID, &articleContent, serverKey); err != nil {
+		return nil, err
+	}
+	articleContent.IsEncrypted = false
+	articleContent.ServerEncrypted = true
+
+	contentBytes, err := json.Marshal(articleContent)
 	if err != nil {
-		return "", fmt.Errorf("failed to encrypt content: %w", err)
+		return nil, fmt.Errorf("failed to serialize encrypted content: %w", err)
 	}
 
-	// Encrypt the summary if present
-	if articleContent.Summary != "" {
-		encryptedSummary, err := s.encryption.Encrypt(articleContent.Summary, userKey)
-		if err != nil {
-			return "", fmt.Errorf("failed to encrypt summary: %w", err)
+	return s.EncodeContent(userID, articleID, string(contentBytes))
+}
+
+// ValidateEncryptedContent checks the structure of content encrypted by a
+// zero-knowledge client without decrypting it: the data key and every
+// encrypted field must be sealed under a data key with associated data.
+// Whether the fields open under the wrapped key, and are bound to the right
+// article, only the client can tell.
+func ValidateEncryptedContent(content *ArticleContent) error {
+	if content.WrappedKey == "" {
+		return fmt.Errorf("missing wrapped key")
+	}
+	if err := encryption.ValidateDataKeyCiphertext(content.WrappedKey); err != nil {
+		return fmt.Errorf("invalid wrapped key: %w", err)
+	}
+	for _, field := range encryptedFields(content) {
+		if err := encryption.ValidateDataKeyCiphertext(*field.value); err != nil {
+			return fmt.Errorf("invalid %s: %w", field.name, err)
 		}
-		articleContent.Summary = encryptedSummary
 	}
+	return nil
+}
 
-	articleContent.Content = encryptedContent
-	articleContent.IsEncrypted = true
+// WriteContent writes encoded article content to the storage backend in
+// the current layout, replacing any previous content, and returns the key
+// and size of the file written. Large unencrypted bodies are moved to the blob named
+// by ContentHash and the file is compressed.
+func (s *Service) WriteContent(userID, articleID string, contentBytes []byte) (*ObjectInfo, error) {
+	keys, err := ArticleKeysFor(userID, articleID)
+	if err != nil {
+		return nil, err
+	}
+	key := keys.Content
 
-	// Convert back to JSON and save
-	contentBytes, err := json.Marshal(articleContent)
+	stored := contentBytes
+	hash, err := s.ContentHash(contentBytes)
+	if err != nil {
+		return nil, err
+	}
+	if hash != "" {
+		var articleContent ArticleContent
+		if err := json.Unmarshal(contentBytes, &articleContent); err != nil {
+			return nil, fmt.Errorf("failed to parse article content: %w", err)
+		}
+
+		// The blob is written first so a file never refers to a missing blob
+		if err := s.putBlob(hash, []byte(articleContent.Content)); err != nil {
+			return nil, err
+		}
+
+		articleContent.Content = ""
+		articleContent.ContentRef = hash
+		if stored, err = json.Marshal(articleContent); err != nil {
+			return nil, fmt.Errorf("failed to serialize content: %w", err)
+		}
+	}
+
+	stored, err = encodeFormat(stored, s.config.StorageCompression)
 	if err != nil {
-		return "", fmt.Errorf("failed to serialize encrypted content: %w", err)
+		return nil, err
+	}
+
+	if err := s.backend.Put(key, bytes.NewReader(stored), int64(len(stored))); err != nil {
+		return nil, fmt.Errorf("failed to write content: %w", err)
 	}
 
-	return s.SaveContent(userID, articleID, string(contentBytes))
+	// Content rewritten in the current layout replaces any older copy
+	if err := s.backend.Delete(keys.Flat); err != nil {
+		return nil, fmt.Errorf("failed to delete previous content: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"article_id": articleID,
+		"key":        key,
+	}).Info("Content saved successfully")
+
+	return &ObjectInfo{Key: key, Size: int64(len(stored)), ModTime: time.Now()}, nil
 }
 
-// GetContent retrieves content from storage
+// GetContent retrieves content from storage in either layout
 func (s *Service) GetContent(userID, articleID string) (string, error) {
-	filePath := filepath.Join(s.getUserStoragePath(userID), fmt.Sprintf("%s.json", articleID))
-	
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return "", fmt.Errorf("article not found: %s", articleID)
+	key, stored, err := s.readContent(userID, articleID)
+	if err != nil {
+		return "", err
 	}
 
-	// Read file
-	contentBytes, err := os.ReadFile(filePath)
+	contentBytes, err := DecodeContent(s.backend, stored)
 	if err != nil {
-		return "", fmt.Errorf("failed to read file: %w", err)
+		return "", err
 	}
 
 	s.logger.WithFields(logrus.Fields{
 		"user_id":    userID,
 		"article_id": articleID,
-		"file_path":  filePath,
+		"key":        key,
 	}).Info("Content retrieved successfully")
 
 	return string(contentBytes), nil
 }
 
-// GetDecryptedContent retrieves and decrypts content from storage
+// GetDecryptedContent retrieves and decrypts content from storage. The key
+// is the user key of encrypted content, or the server key of
+// server-encrypted content.
 func (s *Service) GetDecryptedContent(userID, articleID, userKey string) (string, error) {
 	content, err := s.GetContent(userID, articleID)
 	if err != nil {
 		return "", err
 	}
 
+	return s.DecryptContent(userID, articleID, content, userKey)
+}
+
+// DecryptContent decrypts article content in its stored representation,
+// such as content returned to a zero-knowledge client
+func (s *Service) DecryptContent(userID, articleID, content, userKey string) (string, error) {
 	var articleContent ArticleContent
 	if err := json.Unmarshal([]byte(content), &articleContent); err != nil {
 		return "", fmt.Errorf("failed to parse article content: %w", err)
 	}
 
 	// If not encrypted, return as is
-	if !articleContent.IsEncrypted {
+	if !articleContent.IsEncrypted && !articleContent.ServerEncrypted {
 		return content, nil
 	}
 
-	// Decrypt content
-	decryptedContent, err := s.encryption.Decrypt(articleContent.Content, userKey)
-	if err != nil {
-		return "", fmt.Errorf("failed to decrypt content: %w", err)
-	}
-	articleContent.Content = decryptedContent
-
-	// Decrypt summary if present
-	if articleContent.Summary != "" {
-		decryptedSummary, err := s.encryption.Decrypt(articleContent.Summary, userKey)
-		if err != nil {
-			return "", fmt.Errorf("failed to decrypt summary: %w", err)
-		}
-		articleContent.Summary = decryptedSummary
+	if err := s.decryptFields(userID, articleID, &articleContent, userKey); err != nil {
+		return "", err
 	}
 
-	articleContent.IsEncrypted = false
-
 	// Convert back to JSON
 	decryptedBytes, err := json.Marshal(articleContent)
 	if err != nil {
@@ -191,160 +313,349 @@ func (s *Service) GetDecryptedContent(userID, articleID, userKey string) (string
 	return string(decryptedBytes), nil
 }
 
-// DeleteContent deletes content from storage
-func (s *Service) DeleteContent(userID, articleID string) error {
-	filePath := filepath.Join(s.getUserStoragePath(userID), fmt.Sprintf("%s.json", articleID))
-	
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return fmt.Errorf("article not found: %s", articleID)
+// Names of the encrypted fields of article content, bound to their
+// ciphertext as associated data
+const (
+	fieldContent       = "content"
+	fieldSummary       = "summary"
+	fieldTitle         = "title"
+	fieldURL           = "url"
+	fieldDescription   = "description"
+	fieldPrivateFields = "private_fields"
+)
+
+// contentField is an encrypted field of article content
+type contentField struct {
+	name  string
+	value *string
+}
+
+// encryptedFields returns the fields of content that are encrypted. The
+// summary and description are only encrypted when present.
+func encryptedFields(content *ArticleContent) []contentField {
+	fields := []contentField{{fieldContent, &content.Content}}
+	if content.Summary != "" {
+		fields = append(fields, contentField{fieldSummary, &content.Summary})
+	}
+	if content.FullPrivacy {
+		fields = append(fields, contentField{fieldTitle, &content.Title}, contentField{fieldURL, &content.URL})
+		if content.Description != "" {
+			fields = append(fields, contentField{fieldDescription, &content.Description})
+		}
+		fields = append(fields, contentField{fieldPrivateFields, &content.PrivateFields})
 	}
+	return fields
+}
+
+// privateFields holds the structured fields of a full-privacy article,
+// encrypted together as PrivateFields
+type privateFields struct {
+	Tags     []string          `json:"tags,omitempty"`
+	Category string            `json:"category,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
 
-	// Delete file
-	if err := os.Remove(filePath); err != nil {
-		return fmt.Errorf("failed to delete file: %w", err)
+// packPrivateFields moves the tags, category and metadata of a full-privacy
+// article into PrivateFields before it is encrypted
+func packPrivateFields(content *ArticleContent) error {
+	packed, err := json.Marshal(privateFields{
+		Tags:     content.Tags,
+		Category: content.Category,
+		Metadata: content.Metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to serialize private fields: %w", err)
 	}
 
-	s.logger.WithFields(logrus.Fields{
-		"user_id":    userID,
-		"article_id": articleID,
-		"file_path":  filePath,
-	}).Info("Content deleted successfully")
+	content.PrivateFields = string(packed)
+	content.Tags = nil
+	content.Category = ""
+	content.Metadata = nil
+	return nil
+}
+
+// unpackPrivateFields restores the fields packed by packPrivateFields after
+// decryption
+func unpackPrivateFields(content *ArticleContent) error {
+	var fields privateFields
+	if err := json.Unmarshal([]byte(content.PrivateFields), &fields); err != nil {
+		return fmt.Errorf("failed to parse private fields: %w", err)
+	}
 
+	content.Tags = fields.Tags
+	content.Category = fields.Category
+	content.Metadata = fields.Metadata
+	content.PrivateFields = ""
 	return nil
 }
 
-// ListUserContent lists all articles for a user
-func (s *Service) ListUserContent(userID string) ([]ArticleContent, error) {
-	userDir := s.getUserStoragePath(userID)
-	
-	// Check if user directory exists
-	if _, err := os.Stat(userDir); os.IsNotExist(err) {
-		return []ArticleContent{}, nil
+// fieldAAD binds an encrypted field to its user, article and field name, so
+// it cannot be copied into another field, article or user's content
+func fieldAAD(userID, articleID, field string) []byte {
+	return []byte(userID + "/" + articleID + "/" + field)
+}
+
+// boundAAD returns the associated data to open a field with. Fields
+// encrypted before associated data was bound were sealed without any.
+func boundAAD(userID, articleID, field, ciphertext string) []byte {
+	if !encryption.HasAssociatedData(ciphertext) {
+		return nil
 	}
+	return fieldAAD(userID, articleID, field)
+}
 
-	var articles []ArticleContent
-	
-	err := filepath.WalkDir(userDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
+// encryptFields encrypts the fields of an article under a new data key
+// wrapped by the user's key-encryption key
+func (s *Service) encryptFields(userID, articleID string, content *ArticleContent, userKey string) error {
+	kek, err := encryption.DeriveUserKEK(userKey, userID)
+	if err != nil {
+		return fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	// Every article is encrypted under its own data key
+	dataKey, err := encryption.GenerateDataKey()
+	if err != nil {
+		return err
+	}
+	wrappedKey, err := s.encryption.WrapKey(kek, dataKey, dataKeyAAD(userID, articleID))
+	if err != nil {
+		return fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	if content.FullPrivacy {
+		if err := packPrivateFields(content); err != nil {
 			return err
 		}
-		
-		if d.IsDir() || filepath.Ext(path) != ".json" {
-			return nil
+	}
+
+	for _, field := range encryptedFields(content) {
+		if err := s.sealField(userID, articleID, field, dataKey); err != nil {
+			return err
 		}
+	}
+
+	content.IsEncrypted = true
+	content.WrappedKey = wrappedKey
+	return nil
+}
+
+// sealField encrypts a field in place under a data key, bound to its
+// article and name
+func (s *Service) sealField(userID, articleID string, field contentField, dataKey []byte) error {
+	encrypted, err := s.encryption.EncryptWithKey(*field.value, dataKey, fieldAAD(userID, articleID, field.name))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", field.name, err)
+	}
+	*field.value = encrypted
+	return nil
+}
 
-		contentBytes, err := os.ReadFile(path)
+// decryptFields decrypts the fields of an encrypted article in place
+func (s *Service) decryptFields(userID, articleID string, content *ArticleContent, userKey string) error {
+	decrypt, err := s.decrypter(userID, articleID, content, userKey)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range encryptedFields(content) {
+		decrypted, err := decrypt(field.name, *field.value)
 		if err != nil {
-			s.logger.WithError(err).WithField("file_path", path).Warn("Failed to read article file")
-			return nil
+			return fmt.Errorf("failed to decrypt %s: %w", field.name, err)
 		}
+		*field.value = decrypted
+	}
 
-		var article ArticleContent
-		if err := json.Unmarshal(contentBytes, &article); err != nil {
-			s.logger.WithError(err).WithField("file_path", path).Warn("Failed to parse article file")
-			return nil
+	if content.FullPrivacy {
+		if err := unpackPrivateFields(content); err != nil {
+			return err
 		}
+	}
 
-		articles = append(articles, article)
-		return nil
-	})
+	content.IsEncrypted = false
+	content.ServerEncrypted = false
+	content.WrappedKey = ""
+
+	return nil
+}
 
+// decrypter returns a function decrypting the fields of encrypted content.
+// Content with a wrapped data key is decrypted under the unwrapped key;
+// older content is decrypted under the user key directly.
+func (s *Service) decrypter(userID, articleID string, content *ArticleContent, userKey string) (func(field, data string) (string, error), error) {
+	if content.WrappedKey == "" {
+		return func(field, data string) (string, error) {
+			return s.encryption.DecryptWithAAD(data, userKey, boundAAD(userID, articleID, field, data))
+		}, nil
+	}
+
+	dataKey, err := s.unwrapDataKey(userID, articleID, content, userKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list user content: %w", err)
+		return nil, err
 	}
 
-	return articles, nil
+	return func(field, data string) (string, error) {
+		return s.encryption.DecryptWithKey(data, dataKey, boundAAD(userID, articleID, field, data))
+	}, nil
 }
 
-// BackupToCloud backs up content to cloud storage
-func (s *Service) BackupToCloud(userID, articleID string) error {
-	// Get content
-	content, err := s.GetContent(userID, articleID)
+// dataKeyAAD binds a wrapped data key to its article
+func dataKeyAAD(userID, articleID string) []byte {
+	return []byte(userID + "/" + articleID)
+}
+
+// DeleteContent deletes an article's content in either layout and its
+// assets
+func (s *Service) DeleteContent(userID, articleID string) error {
+	keys, err := ArticleKeysFor(userID, articleID)
 	if err != nil {
-		return fmt.Errorf("failed to get content for backup: %w", err)
+		return err
 	}
 
-	// For now, this is a placeholder for cloud backup
-	// In a real implementation, this would upload to AWS S3, Google Cloud Storage, etc.
-	s.logger.WithFields(logrus.Fields{
-		"user_id":    userID,
-		"article_id": articleID,
-		"content_size": len(content),
-	}).Info("Cloud backup initiated (placeholder)")
+	found := false
+	for _, key := range keys.contentKeys() {
+		if _, err := s.backend.Stat(key); errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		found = true
 
-	return nil
-}
+		if err := s.backend.Delete(key); err != nil {
+			return fmt.Errorf("failed to delete content: %w", err)
+		}
+	}
+
+	assets, err := s.backend.List(keys.Assets)
+	if err != nil {
+		return fmt.Errorf("failed to list assets: %w", err)
+	}
+	for _, asset := range assets {
+		if err := s.backend.Delete(asset.Key); err != nil {
+			return fmt.Errorf("failed to delete asset: %w", err)
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("%w: %s", ErrContentNotFound, articleID)
+	}
 
-// RestoreFromCloud restores content from cloud storage
-func (s *Service) RestoreFromCloud(userID, articleID string) error {
-	// For now, this is a placeholder for cloud restore
-	// In a real implementation, this would download from cloud storage
 	s.logger.WithFields(logrus.Fields{
 		"user_id":    userID,
 		"article_id": articleID,
-	}).Info("Cloud restore initiated (placeholder)")
+	}).Info("Content deleted successfully")
 
 	return nil
 }
 
-// GetStorageStats returns storage statistics for a user
-func (s *Service) GetStorageStats(userID string) (map[string]interface{}, error) {
-	userDir := s.getUserStoragePath(userID)
-	
-	stats := map[string]interface{}{
-		"total_articles": 0,
-		"total_size":     int64(0),
-		"encrypted_articles": 0,
+// MoveContent moves an article's content file to the current layout
+// without decoding it and returns its key. It is safe to repeat after an
+// interruption: content already in the current layout is left alone.
+func (s *Service) MoveContent(userID, articleID string) (string, error) {
+	keys, err := ArticleKeysFor(userID, articleID)
+	if err != nil {
+		return "", err
+	}
+	key, flatKey := keys.Content, keys.Flat
+
+	stored, err := readAll(s.backend, flatKey)
+	if errors.Is(err, ErrNotFound) {
+		if _, err := s.backend.Stat(key); errors.Is(err, ErrNotFound) {
+			return "", fmt.Errorf("%w: %s", ErrContentNotFound, articleID)
+		} else if err != nil {
+			return "", err
+		}
+		return key, nil
+	}
+	if err != nil {
+		return "", err
 	}
 
-	if _, err := os.Stat(userDir); os.IsNotExist(err) {
-		return stats, nil
+	// The new file is written before the old one is removed so the content
+	// stays readable throughout
+	if err := s.backend.Put(key, bytes.NewReader(stored), int64(len(stored))); err != nil {
+		return "", fmt.Errorf("failed to write content: %w", err)
+	}
+	if err := s.backend.Delete(flatKey); err != nil {
+		return "", fmt.Errorf("failed to delete previous content: %w", err)
 	}
 
-	err := filepath.WalkDir(userDir, func(path string, d fs.DirEntry, err error) error {
+	return key, nil
+}
+
+// readContent reads an article's stored content, preferring the current
+// layout, and returns the key it was read from
+func (s *Service) readContent(userID, articleID string) (string, []byte, error) {
+	keys, err := ArticleKeysFor(userID, articleID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, key := range keys.contentKeys() {
+		stored, err := readAll(s.backend, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
 		if err != nil {
-			return err
+			return "", nil, err
 		}
-		
-		if d.IsDir() || filepath.Ext(path) != ".json" {
-			return nil
+		return key, stored, nil
+	}
+
+	return "", nil, fmt.Errorf("%w: %s", ErrContentNotFound, articleID)
+}
+
+// ListUserContent lists all articles for a user in either layout
+func (s *Service) ListUserContent(userID string) ([]ArticleContent, error) {
+	prefix, err := UserPrefix(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := s.backend.List(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user content: %w", err)
+	}
+
+	// A move interrupted by a crash can leave an article in both layouts,
+	// in which case the current layout wins
+	keys := map[string]string{}
+	for _, object := range objects {
+		location, ok := ParseContentKey(object.Key)
+		if !ok || location.Asset {
+			continue
+		}
+		if _, seen := keys[location.ArticleID]; !seen || location.Layout == CurrentLayout {
+			keys[location.ArticleID] = object.Key
 		}
+	}
 
-		info, err := d.Info()
-		if err != nil {
-			return nil
+	articles := []ArticleContent{}
+	for _, object := range objects {
+		location, ok := ParseContentKey(object.Key)
+		if !ok || keys[location.ArticleID] != object.Key {
+			continue
 		}
 
-		stats["total_articles"] = stats["total_articles"].(int) + 1
-		stats["total_size"] = stats["total_size"].(int64) + info.Size()
+		stored, err := readAll(s.backend, object.Key)
+		if err != nil {
+			s.logger.WithError(err).WithField("key", object.Key).Warn("Failed to read article content")
+			continue
+		}
 
-		// Check if encrypted
-		contentBytes, err := os.ReadFile(path)
+		contentBytes, err := DecodeContent(s.backend, stored)
 		if err != nil {
-			return nil
+			s.logger.WithError(err).WithField("key", object.Key).Warn("Failed to decode article content")
+			continue
 		}
 
 		var article ArticleContent
 		if err := json.Unmarshal(contentBytes, &article); err != nil {
-			return nil
+			s.logger.WithError(err).WithField("key", object.Key).Warn("Failed to parse article content")
+			continue
 		}
 
-		if article.IsEncrypted {
-			stats["encrypted_articles"] = stats["encrypted_articles"].(int) + 1
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to calculate storage stats: %w", err)
+		articles = append(articles, article)
 	}
 
-	return stats, nil
+	return articles, nil
 }
-
-// getUserStoragePath returns the storage path for a user
-func (s *Service) getUserStoragePath(userID string) string {
-	return filepath.Join(s.config.LocalStoragePath, "users", userID)
-} 
\ No newline at end of file
@@ -0,0 +1,349 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/config"
+	"github.com/readitlater/backend/internal/encryption"
+)
+
+func TestEncryptedContent(t *testing.T) {
+	backend, err := NewLocalBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local backend: %v", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	service := NewService(&config.Config{EncryptionAlgorithm: encryption.AlgorithmAES256GCM, KeyDerivationIterations: 1000}, backend, logger)
+
+	userID := uuid.New().String()
+	userKey, _ := service.encryption.GenerateUserKey()
+	content := `{"title":"Secret","content":"secret body","summary":"secret summary"}`
+
+	save := func(t *testing.T, articleID string) *ArticleContent {
+		t.Helper()
+		if _, err := service.SaveEncryptedContent(userID, articleID, content, userKey); err != nil {
+			t.Fatalf("Failed to save encrypted content: %v", err)
+		}
+		stored, err := service.GetContent(userID, articleID)
+		if err != nil {
+			t.Fatalf("Failed to read content: %v", err)
+		}
+		var articleContent ArticleContent
+		json.Unmarshal([]byte(stored), &articleContent)
+		return &articleContent
+	}
+
+	t.Run("WrappedDataKey", func(t *testing.T) {
+		articleID := uuid.New().String()
+		stored := save(t, articleID)
+		if stored.WrappedKey == "" || strings.Contains(stored.Content, "secret") {
+			t.Fatalf("Expected content encrypted under a wrapped data key, got %+v", stored)
+		}
+		if other := save(t, uuid.New().String()); other.WrappedKey == stored.WrappedKey {
+			t.Error("Articles should have their own data keys")
+		}
+
+		decrypted, err := service.GetDecryptedContent(userID, articleID, userKey)
+		if err != nil {
+			t.Fatalf("Failed to decrypt content: %v", err)
+		}
+		if !strings.Contains(decrypted, `"secret body"`) || !strings.Contains(decrypted, `"secret summary"`) || strings.Contains(decrypted, "wrapped_key") {
+			t.Errorf("Unexpected decrypted content: %s", decrypted)
+		}
+	})
+
+	t.Run("WrongKey", func(t *testing.T) {
+		articleID := uuid.New().String()
+		save(t, articleID)
+
+		otherKey, _ := service.encryption.GenerateUserKey()
+		if _, err := service.GetDecryptedContent(userID, articleID, otherKey); !errors.Is(err, encryption.ErrInvalidKey) {
+			t.Errorf("Expected ErrInvalidKey, got %v", err)
+		}
+	})
+
+	t.Run("WrappedKeyBoundToArticle", func(t *testing.T) {
+		articleID, otherID := uuid.New().String(), uuid.New().String()
+		stored := save(t, articleID)
+		other := save(t, otherID)
+
+		// Swap in another article's wrapped key
+		other.WrappedKey = stored.WrappedKey
+		data, _ := json.Marshal(other)
+		if _, err := service.WriteContent(userID, otherID, data); err != nil {
+			t.Fatalf("Failed to write content: %v", err)
+		}
+		if _, err := service.GetDecryptedContent(userID, otherID, userKey); err == nil {
+			t.Error("Expected a wrapped key copied from another article to fail")
+		}
+	})
+
+	t.Run("FieldsBoundToArticle", func(t *testing.T) {
+		articleID := uuid.New().String()
+		stored := save(t, articleID)
+
+		// Swap the encrypted content and summary
+		stored.Content, stored.Summary = stored.Summary, stored.Content
+		data, _ := json.Marshal(stored)
+		if _, err := service.WriteContent(userID, articleID, data); err != nil {
+			t.Fatalf("Failed to write content: %v", err)
+		}
+		if _, err := service.GetDecryptedContent(userID, articleID, userKey); err == nil {
+			t.Error("Expected swapped fields to fail")
+		}
+	})
+
+	t.Run("FullPrivacy", func(t *testing.T) {
+		articleID := uuid.New().String()
+		private := `{"title":"Secret","url":"https://example.com/secret","description":"secret description",` +
+			`"category":"secret category","tags":["secret"],"metadata":{"author":"secret"},"content":"secret body","full_privacy":true}`
+
+		encoded, err := service.EncodeEncryptedContent(userID, articleID, private, userKey)
+		if err != nil {
+			t.Fatalf("Failed to encode content: %v", err)
+		}
+		if strings.Contains(string(encoded), "secret") {
+			t.Fatalf("Expected every sensitive field to be encrypted, got %s", encoded)
+		}
+		if _, err := service.WriteContent(userID, articleID, encoded); err != nil {
+			t.Fatalf("Failed to write content: %v", err)
+		}
+
+		decrypted, err := service.GetDecryptedContent(userID, articleID, userKey)
+		if err != nil {
+			t.Fatalf("Failed to decrypt content: %v", err)
+		}
+		var articleContent ArticleContent
+		json.Unmarshal([]byte(decrypted), &articleContent)
+		if articleContent.Title != "Secret" || articleContent.URL != "https://example.com/secret" ||
+			articleContent.Description != "secret description" || articleContent.Category != "secret category" ||
+			len(articleContent.Tags) != 1 || articleContent.Metadata["author"] != "secret" || articleContent.PrivateFields != "" {
+			t.Errorf("Unexpected decrypted content: %s", decrypted)
+		}
+	})
+
+	t.Run("ContentWithoutDataKey", func(t *testing.T) {
+		articleID := uuid.New().String()
+		encrypted, err := service.encryption.Encrypt("old body", userKey)
+		if err != nil {
+			t.Fatalf("Failed to encrypt: %v", err)
+		}
+		data, _ := json.Marshal(ArticleContent{Title: "Old", Content: encrypted, IsEncrypted: true})
+		if _, err := service.WriteContent(userID, articleID, data); err != nil {
+			t.Fatalf("Failed to write content: %v", err)
+		}
+
+		decrypted, err := service.GetDecryptedContent(userID, articleID, userKey)
+		if err != nil || !strings.Contains(decrypted, `"old body"`) {
+			t.Errorf("Expected content encrypted under the user key to decrypt, got %q, %v", decrypted, err)
+		}
+	})
+
+	t.Run("ServerEncrypted", func(t *testing.T) {
+		articleID := uuid.New().String()
+		serverKey, _ := service.encryption.GenerateUserKey()
+		data, err := service.EncodeServerEncryptedContent(userID, articleID, content, serverKey)
+		if err != nil {
+			t.Fatalf("Failed to encode content: %v", err)
+		}
+		if _, err := service.WriteContent(userID, articleID, data); err != nil {
+			t.Fatalf("Failed to write content: %v", err)
+		}
+
+		var stored ArticleContent
+		json.Unmarshal(data, &stored)
+		if stored.IsEncrypted || !stored.ServerEncrypted || strings.Contains(string(data), "secret body") {
+			t.Errorf("Expected server-encrypted content, got %s", data)
+		}
+
+		decrypted, err := service.GetDecryptedContent(userID, articleID, serverKey)
+		if err != nil || !strings.Contains(decrypted, `"secret body"`) || strings.Contains(decrypted, "server_encrypted") {
+			t.Errorf("Expected decrypted content, got %q, %v", decrypted, err)
+		}
+		if _, err := service.GetDecryptedContent(userID, articleID, userKey); !errors.Is(err, encryption.ErrInvalidKey) {
+			t.Errorf("Expected ErrInvalidKey, got %v", err)
+		}
+	})
+
+	t.Run("EncryptedAssets", func(t *testing.T) {
+		articleID := uuid.New().String()
+		save(t, articleID)
+		asset := strings.Repeat("pdf data ", 20000)
+
+		if _, err := service.WriteEncryptedAsset(userID, articleID, "file.pdf", strings.NewReader(asset), int64(len(asset)), userKey); err != nil {
+			t.Fatalf("Failed to write asset: %v", err)
+		}
+		keys, _ := ArticleKeysFor(userID, articleID)
+		stored, _ := readAll(backend, keys.Assets+"file.pdf")
+		if strings.Contains(string(stored), "pdf data") {
+			t.Error("Expected the stored asset to be encrypted")
+		}
+
+		readerAt, err := service.EncryptedAssetReaderAt(userID, articleID, "file.pdf", userKey)
+		if err != nil {
+			t.Fatalf("Failed to open asset: %v", err)
+		}
+		data, err := io.ReadAll(io.NewSectionReader(readerAt, 0, readerAt.Size()))
+		if err != nil || string(data) != asset {
+			t.Errorf("Expected the asset back, got %d bytes, %v", len(data), err)
+		}
+		part := make([]byte, 100)
+		if _, err := readerAt.ReadAt(part, 70000); err != nil || string(part) != asset[70000:70100] {
+			t.Errorf("Unexpected range read: %q, %v", part, err)
+		}
+
+		// An asset copied to another name does not open
+		backend.Put(keys.Assets+"copy.pdf", strings.NewReader(string(stored)), int64(len(stored)))
+		if copied, err := service.EncryptedAssetReaderAt(userID, articleID, "copy.pdf", userKey); err == nil {
+			if _, err := copied.ReadAt(part, 0); err == nil {
+				t.Error("Expected a renamed asset to fail")
+			}
+		}
+
+		otherKey, _ := service.encryption.GenerateUserKey()
+		if _, err := service.EncryptedAssetReaderAt(userID, articleID, "file.pdf", otherKey); !errors.Is(err, encryption.ErrInvalidKey) {
+			t.Errorf("Expected ErrInvalidKey, got %v", err)
+		}
+		if _, err := service.WriteEncryptedAsset(userID, articleID, "../content.json", strings.NewReader("x"), 1, userKey); !errors.Is(err, ErrInvalidAssetName) {
+			t.Errorf("Expected ErrInvalidAssetName, got %v", err)
+		}
+	})
+
+	t.Run("EncryptAsset", func(t *testing.T) {
+		articleID := uuid.New().String()
+		save(t, articleID)
+		asset := strings.Repeat("image data ", 10000)
+
+		if _, err := service.WriteAsset(userID, articleID, "cover.png", strings.NewReader(asset), int64(len(asset))); err != nil {
+			t.Fatalf("Failed to write asset: %v", err)
+		}
+		if encrypted, err := service.AssetEncrypted(userID, articleID, "cover.png", userKey); err != nil || encrypted {
+			t.Errorf("Expected a plain asset, got %v, %v", encrypted, err)
+		}
+
+		info, err := service.EncryptAsset(userID, articleID, "cover.png", userKey)
+		if err != nil {
+			t.Fatalf("Failed to encrypt asset: %v", err)
+		}
+		if size, _ := service.EncryptedAssetSize(int64(len(asset))); info.Size != size {
+			t.Errorf("Expected a stored size of %d, got %d", size, info.Size)
+		}
+		if encrypted, err := service.AssetEncrypted(userID, articleID, "cover.png", userKey); err != nil || !encrypted {
+			t.Errorf("Expected an encrypted asset, got %v, %v", encrypted, err)
+		}
+
+		readerAt, err := service.EncryptedAssetReaderAt(userID, articleID, "cover.png", userKey)
+		if err != nil {
+			t.Fatalf("Failed to open asset: %v", err)
+		}
+		data, err := io.ReadAll(io.NewSectionReader(readerAt, 0, readerAt.Size()))
+		if err != nil || string(data) != asset {
+			t.Errorf("Expected the asset back, got %d bytes, %v", len(data), err)
+		}
+
+		names, err := service.ListAssets(userID, articleID)
+		if err != nil || len(names) != 1 || names[0] != "cover.png" {
+			t.Errorf("Expected the asset to be listed, got %v, %v", names, err)
+		}
+	})
+
+	t.Run("RekeyContent", func(t *testing.T) {
+		newKey, _ := service.encryption.GenerateUserKey()
+		rekey := func(t *testing.T, articleID string) {
+			t.Helper()
+			stored, _ := service.GetContent(userID, articleID)
+			rekeyed, err := service.RekeyContent(userID, articleID, []byte(stored), userKey, newKey)
+			if err != nil || rekeyed == nil {
+				t.Fatalf("Failed to rekey content: %v", err)
+			}
+			if _, err := service.WriteContent(userID, articleID, rekeyed); err != nil {
+				t.Fatalf("Failed to write content: %v", err)
+			}
+
+			if _, err := service.GetDecryptedContent(userID, articleID, userKey); err == nil {
+				t.Error("Expected the old key to stop working")
+			}
+			decrypted, err := service.GetDecryptedContent(userID, articleID, newKey)
+			if err != nil || !strings.Contains(decrypted, "body") {
+				t.Errorf("Expected the new key to work, got %q, %v", decrypted, err)
+			}
+
+			// Repeating an interrupted rotation leaves rotated content alone
+			stored, _ = service.GetContent(userID, articleID)
+			if rekeyed, err := service.RekeyContent(userID, articleID, []byte(stored), userKey, newKey); err != nil || rekeyed != nil {
+				t.Errorf("Expected rotated content to be skipped, got %v", err)
+			}
+		}
+
+		t.Run("WrappedDataKey", func(t *testing.T) {
+			articleID := uuid.New().String()
+			before := save(t, articleID)
+			rekey(t, articleID)
+
+			after, _ := service.GetContent(userID, articleID)
+			if !strings.Contains(after, before.Content) {
+				t.Error("Rewrapping should not re-encrypt the content")
+			}
+		})
+
+		t.Run("ContentWithoutDataKey", func(t *testing.T) {
+			articleID := uuid.New().String()
+			encrypted, _ := service.encryption.Encrypt("old body", userKey)
+			data, _ := json.Marshal(ArticleContent{Content: encrypted, IsEncrypted: true})
+			if _, err := service.WriteContent(userID, articleID, data); err != nil {
+				t.Fatalf("Failed to write content: %v", err)
+			}
+			rekey(t, articleID)
+		})
+
+		t.Run("UnboundFields", func(t *testing.T) {
+			articleID := uuid.New().String()
+			stored := save(t, articleID)
+
+			// Fields sealed before associated data was bound
+			dataKey, _ := service.unwrapDataKey(userID, articleID, stored, userKey)
+			stored.Content, _ = service.encryption.EncryptWithKey("unbound body", dataKey, nil)
+			stored.Summary = ""
+			data, _ := json.Marshal(stored)
+			if _, err := service.WriteContent(userID, articleID, data); err != nil {
+				t.Fatalf("Failed to write content: %v", err)
+			}
+			if _, err := service.GetDecryptedContent(userID, articleID, userKey); err != nil {
+				t.Fatalf("Expected unbound fields to stay readable: %v", err)
+			}
+
+			// The same key only upgrades the format
+			rekeyed, err := service.RekeyContent(userID, articleID, data, userKey, userKey)
+			if err != nil || rekeyed == nil {
+				t.Fatalf("Failed to upgrade content: %v", err)
+			}
+			var upgraded ArticleContent
+			json.Unmarshal(rekeyed, &upgraded)
+			if upgraded.WrappedKey != stored.WrappedKey || !encryption.HasAssociatedData(upgraded.Content) {
+				t.Error("Expected only the fields to be re-encrypted with associated data")
+			}
+			if again, err := service.RekeyContent(userID, articleID, rekeyed, userKey, userKey); err != nil || again != nil {
+				t.Errorf("Expected upgraded content to be left alone, got %v", err)
+			}
+		})
+
+		t.Run("WrongOldKey", func(t *testing.T) {
+			articleID := uuid.New().String()
+			save(t, articleID)
+			stored, _ := service.GetContent(userID, articleID)
+
+			otherKey, _ := service.encryption.GenerateUserKey()
+			if _, err := service.RekeyContent(userID, articleID, []byte(stored), otherKey, newKey); err == nil {
+				t.Error("Expected rekeying with the wrong old key to fail")
+			}
+		})
+	})
+}
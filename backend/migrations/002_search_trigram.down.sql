@@ -0,0 +1,10 @@
+-- Drop search indexes
+DROP INDEX IF EXISTS idx_articles_search_document;
+DROP INDEX IF EXISTS idx_articles_tags_trgm;
+DROP INDEX IF EXISTS idx_articles_url_trgm;
+DROP INDEX IF EXISTS idx_articles_site_name_trgm;
+DROP INDEX IF EXISTS idx_articles_author_trgm;
+DROP INDEX IF EXISTS idx_articles_title_trgm;
+
+-- Drop extensions
+DROP EXTENSION IF EXISTS pg_trgm;
@@ -0,0 +1,17 @@
+-- Enable trigram matching for fuzzy search
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Trigram indexes for typo-tolerant matching on short text fields
+CREATE INDEX idx_articles_title_trgm ON articles USING GIN(title gin_trgm_ops);
+CREATE INDEX idx_articles_author_trgm ON articles USING GIN(author gin_trgm_ops);
+CREATE INDEX idx_articles_site_name_trgm ON articles USING GIN(site_name gin_trgm_ops);
+CREATE INDEX idx_articles_url_trgm ON articles USING GIN(url gin_trgm_ops);
+CREATE INDEX idx_articles_tags_trgm ON articles USING GIN((tags::text) gin_trgm_ops);
+
+-- Full-text index covering the fields searched together with trigram matches
+CREATE INDEX idx_articles_search_document ON articles USING GIN(
+    to_tsvector('english',
+        coalesce(title, '') || ' ' ||
+        coalesce(description, '') || ' ' ||
+        coalesce(content_text, ''))
+);
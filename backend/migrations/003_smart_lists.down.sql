@@ -0,0 +1,8 @@
+-- Drop triggers
+DROP TRIGGER IF EXISTS update_smart_lists_updated_at ON smart_lists;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_smart_lists_user_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS smart_lists;
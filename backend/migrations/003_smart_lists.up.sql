@@ -0,0 +1,21 @@
+-- Create smart_lists table for saved searches
+CREATE TABLE smart_lists (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    query TEXT NOT NULL DEFAULT '',
+    filter JSONB NOT NULL DEFAULT '{}',
+    sort_by VARCHAR(20) NOT NULL DEFAULT '',
+    sort_order VARCHAR(4) NOT NULL DEFAULT '',
+    position INTEGER NOT NULL DEFAULT 0,
+    is_pinned BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE (user_id, name)
+);
+
+CREATE INDEX idx_smart_lists_user_id ON smart_lists(user_id, is_pinned DESC, position);
+
+CREATE TRIGGER update_smart_lists_updated_at BEFORE UPDATE ON smart_lists
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,8 @@
+-- Drop tables
+DROP TABLE IF EXISTS encrypted_search_indexes;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_articles_blind_index;
+
+-- Drop columns
+ALTER TABLE articles DROP COLUMN IF EXISTS blind_index;
@@ -0,0 +1,15 @@
+-- Blind index tokens uploaded by clients for encrypted articles
+ALTER TABLE articles ADD COLUMN blind_index JSONB DEFAULT '[]';
+
+CREATE INDEX idx_articles_blind_index ON articles USING GIN(blind_index jsonb_path_ops);
+
+-- Client-encrypted per-user search index blobs, one row per version
+CREATE TABLE encrypted_search_indexes (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    blob BYTEA NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (user_id, version)
+);
@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_backups_running;
+DROP INDEX IF EXISTS idx_backups_user_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS backups;
@@ -0,0 +1,19 @@
+-- Create backups table recording cloud backup runs
+CREATE TABLE backups (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    snapshot_id VARCHAR(40),
+    status VARCHAR(20) NOT NULL DEFAULT 'running',
+    object_count INTEGER NOT NULL DEFAULT 0,
+    total_size BIGINT NOT NULL DEFAULT 0,
+    uploaded_count INTEGER NOT NULL DEFAULT 0,
+    uploaded_size BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX idx_backups_user_id ON backups(user_id, started_at DESC);
+
+-- At most one running backup per user
+CREATE UNIQUE INDEX idx_backups_running ON backups(user_id) WHERE status = 'running';
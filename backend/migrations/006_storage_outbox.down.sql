@@ -0,0 +1,5 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_storage_outbox_article_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS storage_outbox;
@@ -0,0 +1,14 @@
+-- Pending storage writes and deletes, recorded in the same transaction as
+-- the article change and replayed until they succeed
+CREATE TABLE storage_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    article_id UUID NOT NULL,
+    operation VARCHAR(10) NOT NULL,
+    payload BYTEA,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_storage_outbox_article_id ON storage_outbox(article_id, id);
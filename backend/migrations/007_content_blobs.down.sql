@@ -0,0 +1,8 @@
+-- Drop columns
+ALTER TABLE articles DROP COLUMN IF EXISTS content_hash;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_content_blobs_unreferenced;
+
+-- Drop tables
+DROP TABLE IF EXISTS content_blobs;
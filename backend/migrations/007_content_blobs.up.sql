@@ -0,0 +1,15 @@
+-- Shared article bodies stored once under blobs/ and referenced by every
+-- article whose unencrypted content is identical
+CREATE TABLE content_blobs (
+    hash VARCHAR(64) PRIMARY KEY,
+    size BIGINT NOT NULL,
+    ref_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Unreferenced blobs are collected by the garbage collector
+CREATE INDEX idx_content_blobs_unreferenced ON content_blobs(updated_at) WHERE ref_count <= 0;
+
+-- The blob an article's content file refers to, if any
+ALTER TABLE articles ADD COLUMN content_hash VARCHAR(64);
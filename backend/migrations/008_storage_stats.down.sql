@@ -0,0 +1,12 @@
+-- Drop triggers
+DROP TRIGGER IF EXISTS update_storage_stats_content ON articles;
+
+-- Drop functions
+DROP FUNCTION IF EXISTS update_storage_stats_content();
+DROP FUNCTION IF EXISTS add_storage_stats(UUID, INTEGER, INTEGER, BIGINT, BIGINT);
+
+-- Drop tables
+DROP TABLE IF EXISTS storage_stats;
+
+-- Drop columns
+ALTER TABLE articles DROP COLUMN IF EXISTS stored_size;
@@ -0,0 +1,78 @@
+-- Compressed size of each article's own content file, recorded after it is written
+ALTER TABLE articles ADD COLUMN stored_size BIGINT NOT NULL DEFAULT 0;
+
+-- Per-user storage counters, maintained incrementally by a trigger on
+-- articles. Rows are not tied to users so the trigger can run while a user's
+-- articles are deleted in cascade; rows of deleted users are removed when
+-- the counters are recomputed.
+CREATE TABLE storage_stats (
+    user_id UUID PRIMARY KEY,
+    articles INTEGER NOT NULL DEFAULT 0,
+    encrypted_articles INTEGER NOT NULL DEFAULT 0,
+    logical_size BIGINT NOT NULL DEFAULT 0,
+    physical_size BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create function to apply an article's contribution to storage_stats
+CREATE OR REPLACE FUNCTION add_storage_stats(
+    stats_user_id UUID, article_count INTEGER, encrypted_count INTEGER, logical BIGINT, physical BIGINT
+) RETURNS VOID AS $$
+BEGIN
+    INSERT INTO storage_stats (user_id, articles, encrypted_articles, logical_size, physical_size)
+    VALUES (stats_user_id, article_count, encrypted_count, logical, physical)
+    ON CONFLICT (user_id) DO UPDATE SET
+        articles = storage_stats.articles + EXCLUDED.articles,
+        encrypted_articles = storage_stats.encrypted_articles + EXCLUDED.encrypted_articles,
+        logical_size = storage_stats.logical_size + EXCLUDED.logical_size,
+        physical_size = storage_stats.physical_size + EXCLUDED.physical_size,
+        updated_at = CURRENT_TIMESTAMP;
+END;
+$$ language 'plpgsql';
+
+-- Create function to update storage_stats when articles with content change
+CREATE OR REPLACE FUNCTION update_storage_stats_content()
+RETURNS TRIGGER AS $$
+BEGIN
+    -- Most updates (read state, tags...) do not touch storage
+    IF TG_OP = 'UPDATE'
+        AND OLD.user_id = NEW.user_id
+        AND OLD.local_path IS NOT DISTINCT FROM NEW.local_path
+        AND OLD.is_encrypted IS NOT DISTINCT FROM NEW.is_encrypted
+        AND OLD.storage_size IS NOT DISTINCT FROM NEW.storage_size
+        AND OLD.stored_size = NEW.stored_size THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.local_path IS NOT NULL THEN
+        PERFORM add_storage_stats(OLD.user_id, -1,
+            CASE WHEN coalesce(OLD.is_encrypted, false) THEN -1 ELSE 0 END,
+            -coalesce(OLD.storage_size, 0), -OLD.stored_size);
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.local_path IS NOT NULL THEN
+        PERFORM add_storage_stats(NEW.user_id, 1,
+            CASE WHEN coalesce(NEW.is_encrypted, false) THEN 1 ELSE 0 END,
+            coalesce(NEW.storage_size, 0), NEW.stored_size);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+-- Create trigger to keep storage_stats up to date. Triggers fire in name
+-- order, so update_storage_stats locks the users row before this one locks
+-- the storage_stats row, the same order RecomputeStorageStats uses.
+CREATE TRIGGER update_storage_stats_content AFTER INSERT OR UPDATE OR DELETE ON articles
+    FOR EACH ROW EXECUTE FUNCTION update_storage_stats_content();
+
+-- Seed the counters from existing articles
+INSERT INTO storage_stats (user_id, articles, encrypted_articles, logical_size, physical_size)
+SELECT user_id,
+    COUNT(*),
+    COUNT(*) FILTER (WHERE is_encrypted = true),
+    COALESCE(SUM(storage_size), 0),
+    0
+FROM articles
+WHERE local_path IS NOT NULL
+GROUP BY user_id;
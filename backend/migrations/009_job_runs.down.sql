@@ -0,0 +1,5 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_job_runs_job_name;
+
+-- Drop tables
+DROP TABLE IF EXISTS job_runs;
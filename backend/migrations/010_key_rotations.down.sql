@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_key_rotations_active;
+DROP INDEX IF EXISTS idx_key_rotations_user_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS key_rotations;
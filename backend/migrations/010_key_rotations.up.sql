@@ -0,0 +1,22 @@
+-- Create key_rotations table tracking user key rotation jobs. The keys
+-- themselves are never stored; a rotation resumes from last_article_id once
+-- the user supplies them again.
+CREATE TABLE key_rotations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'running',
+    total INTEGER NOT NULL DEFAULT 0,
+    rotated INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    last_article_id UUID,
+    run_token UUID NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX idx_key_rotations_user_id ON key_rotations(user_id, started_at DESC);
+
+-- At most one unfinished rotation per user
+CREATE UNIQUE INDEX idx_key_rotations_active ON key_rotations(user_id) WHERE status IN ('running', 'paused');
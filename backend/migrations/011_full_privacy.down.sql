@@ -0,0 +1,3 @@
+-- Drop columns
+ALTER TABLE articles DROP COLUMN IF EXISTS private_fields;
+ALTER TABLE articles DROP COLUMN IF EXISTS is_private;
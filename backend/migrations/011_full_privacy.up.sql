@@ -0,0 +1,4 @@
+-- Full-privacy articles store only ciphertext in title, url and description,
+-- with tags, category and metadata encrypted together in private_fields
+ALTER TABLE articles ADD COLUMN is_private BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE articles ADD COLUMN private_fields TEXT;
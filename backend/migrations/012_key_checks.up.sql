@@ -0,0 +1,8 @@
+-- Key check of each user's encryption key, recorded when they first encrypt
+-- content. It recognises the key without revealing it.
+CREATE TABLE encryption_key_checks (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    verifier TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
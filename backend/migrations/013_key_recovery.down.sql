@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_key_recovery_events_user_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS key_recovery_events;
+DROP TABLE IF EXISTS key_recoveries;
@@ -0,0 +1,24 @@
+-- User keys wrapped for recovery: by the user's recovery key, and optionally
+-- sealed to the organization escrow public key identified by escrow_key_id
+CREATE TABLE key_recoveries (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    wrapped_key TEXT NOT NULL,
+    escrowed_key TEXT,
+    escrow_key_id VARCHAR(32),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_tested_at TIMESTAMP,
+    last_used_at TIMESTAMP
+);
+
+-- Audit log of key recovery setup, tests and use
+CREATE TABLE key_recovery_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event VARCHAR(30) NOT NULL,
+    success BOOLEAN NOT NULL,
+    actor VARCHAR(255) NOT NULL DEFAULT '',
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_key_recovery_events_user_id ON key_recovery_events(user_id, created_at DESC);
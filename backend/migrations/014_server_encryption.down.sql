@@ -0,0 +1,3 @@
+-- Drop columns and tables
+ALTER TABLE articles DROP COLUMN IF EXISTS server_encrypted;
+DROP TABLE IF EXISTS server_encryption_keys;
@@ -0,0 +1,15 @@
+-- Server-managed key of each user who does not manage their own, wrapped
+-- by the master key identified by master_key_id
+CREATE TABLE server_encryption_keys (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    wrapped_key TEXT NOT NULL,
+    master_key_id TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_server_encryption_keys_master_key_id ON server_encryption_keys(master_key_id);
+
+-- Content encrypted at rest under the user's server-managed key, as
+-- opposed to is_encrypted content only the user's own key opens
+ALTER TABLE articles ADD COLUMN server_encrypted BOOLEAN NOT NULL DEFAULT false;
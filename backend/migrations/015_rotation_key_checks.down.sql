@@ -0,0 +1,2 @@
+-- Drop columns
+ALTER TABLE key_rotations DROP COLUMN IF EXISTS new_key_check;
@@ -0,0 +1,3 @@
+-- Key check of a rotation's new key. Until the rotation completes without
+-- failures both keys read content, and new content must use the new key.
+ALTER TABLE key_rotations ADD COLUMN new_key_check TEXT NOT NULL DEFAULT '';
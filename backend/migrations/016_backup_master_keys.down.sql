@@ -0,0 +1,2 @@
+-- Drop columns
+ALTER TABLE backups DROP COLUMN IF EXISTS master_key_id;
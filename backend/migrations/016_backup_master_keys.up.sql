@@ -0,0 +1,3 @@
+-- Master key a backup's snapshot is encrypted under, empty for backups
+-- made before it was recorded
+ALTER TABLE backups ADD COLUMN master_key_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,26 @@
+-- Stored size of each article's assets, counted against the user's quota
+ALTER TABLE articles ADD COLUMN assets_size BIGINT NOT NULL DEFAULT 0;
+
+-- Count assets in storage_used
+CREATE OR REPLACE FUNCTION update_user_storage_stats()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users
+        SET storage_used = storage_used + NEW.storage_size + NEW.assets_size
+        WHERE id = NEW.user_id;
+        RETURN NEW;
+    ELSIF TG_OP = 'UPDATE' THEN
+        UPDATE users
+        SET storage_used = storage_used - OLD.storage_size - OLD.assets_size + NEW.storage_size + NEW.assets_size
+        WHERE id = NEW.user_id;
+        RETURN NEW;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE users
+        SET storage_used = storage_used - OLD.storage_size - OLD.assets_size
+        WHERE id = OLD.user_id;
+        RETURN OLD;
+    END IF;
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
@@ -0,0 +1,2 @@
+-- Drop columns
+ALTER TABLE articles DROP COLUMN IF EXISTS client_encrypted;
@@ -0,0 +1,4 @@
+-- Whether an article's content was encrypted by a zero-knowledge client
+-- rather than by the server from a user key. Encrypted articles saved
+-- before this was recorded cannot be told apart and report user_key.
+ALTER TABLE articles ADD COLUMN client_encrypted BOOLEAN NOT NULL DEFAULT false;
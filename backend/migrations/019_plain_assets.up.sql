@@ -0,0 +1,4 @@
+-- Server-encrypted articles with assets that may still be stored as is,
+-- saved before the article was encrypted or restored from a backup, until
+-- the server encryption job encrypts them
+ALTER TABLE articles ADD COLUMN plain_assets BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,301 @@
+// Package zkclient implements the client half of the zero-knowledge
+// protocol. Articles are encrypted before they are uploaded and the
+// ciphertext returned by GET /articles/:id/content is decrypted locally, so
+// the user key never reaches the server. It depends only on the encryption
+// primitives and defines the requests and responses it works with, so it
+// serves as a reference for other clients and is used by tests and tools.
+package zkclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/readitlater/backend/internal/encryption"
+)
+
+// Names of the encrypted fields, bound to their ciphertext as associated
+// data
+const (
+	fieldContent       = "content"
+	fieldSummary       = "summary"
+	fieldTitle         = "title"
+	fieldURL           = "url"
+	fieldDescription   = "description"
+	fieldPrivateFields = "private_fields"
+)
+
+// Article is the plaintext of an article
+type Article struct {
+	ID          string
+	URL         string
+	Title       string
+	Description string
+	Content     string
+	Summary     string
+	Tags        []string
+	Category    string
+	Metadata    map[string]string
+	FullPrivacy bool // also encrypt title, URL, description, tags, category and metadata
+}
+
+// EncryptedContent is the ciphertext of an article, sent in and returned
+// as "encrypted". Every field is a base64 envelope sealed under the
+// article's data key, bound to the user ID, article ID and field name; the
+// data key is wrapped under a KEK derived from the user key. Title, URL,
+// description and private fields are only set for full-privacy articles.
+type EncryptedContent struct {
+	WrappedKey    string `json:"wrapped_key"`
+	Content       string `json:"content"`
+	Summary       string `json:"summary,omitempty"`
+	Title         string `json:"title,omitempty"`
+	URL           string `json:"url,omitempty"`
+	Description   string `json:"description,omitempty"`
+	PrivateFields string `json:"private_fields,omitempty"`
+}
+
+// CreateRequest is the body of POST /articles for an encrypted article
+type CreateRequest struct {
+	ID          string            `json:"id"`
+	URL         string            `json:"url,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Category    string            `json:"category,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Encrypted   *EncryptedContent `json:"encrypted"`
+	KeyCheck    string            `json:"key_check"`
+	FullPrivacy bool              `json:"full_privacy,omitempty"`
+}
+
+// ContentResponse is the body returned by GET /articles/:id/content. Only
+// the fields the client needs are decoded.
+type ContentResponse struct {
+	Article   ContentArticle    `json:"article"`
+	Content   string            `json:"content"`
+	Summary   string            `json:"summary,omitempty"`
+	Metadata  map[string]string `json:"metadata"`
+	Encrypted *EncryptedContent `json:"encrypted,omitempty"`
+}
+
+// ContentArticle is the article of a ContentResponse. The title, URL and
+// description of full-privacy articles hold ciphertext.
+type ContentArticle struct {
+	ID          string   `json:"id"`
+	URL         string   `json:"url"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Category    string   `json:"category"`
+	IsPrivate   bool     `json:"is_private"`
+}
+
+// privateFields holds the structured fields of a full-privacy article,
+// encrypted together as PrivateFields
+type privateFields struct {
+	Tags     []string          `json:"tags,omitempty"`
+	Category string            `json:"category,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Client encrypts and decrypts the articles of one user
+type Client struct {
+	userID     string
+	kek        []byte
+	keyCheck   string
+	encryption *encryption.Service
+}
+
+// New creates a client for a user and their key. Content is encrypted with
+// algorithm, AES-256-GCM when empty.
+func New(userID, userKey, algorithm string) (*Client, error) {
+	if !encryption.IsSupportedAlgorithm(algorithm) {
+		return nil, fmt.Errorf("unsupported encryption algorithm: %s", algorithm)
+	}
+	kek, err := encryption.DeriveUserKEK(userKey, userID)
+	if err != nil {
+		return nil, err
+	}
+	keyCheck, err := encryption.NewKeyCheck(userKey, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		userID:     userID,
+		kek:        kek,
+		keyCheck:   keyCheck,
+		encryption: encryption.NewService(algorithm, encryption.DefaultIterations),
+	}, nil
+}
+
+// EncryptArticle returns the upload for a new article: its content and
+// summary are encrypted under a new data key, along with the title, URL,
+// description, tags, category and metadata of full-privacy articles. The
+// article ID is generated unless the article has one, since the ciphertext
+// is bound to it.
+func (c *Client) EncryptArticle(article *Article) (*CreateRequest, error) {
+	articleID := article.ID
+	if articleID == "" {
+		articleID = uuid.New().String()
+	}
+
+	// Every article is encrypted under its own data key
+	dataKey, err := encryption.GenerateDataKey()
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := c.encryption.WrapKey(c.kek, dataKey, dataKeyAAD(c.userID, articleID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	seal := func(field, value string) (string, error) {
+		sealed, err := c.encryption.EncryptWithKey(value, dataKey, fieldAAD(c.userID, articleID, field))
+		if err != nil {
+			return "", fmt.Errorf("failed to encrypt %s: %w", field, err)
+		}
+		return sealed, nil
+	}
+
+	encrypted := &EncryptedContent{WrappedKey: wrappedKey}
+	if encrypted.Content, err = seal(fieldContent, article.Content); err != nil {
+		return nil, err
+	}
+	if article.Summary != "" {
+		if encrypted.Summary, err = seal(fieldSummary, article.Summary); err != nil {
+			return nil, err
+		}
+	}
+
+	request := &CreateRequest{
+		ID:          articleID,
+		Encrypted:   encrypted,
+		KeyCheck:    c.keyCheck,
+		FullPrivacy: article.FullPrivacy,
+	}
+	if !article.FullPrivacy {
+		request.URL = article.URL
+		request.Title = article.Title
+		request.Description = article.Description
+		request.Tags = article.Tags
+		request.Category = article.Category
+		request.Metadata = article.Metadata
+		return request, nil
+	}
+
+	packed, err := json.Marshal(privateFields{
+		Tags:     article.Tags,
+		Category: article.Category,
+		Metadata: article.Metadata,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize private fields: %w", err)
+	}
+	if encrypted.Title, err = seal(fieldTitle, article.Title); err != nil {
+		return nil, err
+	}
+	if encrypted.URL, err = seal(fieldURL, article.URL); err != nil {
+		return nil, err
+	}
+	if article.Description != "" {
+		if encrypted.Description, err = seal(fieldDescription, article.Description); err != nil {
+			return nil, err
+		}
+	}
+	if encrypted.PrivateFields, err = seal(fieldPrivateFields, string(packed)); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// DecryptContent decrypts article content returned by the server, restoring
+// the private fields of full-privacy articles. Content that is not
+// encrypted is returned as is. A key other than the one the article was
+// encrypted under fails with encryption.ErrInvalidKey.
+func (c *Client) DecryptContent(response *ContentResponse) (*Article, error) {
+	article := &Article{
+		ID:          response.Article.ID,
+		URL:         response.Article.URL,
+		Title:       response.Article.Title,
+		Description: response.Article.Description,
+		Content:     response.Content,
+		Summary:     response.Summary,
+		Tags:        response.Article.Tags,
+		Category:    response.Article.Category,
+		Metadata:    response.Metadata,
+		FullPrivacy: response.Article.IsPrivate,
+	}
+	encrypted := response.Encrypted
+	if encrypted == nil {
+		return article, nil
+	}
+
+	articleID := response.Article.ID
+	dataKey, err := c.encryption.UnwrapKey(c.kek, encrypted.WrappedKey, dataKeyAAD(c.userID, articleID))
+	if errors.Is(err, encryption.ErrDecryptionFailed) {
+		return nil, fmt.Errorf("%w: %v", encryption.ErrInvalidKey, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	open := func(field, value string) (string, error) {
+		plaintext, err := c.encryption.DecryptWithKey(value, dataKey, fieldAAD(c.userID, articleID, field))
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt %s: %w", field, err)
+		}
+		return plaintext, nil
+	}
+
+	if article.Content, err = open(fieldContent, encrypted.Content); err != nil {
+		return nil, err
+	}
+	if encrypted.Summary != "" {
+		if article.Summary, err = open(fieldSummary, encrypted.Summary); err != nil {
+			return nil, err
+		}
+	}
+	if !article.FullPrivacy {
+		return article, nil
+	}
+
+	if article.Title, err = open(fieldTitle, encrypted.Title); err != nil {
+		return nil, err
+	}
+	if article.URL, err = open(fieldURL, encrypted.URL); err != nil {
+		return nil, err
+	}
+	article.Description = ""
+	if encrypted.Description != "" {
+		if article.Description, err = open(fieldDescription, encrypted.Description); err != nil {
+			return nil, err
+		}
+	}
+	packed, err := open(fieldPrivateFields, encrypted.PrivateFields)
+	if err != nil {
+		return nil, err
+	}
+	var fields privateFields
+	if err := json.Unmarshal([]byte(packed), &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse private fields: %w", err)
+	}
+	article.Tags = fields.Tags
+	article.Category = fields.Category
+	article.Metadata = fields.Metadata
+
+	return article, nil
+}
+
+// dataKeyAAD binds a wrapped data key to its user and article
+func dataKeyAAD(userID, articleID string) []byte {
+	return []byte(userID + "/" + articleID)
+}
+
+// fieldAAD binds an encrypted field to its user, article and field name, so
+// it cannot be copied into another field, article or user's content
+func fieldAAD(userID, articleID, field string) []byte {
+	return []byte(userID + "/" + articleID + "/" + field)
+}
@@ -0,0 +1,209 @@
+package zkclient
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/readitlater/backend/internal/config"
+	"github.com/readitlater/backend/internal/encryption"
+	"github.com/readitlater/backend/internal/storage"
+)
+
+func TestClient(t *testing.T) {
+	userID := uuid.New().String()
+	userKey, _ := encryption.NewService(encryption.AlgorithmAES256GCM, 1000).GenerateUserKey()
+	client, err := New(userID, userKey, encryption.AlgorithmXChaCha20Poly1305)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	article := &Article{
+		URL:         "https://example.com/secret",
+		Title:       "Secret title",
+		Description: "Secret description",
+		Content:     "secret body",
+		Summary:     "secret summary",
+		Tags:        []string{"secret-tag"},
+		Category:    "secret-category",
+		Metadata:    map[string]string{"author": "secret-author"},
+	}
+
+	// stored returns the content file the server keeps for an upload,
+	// after checking the server accepts it
+	stored := func(t *testing.T, upload *CreateRequest) *storage.ArticleContent {
+		t.Helper()
+		content := &storage.ArticleContent{
+			ID:            upload.ID,
+			UserID:        userID,
+			Content:       upload.Encrypted.Content,
+			Summary:       upload.Encrypted.Summary,
+			Title:         upload.Encrypted.Title,
+			URL:           upload.Encrypted.URL,
+			Description:   upload.Encrypted.Description,
+			PrivateFields: upload.Encrypted.PrivateFields,
+			FullPrivacy:   upload.FullPrivacy,
+			IsEncrypted:   true,
+			WrappedKey:    upload.Encrypted.WrappedKey,
+		}
+		if err := storage.ValidateEncryptedContent(content); err != nil {
+			t.Fatalf("Expected the server to accept the upload: %v", err)
+		}
+		return content
+	}
+
+	// served returns the response of GET /articles/:id/content for an
+	// upload, decoded from JSON as a client would
+	served := func(t *testing.T, upload *CreateRequest) *ContentResponse {
+		t.Helper()
+		stored(t, upload)
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"article": map[string]interface{}{
+				"id":          upload.ID,
+				"url":         upload.URL,
+				"title":       upload.Title,
+				"description": upload.Description,
+				"tags":        upload.Tags,
+				"category":    upload.Category,
+				"is_private":  upload.FullPrivacy,
+			},
+			"id":        upload.ID,
+			"content":   "",
+			"metadata":  upload.Metadata,
+			"encrypted": upload.Encrypted,
+		})
+		var response ContentResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return &response
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		upload, err := client.EncryptArticle(article)
+		if err != nil {
+			t.Fatalf("Failed to encrypt article: %v", err)
+		}
+		if _, err := uuid.Parse(upload.ID); err != nil {
+			t.Errorf("Expected a generated article ID, got %q", upload.ID)
+		}
+		if err := encryption.VerifyKeyCheck(upload.KeyCheck, userKey, userID); err != nil {
+			t.Errorf("Expected the key check of the user key: %v", err)
+		}
+		uploadJSON, _ := json.Marshal(upload)
+		if strings.Contains(string(uploadJSON), "secret body") || strings.Contains(string(uploadJSON), "user_key") {
+			t.Errorf("Expected no plaintext content or user key in the upload, got %s", uploadJSON)
+		}
+
+		decrypted, err := client.DecryptContent(served(t, upload))
+		if err != nil {
+			t.Fatalf("Failed to decrypt content: %v", err)
+		}
+		if decrypted.Content != article.Content || decrypted.Summary != article.Summary {
+			t.Errorf("Expected decrypted content %q, got %q", article.Content, decrypted.Content)
+		}
+		if decrypted.Title != article.Title || decrypted.Metadata["author"] != "secret-author" {
+			t.Errorf("Expected the plaintext fields, got %+v", decrypted)
+		}
+	})
+
+	t.Run("FullPrivacy", func(t *testing.T) {
+		private := *article
+		private.FullPrivacy = true
+		upload, err := client.EncryptArticle(&private)
+		if err != nil {
+			t.Fatalf("Failed to encrypt article: %v", err)
+		}
+
+		uploadJSON, _ := json.Marshal(upload)
+		if strings.Contains(string(uploadJSON), "secret") {
+			t.Errorf("Expected no plaintext in the upload, got %s", uploadJSON)
+		}
+
+		decrypted, err := client.DecryptContent(served(t, upload))
+		if err != nil {
+			t.Fatalf("Failed to decrypt content: %v", err)
+		}
+		if decrypted.Title != article.Title || decrypted.URL != article.URL || decrypted.Description != article.Description || decrypted.Category != article.Category {
+			t.Errorf("Expected private fields to be restored, got %+v", decrypted)
+		}
+		if len(decrypted.Tags) != 1 || decrypted.Tags[0] != "secret-tag" || decrypted.Metadata["author"] != "secret-author" {
+			t.Errorf("Expected tags and metadata to be restored, got %v and %v", decrypted.Tags, decrypted.Metadata)
+		}
+	})
+
+	t.Run("ServerCompatible", func(t *testing.T) {
+		private := *article
+		private.FullPrivacy = true
+		upload, err := client.EncryptArticle(&private)
+		if err != nil {
+			t.Fatalf("Failed to encrypt article: %v", err)
+		}
+
+		// The server opens what the client sealed with the same user key
+		logger := logrus.New()
+		logger.SetOutput(io.Discard)
+		cfg := &config.Config{EncryptionAlgorithm: encryption.AlgorithmAES256GCM, KeyDerivationIterations: 1000}
+		service := storage.NewService(cfg, nil, logger)
+
+		contentJSON, _ := json.Marshal(stored(t, upload))
+		decryptedJSON, err := service.DecryptContent(userID, upload.ID, string(contentJSON), userKey)
+		if err != nil {
+			t.Fatalf("Expected the server to decrypt the upload: %v", err)
+		}
+		var decrypted storage.ArticleContent
+		json.Unmarshal([]byte(decryptedJSON), &decrypted)
+		if decrypted.Content != article.Content || decrypted.Title != article.Title || decrypted.Category != article.Category {
+			t.Errorf("Expected the server to read the article, got %+v", decrypted)
+		}
+	})
+
+	t.Run("WrongKey", func(t *testing.T) {
+		upload, err := client.EncryptArticle(article)
+		if err != nil {
+			t.Fatalf("Failed to encrypt article: %v", err)
+		}
+
+		otherKey, _ := encryption.NewService(encryption.AlgorithmAES256GCM, 1000).GenerateUserKey()
+		other, err := New(userID, otherKey, "")
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		if _, err := other.DecryptContent(served(t, upload)); !errors.Is(err, encryption.ErrInvalidKey) {
+			t.Errorf("Expected ErrInvalidKey, got %v", err)
+		}
+	})
+
+	t.Run("OtherArticle", func(t *testing.T) {
+		upload, err := client.EncryptArticle(article)
+		if err != nil {
+			t.Fatalf("Failed to encrypt article: %v", err)
+		}
+
+		// Ciphertext served under another article ID does not open
+		content := served(t, upload)
+		content.Article.ID = uuid.New().String()
+		if _, err := client.DecryptContent(content); err == nil {
+			t.Error("Expected content moved to another article to fail")
+		}
+	})
+
+	t.Run("Unencrypted", func(t *testing.T) {
+		content := &ContentResponse{Content: "plain"}
+		decrypted, err := client.DecryptContent(content)
+		if err != nil || decrypted.Content != "plain" {
+			t.Errorf("Expected unencrypted content as is, got %q, %v", decrypted.Content, err)
+		}
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		if _, err := New(userID, "correct horse battery staple", ""); !errors.Is(err, encryption.ErrInvalidKey) {
+			t.Errorf("Expected ErrInvalidKey, got %v", err)
+		}
+	})
+}